@@ -12,11 +12,12 @@ import (
 	"github.com/cs-au-dk/goat/utils/dot"
 )
 
-const condensePanickedConfigurations = true
+const condenseCrashedConfigurations = true
 
 // Construct a Dot graph given a starting coarse configuration.
 func (s0 *AbsConfiguration) OldVisualize() {
 	G := &dot.DotGraph{
+		Name: "futures",
 		Options: map[string]string{
 			"minlen":  fmt.Sprint(opts.Minlen()),
 			"nodesep": fmt.Sprint(opts.Nodesep()),
@@ -152,9 +153,9 @@ func (s0 *AbsConfiguration) OldVisualize() {
 		for _, succ := range conf.Successors {
 			conf1 := succ.Configuration()
 
-			if condensePanickedConfigurations && conf1.IsPanicked() {
+			if condenseCrashedConfigurations && conf1.IsCrashed() {
 				cluster := configurationToCluster[conf]
-				cluster.Cluster.Attrs["label"] = "Panics"
+				cluster.Cluster.Attrs["label"] = "Crashes"
 				continue
 			}
 
@@ -33,7 +33,8 @@ type AbsConfiguration struct {
 	superloc     defs.Superloc
 	Target       defs.Goro
 	level        ABSTRACTION_LEVEL
-	panicked     *bool // cached result of IsPanicked
+	crashed      *bool // cached result of IsCrashed
+	exited       *bool // cached result of IsExited
 }
 
 func (s *AbsConfiguration) Init(abs ABSTRACTION_LEVEL) Configuration {
@@ -126,19 +127,50 @@ func (s *AbsConfiguration) PrettyPrint() {
 	fmt.Println(s.superloc.String())
 }
 
+// Returns true iff. no goroutine can progress silently in the configuration.
+// This is the case if every goroutine is at a communication operation, or if
+// the program has exited.
 func (s *AbsConfiguration) IsSynchronizing(C AnalysisCtxt, state L.AnalysisState) bool {
-	return s.nextSilentProgress(C, state) == nil
+	return s.IsExited() || s.nextSilentProgress(C, state) == nil
 }
 
-// Returns true iff. the configuration contains a panicked goroutine
-func (s *AbsConfiguration) IsPanicked() bool {
-	if s.panicked == nil {
+// Returns true iff. the program has crashed in the configuration, because a
+// panic escaped the root function of a goroutine.
+func (s *AbsConfiguration) IsCrashed() bool {
+	if s.crashed == nil {
 		_, _, found := s.Superlocation().Find(func(_ defs.Goro, cl defs.CtrLoc) bool {
-			return cl.Panicked()
+			n, ok := cl.Node().(*cfg.TerminateGoro)
+			return ok && n.Cause() == cfg.GoroTermination.CRASHED
 		})
-		s.panicked = &found
+		s.crashed = &found
 	}
-	return *s.panicked
+	return *s.crashed
+}
+
+// Returns true iff. the program has exited in the configuration, because the
+// main goroutine returned from main or a goroutine called os.Exit.
+func (s *AbsConfiguration) IsExited() bool {
+	if s.exited == nil {
+		exited := hasExited(s.Superlocation())
+		s.exited = &exited
+	}
+	return *s.exited
+}
+
+// Returns true iff. a goroutine ended the program at the superlocation.
+func hasExited(sl defs.Superloc) bool {
+	_, _, found := sl.Find(func(_ defs.Goro, cl defs.CtrLoc) bool {
+		n, ok := cl.Node().(*cfg.TerminateGoro)
+		return ok && n.Cause() == cfg.GoroTermination.EXIT_PROGRAM
+	})
+	return found
+}
+
+// Returns true iff. returning from the root function of the goroutine ends
+// the program, i.e., if it is the main goroutine of a program.
+func endsProgram(g defs.Goro, root *ssa.Function) bool {
+	return g.IsRoot() &&
+		root.Pkg != nil && root.Pkg.Pkg.Name() == "main" && root.Name() == "main"
 }
 
 // Returns whether the control location is a communication operation
@@ -206,8 +238,8 @@ func (s *AbsConfiguration) isAtRelevantCommunicationNode(
 // Other relations are ordered by hash values.
 func (s *AbsConfiguration) nextSilentProgress(C AnalysisCtxt, state L.AnalysisState) (ret defs.Goro) {
 	s.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
-		// Discard communication nodes and panicked control locations.
-		if cl.Panicked() || s.isAtRelevantCommunicationNode(C, state.Memory(), g, cl) {
+		// Discard communication nodes.
+		if s.isAtRelevantCommunicationNode(C, state.Memory(), g, cl) {
 			return
 		}
 
@@ -236,12 +268,25 @@ func (s *AbsConfiguration) GetTransitions(
 	C AnalysisCtxt,
 	initState L.AnalysisState) transfers {
 
+	// No goroutine can progress after the program has exited.
+	if s.IsExited() {
+		return make(transfers)
+	}
+
 	// Determine whether any thread should be progressed silently.
 	if progressSilently := s.nextSilentProgress(C, initState); progressSilently != nil {
 		C.Log.Superloc = s.superloc
 		return s.GetSilentSuccessors(C, progressSilently, initState)
 	}
 
+	return s.getCommTransitions(C, initState)
+}
+
+// Returns the synchronizing transitions of a configuration where
+// all goroutines are at communication operations.
+func (s *AbsConfiguration) getCommTransitions(
+	C AnalysisCtxt,
+	initState L.AnalysisState) transfers {
 	// leaves contains the cfg nodes the different goroutines can end up at (without synchronizing).
 	leaves := make(map[defs.Goro]map[defs.CtrLoc]struct{})
 
@@ -277,12 +322,6 @@ func (s *AbsConfiguration) GetTransitions(
 		}
 	})
 
-	// Check whether the root thread has progressed to termination.
-	// If so, cut off abstract interpretation here.
-	// if _, ok := s.superloc.GetUnsafe(s.superloc.Main()).Node().(*cfg.TerminateGoro); ok {
-	// 	return nil
-	// }
-
 	if mops.Memory().Lattice() == nil {
 		log.Fatal("Memory is nil?", mops)
 	}
@@ -298,113 +337,8 @@ func (s *AbsConfiguration) GetSilentSuccessors(
 	g defs.Goro,
 	initState L.AnalysisState,
 ) transfers {
-	// Intra-processual analysis worklist.
 	cl0 := s.Threads().GetUnsafe(g)
-	analysis := map[defs.CtrLoc]L.AnalysisState{cl0: initState}
-
-	if cl0.Panicked() {
-		panic("Abstract interpretation of panicked control locations is disabled")
-	}
-
-	graph := map[defs.CtrLoc][]defs.CtrLoc{}
-	// NOTE: You can visualize this graph with `VisualizeIntraprocess(g, graph, analysis)`
-	/* defer func() {
-		if err := recover(); err != nil || (C.Metrics.Enabled() && C.Metrics.Outcome == OUTCOME_PANIC) {
-			VisualizeIntraprocess(g, graph, analysis)
-			if err != nil {
-				panic(err)
-			}
-		}
-	}() */
-
-	// When to stop looking for successors.
-	// Currently when encountering a communication node, a spawn of a goroutine,
-	// or a panicked control location.
-	stopCond := func(cl defs.CtrLoc) bool {
-		n := cl.Node()
-		if s.isAtRelevantCommunicationNode(C, analysis[cl].Memory(), g, cl) {
-			return true
-		}
-
-		if len(n.Spawns()) == 0 {
-			return false
-		}
-
-		return true
-		/*
-			// The next available index for a goroutine spawn.
-			index := s.Superlocation().NextIndex(
-				// Prevent cyclical spawns in goroutines.
-				g.Spawn(cl).GetRadix())
-			// Only stop at spawn if we will actually spawn a goroutine
-			return opts.WithinGoroBound(index)
-		*/
-	}
-
-	start := time.Now()
-	steps := 0
-	checkSkippedInterval := 100_000
-
-	W := defs.EmptyIntraprocessWorklist(C.LoadRes.CtrLocPriorities)
-	W.Add(cl0)
-FIXPOINT:
-	for ; !W.IsEmpty(); steps++ {
-		if C.Metrics.Enabled() && steps%checkSkippedInterval == 0 && steps > 0 {
-			select {
-			case <-C.Metrics.skipped:
-				break FIXPOINT
-			default:
-			}
-		}
-
-		cl := W.GetNext()
-		pair := analysis[cl]
-
-		if stopCond(cl) {
-			continue
-		}
-
-		edges := []defs.CtrLoc{}
-		s.singleSilent(C, g, cl, pair).ForEach(func(cl defs.CtrLoc, updPair L.AnalysisState) {
-			edges = append(edges, cl)
-
-			if cl.Panicked() {
-				// Do not spend time joining memory for panicked control locations.
-				// We will never use this memory, and joining can be expensive due to the high
-				// number of predecessors (and therefore different versions of the memory).
-				analysis[cl] = updPair
-				return
-			}
-
-			// If we previously visited w, we join the memory there with the updated memory and
-			// check for a change to determine if we should push the location into the queue again.
-			// If it is the first time we encounter w, we always push it.
-			push := true
-			if prevPair, ok := analysis[cl]; ok && len(cl.Node().Predecessors()) != 1 {
-				// NOTE: We can (maybe) save some joins by computing the number of predecessors
-				// ourselves (like we compute the forward edges for `graph`). The number we
-				// find can possibly be lower than what is in the CFG (for example for branches
-				// that we can always correctly predict).
-				updPair = prevPair.MonoJoin(updPair)
-				push = !updPair.Eq(prevPair)
-			}
-
-			if push {
-				analysis[cl] = updPair
-				W.Add(cl)
-			}
-		})
-
-		graph[cl] = edges
-	}
-
-	duration := time.Since(start)
-	reanalysisFactor := float64(steps) / float64(len(analysis))
-	if duration >= 2*time.Second && reanalysisFactor >= 20. {
-		log.Printf("Slow (%s) internal transition for %v from %v of %v", duration, g, cl0, cl0.Node().Function())
-		log.Printf("Made %d steps to reach fixpoint for %d locations (%.2f steps/loc, %.2f steps/s)",
-			steps, len(analysis), float64(steps)/float64(len(analysis)), float64(steps)/duration.Seconds())
-	}
+	analysis := s.silentFixpoint(C, g, initState)
 
 	results := make(transfers)
 	addResult := func(conf *AbsConfiguration, state L.AnalysisState) {
@@ -430,8 +364,6 @@ FIXPOINT:
 	for cl, state := range analysis {
 		n := cl.Node()
 		switch {
-		case cl.Panicked():
-			fallthrough
 		case s.isAtRelevantCommunicationNode(C, state.Memory(), g, cl):
 			addResult(
 				s.Copy().DeriveThread(g, cl),
@@ -573,7 +505,15 @@ FIXPOINT:
 			if _, ok := n.(*cfg.FunctionExit); ok && n.Function() == cl.Root() {
 				// If the function exit node belongs to the root function
 				// of the goroutine, it indicates a potential goroutine exit point.
-				addTermination(cl, state, cfg.GoroTermination.EXIT_ROOT)
+				// A panic that was not recovered before reaching it crashes the program.
+				switch {
+				case cl.Panicked():
+					addTermination(cl, state, cfg.GoroTermination.CRASHED)
+				case endsProgram(g, cl.Root()):
+					addTermination(cl, state, cfg.GoroTermination.EXIT_PROGRAM)
+				default:
+					addTermination(cl, state, cfg.GoroTermination.EXIT_ROOT)
+				}
 			}
 		}
 	}
@@ -582,23 +522,115 @@ FIXPOINT:
 	// termination point to prevent us from picking this goroutine again.
 	// TODO: Use some form of cycle detection to determine if the goroutine can
 	// loop forever.
-	const (
-		HAS_SUCCS = 1 << iota
-		HAS_PANICS
-	)
-	addTerm := 0
-	for _, succ := range results {
-		if !succ.Configuration().GetUnsafe(g).Panicked() {
-			addTerm = addTerm | HAS_SUCCS
-			break
-		} else {
-			addTerm = addTerm | HAS_PANICS
+	if len(results) == 0 {
+		addTermination(cl0, initState, cfg.GoroTermination.INFINITE_LOOP)
+	}
+
+	return results
+}
+
+// silentFixpoint computes the abstract states reachable by silently progressing
+// goroutine g from its current control location, stopping at relevant
+// communication nodes and goroutine spawns.
+func (s *AbsConfiguration) silentFixpoint(
+	C AnalysisCtxt,
+	g defs.Goro,
+	initState L.AnalysisState,
+) map[defs.CtrLoc]L.AnalysisState {
+	// Intra-processual analysis worklist.
+	cl0 := s.Threads().GetUnsafe(g)
+	analysis := map[defs.CtrLoc]L.AnalysisState{cl0: initState}
+
+	graph := map[defs.CtrLoc][]defs.CtrLoc{}
+	// NOTE: You can visualize this graph with `VisualizeIntraprocess(g, graph, analysis)`
+	/* defer func() {
+		if err := recover(); err != nil || (C.Metrics.Enabled() && C.Metrics.Outcome == OUTCOME_PANIC) {
+			VisualizeIntraprocess(g, graph, analysis)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}() */
+
+	// When to stop looking for successors.
+	// Currently when encountering a communication node or a spawn of a goroutine.
+	stopCond := func(cl defs.CtrLoc) bool {
+		n := cl.Node()
+		if s.isAtRelevantCommunicationNode(C, analysis[cl].Memory(), g, cl) {
+			return true
 		}
+
+		if len(n.Spawns()) == 0 {
+			return false
+		}
+
+		return true
+		/*
+			// The next available index for a goroutine spawn.
+			index := s.Superlocation().NextIndex(
+				// Prevent cyclical spawns in goroutines.
+				g.Spawn(cl).GetRadix())
+			// Only stop at spawn if we will actually spawn a goroutine
+			return opts.WithinGoroBound(index)
+		*/
 	}
 
-	if addTerm == 0 {
-		addTermination(cl0, initState, cfg.GoroTermination.INFINITE_LOOP)
+	start := time.Now()
+	steps := 0
+	checkSkippedInterval := 100_000
+
+	W := defs.EmptyIntraprocessWorklist(C.LoadRes.CtrLocPriorities)
+	W.Add(cl0)
+FIXPOINT:
+	for ; !W.IsEmpty(); steps++ {
+		if C.Metrics.Enabled() && steps%checkSkippedInterval == 0 && steps > 0 {
+			select {
+			case <-C.Metrics.skipped:
+				break FIXPOINT
+			default:
+			}
+		}
+
+		cl := W.GetNext()
+		pair := analysis[cl]
+
+		if stopCond(cl) {
+			continue
+		}
+
+		edges := []defs.CtrLoc{}
+		s.singleSilent(C, g, cl, pair).ForEach(func(cl defs.CtrLoc, updPair L.AnalysisState) {
+			edges = append(edges, cl)
+
+			// If we previously visited w, we join the memory there with the updated memory and
+			// check for a change to determine if we should push the location into the queue again.
+			// If it is the first time we encounter w, we always push it.
+			push := true
+			if prevPair, ok := analysis[cl]; ok && len(cl.Node().Predecessors()) != 1 {
+				// NOTE: We can (maybe) save some joins by computing the number of predecessors
+				// ourselves (like we compute the forward edges for `graph`). The number we
+				// find can possibly be lower than what is in the CFG (for example for branches
+				// that we can always correctly predict).
+				updPair = prevPair.MonoJoin(updPair)
+				push = !updPair.Eq(prevPair)
+			}
+
+			if push {
+				analysis[cl] = updPair
+				W.Add(cl)
+			}
+		})
+
+		graph[cl] = edges
 	}
 
-	return results
+	duration := time.Since(start)
+	reanalysisFactor := float64(steps) / float64(len(analysis))
+	if duration >= 2*time.Second && reanalysisFactor >= 20. {
+		log.Printf("Slow (%s) internal transition for %v from %v of %v", duration, g, cl0, cl0.Node().Function())
+		log.Printf("Made %d steps to reach fixpoint for %d locations (%.2f steps/loc, %.2f steps/s)",
+			steps, len(analysis), float64(steps)/float64(len(analysis)), float64(steps)/duration.Seconds())
+	}
+
+	return analysis
 }
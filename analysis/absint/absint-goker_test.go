@@ -1,9 +1,11 @@
 package absint
 
 import (
+	"fmt"
 	"log"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cs-au-dk/goat/analysis/defs"
@@ -20,17 +22,19 @@ func StaticAnalysisAndBlockingTests(t *testing.T, C AnalysisCtxt, a L.Analysis,
 	BlockAnalysisTest(t, C, a, sg, nm)
 }
 
-func TestGoKerLocalized(t *testing.T) {
-	// rg "Communication Deadlock \| Channel \|" examples/src/ --files-with-matches | sed 's|/README.md||g' | sort
-	// gobench/goker/blocking/cockroach/25456, gobench/goker/blocking/cockroach/35931
-	// - wildcard swap due to getting a struct with the channel outside the fragment
-	// gobench/goker/blocking/cockroach/35073 - needs loop unrolling and context sensitivity
-	// gobench/goker/blocking/grpc/660 - spawn in infinite loop
-	// gobench/goker/blocking/kubernetes/38669 - not the correct PSet, interprocedural deps only
-	// gobench/goker/blocking/moby/21233 - wildcard swap due to passing channel out of fragment and back
-	// gobench/goker/blocking/moby/33781 - spawn in infinite loop
-	// gobench/goker/blocking/syncthing/5795 - wildcard swap due to storing closure on top object
-	tests := strings.Split(`
+// goKerLocalizedTests are the GoKer benchmarks with channel deadlocks that
+// are found by localized analysis of GCatch PSets.
+//
+// rg "Communication Deadlock \| Channel \|" examples/src/ --files-with-matches | sed 's|/README.md||g' | sort
+// gobench/goker/blocking/cockroach/25456, gobench/goker/blocking/cockroach/35931
+// - wildcard swap due to getting a struct with the channel outside the fragment
+// gobench/goker/blocking/cockroach/35073 - needs loop unrolling and context sensitivity
+// gobench/goker/blocking/grpc/660 - spawn in infinite loop
+// gobench/goker/blocking/kubernetes/38669 - not the correct PSet, interprocedural deps only
+// gobench/goker/blocking/moby/21233 - wildcard swap due to passing channel out of fragment and back
+// gobench/goker/blocking/moby/33781 - spawn in infinite loop
+// gobench/goker/blocking/syncthing/5795 - wildcard swap due to storing closure on top object
+var goKerLocalizedTests = strings.Split(`
 gobench/goker/blocking/cockroach/2448
 gobench/goker/blocking/cockroach/24808
 gobench/goker/blocking/etcd/6857
@@ -42,144 +46,253 @@ gobench/goker/blocking/kubernetes/70277
 gobench/goker/blocking/moby/33293
 gobench/goker/blocking/moby/4395`, "\n")[1:]
 
-	metrics = blockAnalysisMetrics{}
-	for _, test := range tu.ListGoKerPackages(t, "../..") {
-		included := false
-		for _, curated := range tests {
-			if strings.HasPrefix(test, curated) && test == curated {
-				included = true
-				break
-			}
+// goKerPSetStrategy computes the PSets for the main function of a GoKer benchmark.
+type goKerPSetStrategy = func(loadRes tu.LoadResult, entry *ssa.Function, ps gotopo.Primitives) gotopo.PSets
+
+func goKerGCatchPSets(loadRes tu.LoadResult, entry *ssa.Function, ps gotopo.Primitives) gotopo.PSets {
+	callDAG := loadRes.PrunedCallDAG
+	G := callDAG.Original
+
+	return gotopo.GetGCatchPSets(
+		loadRes.Cfg, entry, loadRes.Pointer, G,
+		G.DominatorTree(entry), callDAG, ps,
+	)
+}
+
+func goKerIntraDependentPSets(loadRes tu.LoadResult, entry *ssa.Function, ps gotopo.Primitives) gotopo.PSets {
+	return gotopo.GetIntraDependentPSets(
+		loadRes.Cfg, entry, loadRes.Pointer, loadRes.PrunedCallDAG.Original, ps,
+	)
+}
+
+// goKerResult summarizes the localized analysis of a GoKer benchmark.
+type goKerResult struct {
+	psets, maxPSetSize int
+	found, missed      []tu.AnnBlocks
+	blocks             Blocks
+}
+
+// analyzeGoKer runs the localized analysis of every PSet computed by the
+// strategy, and checks which of the annotated channel deadlocks are found.
+func analyzeGoKer(t *testing.T, loadRes tu.LoadResult, getPSets goKerPSetStrategy) (res goKerResult) {
+	entry := loadRes.Mains[0].Func("main")
+	if entry == nil {
+		t.Fatal("Could not find main function on", loadRes.Mains[0])
+	}
+
+	G := loadRes.PrunedCallDAG.Original
+	computeDominator := G.DominatorTree(entry)
+
+	ps, primsToUses := gotopo.GetPrimitives(entry, loadRes.Pointer, G)
+	psets := getPSets(loadRes, entry, ps)
+
+	// Ensure consistent ordering
+	sort.Slice(psets, func(i, j int) bool {
+		return psets[i].String() < psets[j].String()
+	})
+
+	t.Log(len(psets), "PSets to process")
+
+	res.psets = len(psets)
+	res.blocks = make(Blocks)
+
+	for i, pset := range psets {
+		t.Log(color.CyanString("PSet"), i+1, color.CyanString("of"), len(psets), color.CyanString(":"))
+		t.Log(pset, "\n")
+
+		if pset.Size() > res.maxPSetSize {
+			res.maxPSetSize = pset.Size()
 		}
 
-		if !included {
-			continue
+		funs := []*ssa.Function{}
+		pset.ForEach(func(v ssa.Value) {
+			if v.Parent() != nil {
+				// Include allocation site in dominator computation
+				funs = append(funs, v.Parent())
+			}
+			for fun := range primsToUses[v] {
+				funs = append(funs, fun)
+			}
+		})
+
+		loweredEntry := computeDominator(funs...)
+		t.Log("Using", loweredEntry, "as entrypoint")
+
+		C := ConfigAI(AIConfig{Metrics: true}).Function(loweredEntry)(loadRes)
+		C.FragmentPredicateFromPrimitives(pset.Entries(), primsToUses)
+
+		C.Metrics.TimerStart()
+		ts, analysis := StaticAnalysis(C)
+
+		//log.Println("Superlocation graph size:", ts.Size())
+
+		switch C.Metrics.Outcome {
+		case OUTCOME_PANIC:
+			log.Println(color.RedString("Aborted!"))
+			log.Println(C.Metrics.Error())
+		default:
+			C.Metrics.Done()
+			log.Println(color.GreenString("SA completed in %s", C.Metrics.Performance()))
+
+			res.blocks.UpdateWith(BlockAnalysis(C, ts, analysis))
 		}
+	}
 
-		t.Run(tu.GoKerTestName(test), func(t *testing.T) {
-			t.Parallel()
-			tu.ParallelHelper(t,
-				tu.LoadExampleAsPackages(t, "../..", test, true),
-				func(loadRes tu.LoadResult) {
-					entry := loadRes.Mains[0].Func("main")
-					if entry == nil {
-						t.Fatal("Could not find main function on", loadRes.Mains[0])
+	findClInSl := func(ann tu.AnnProgress) func(defs.Goro, defs.CtrLoc) bool {
+		return func(g defs.Goro, cl defs.CtrLoc) bool {
+			if /* !ann.HasFocus() || ann.Focused().Matches(g) */ true {
+				for node := range ann.Nodes() {
+					if cl.Node() == node {
+						return true
 					}
+				}
+			}
+			return false
+		}
+	}
+
+	findCl := func(ann tu.AnnProgress) func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool {
+		inner := findClInSl(ann)
+		return func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool {
+			for g := range gs {
+				if inner(g, sl.GetUnsafe(g)) {
+					return true
+				}
+			}
+			return false
+		}
+	}
 
-					callDAG := loadRes.PrunedCallDAG
-					G := callDAG.Original
+	tu.MakeNotesManager(t, loadRes).ForEachAnnotation(func(a tu.Annotation) {
+		if ann, ok := a.(tu.AnnBlocks); ok {
+			isChOp := false
+			for node := range ann.Nodes() {
+				if node.IsChannelOp() {
+					isChOp = true
+					break
+				}
+			}
 
-					computeDominator := G.DominatorTree(entry)
+			if !isChOp {
+				return
+			}
 
-					ps, primsToUses := gotopo.GetPrimitives(entry, loadRes.Pointer, G)
+			if !res.blocks.Exists(findCl(ann)) {
+				res.missed = append(res.missed, ann)
+			} else {
+				res.found = append(res.found, ann)
+			}
+		}
+	})
 
-					// GCatch PSets
-					psets := gotopo.GetGCatchPSets(
-						loadRes.Cfg, entry, loadRes.Pointer, G,
-						computeDominator, callDAG, ps,
-					)
+	return
+}
 
-					// Ensure consistent ordering
-					sort.Slice(psets, func(i, j int) bool {
-						return psets[i].String() < psets[j].String()
-					})
+func isGoKerLocalizedTest(test string) bool {
+	for _, curated := range goKerLocalizedTests {
+		if strings.HasPrefix(test, curated) && test == curated {
+			return true
+		}
+	}
+	return false
+}
 
-					t.Log(len(psets), "PSets to process")
+func TestGoKerLocalized(t *testing.T) {
+	metrics = blockAnalysisMetrics{}
+	for _, test := range tu.ListGoKerPackages(t, "../..") {
+		if !isGoKerLocalizedTest(test) {
+			continue
+		}
 
-					blocks := make(Blocks)
+		test := test
+		t.Run(tu.GoKerTestName(test), func(t *testing.T) {
+			t.Parallel()
+			tu.ParallelHelper(t,
+				tu.LoadExampleAsPackages(t, "../..", test, true),
+				func(loadRes tu.LoadResult) {
+					res := analyzeGoKer(t, loadRes, goKerGCatchPSets)
 
-					for i, pset := range psets {
-						t.Log(color.CyanString("PSet"), i+1, color.CyanString("of"), len(psets), color.CyanString(":"))
-						t.Log(pset, "\n")
+					for _, ann := range res.missed {
+						t.Error("False negative:", ann)
+						metrics.falseNegatives++
+					}
+					metrics.truePositives += len(res.found)
 
-						funs := []*ssa.Function{}
-						pset.ForEach(func(v ssa.Value) {
-							if v.Parent() != nil {
-								// Include allocation site in dominator computation
-								funs = append(funs, v.Parent())
-							}
-							for fun := range primsToUses[v] {
-								funs = append(funs, fun)
-							}
-						})
+					if t.Failed() {
+						t.Log("Detected blocks:\n", res.blocks)
+					}
+				})
+		})
+	}
 
-						loweredEntry := computeDominator(funs...)
-						t.Log("Using", loweredEntry, "as entrypoint")
+	//t.Log(metrics)
+}
 
-						C := ConfigAI(AIConfig{Metrics: true}).Function(loweredEntry)(loadRes)
-						C.FragmentPredicateFromPrimitives(pset.Entries(), primsToUses)
+// TestGoKerPSetComparison compares the sizes of intra-dependent and GCatch
+// PSets, and the channel deadlocks found by localized analysis of them.
+// Intra-dependent PSets must find every deadlock that GCatch PSets find.
+// The comparison includes kubernetes/38669, where GCatch PSets do not
+// contain the channels involved in the deadlock, but intra-dependent
+// PSets must find all of them.
+func TestGoKerPSetComparison(t *testing.T) {
+	strategies := []struct {
+		name     string
+		getPSets goKerPSetStrategy
+	}{
+		{"gcatch", goKerGCatchPSets},
+		{"intra-dependent", goKerIntraDependentPSets},
+	}
 
-						C.Metrics.TimerStart()
-						ts, analysis := StaticAnalysis(C)
+	var (
+		mu   sync.Mutex
+		rows []string
+	)
 
-						//log.Println("Superlocation graph size:", ts.Size())
+	t.Cleanup(func() {
+		sort.Strings(rows)
+		header := fmt.Sprintf("%-20s %-16s %6s %8s %8s", "Benchmark", "PSets", "Count", "Max size", "Found")
+		t.Log("PSet comparison:\n" + strings.Join(append([]string{header}, rows...), "\n"))
+	})
 
-						switch C.Metrics.Outcome {
-						case OUTCOME_PANIC:
-							log.Println(color.RedString("Aborted!"))
-							log.Println(C.Metrics.Error())
-						default:
-							C.Metrics.Done()
-							log.Println(color.GreenString("SA completed in %s", C.Metrics.Performance()))
+	for _, test := range tu.ListGoKerPackages(t, "../..") {
+		if !isGoKerLocalizedTest(test) && test != "gobench/goker/blocking/kubernetes/38669" {
+			continue
+		}
 
-							blocks.UpdateWith(BlockAnalysis(C, ts, analysis))
-						}
-					}
+		test := test
+		t.Run(tu.GoKerTestName(test), func(t *testing.T) {
+			t.Parallel()
+			tu.ParallelHelper(t,
+				tu.LoadExampleAsPackages(t, "../..", test, true),
+				func(loadRes tu.LoadResult) {
+					results := make(map[string]goKerResult, len(strategies))
+					for _, strategy := range strategies {
+						res := analyzeGoKer(t, loadRes, strategy.getPSets)
+						results[strategy.name] = res
+						found, total := len(res.found), len(res.found)+len(res.missed)
 
-					findClInSl := func(ann tu.AnnProgress) func(defs.Goro, defs.CtrLoc) bool {
-						return func(g defs.Goro, cl defs.CtrLoc) bool {
-							if /* !ann.HasFocus() || ann.Focused().Matches(g) */ true {
-								for node := range ann.Nodes() {
-									if cl.Node() == node {
-										return true
-									}
-								}
-							}
-							return false
-						}
+						mu.Lock()
+						rows = append(rows, fmt.Sprintf("%-20s %-16s %6d %8d %5d/%d",
+							tu.GoKerTestName(test), strategy.name, res.psets, res.maxPSetSize, found, total))
+						mu.Unlock()
 					}
 
-					findCl := func(ann tu.AnnProgress) func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool {
-						inner := findClInSl(ann)
-						return func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool {
-							for g := range gs {
-								if inner(g, sl.GetUnsafe(g)) {
-									return true
-								}
-							}
-							return false
+					intra := make(map[string]bool)
+					for _, ann := range results["intra-dependent"].found {
+						intra[fmt.Sprint(ann)] = true
+					}
+					for _, ann := range results["gcatch"].found {
+						if !intra[fmt.Sprint(ann)] {
+							t.Error("Found with GCatch PSets, but not with intra-dependent PSets:", ann)
 						}
 					}
 
-					tu.MakeNotesManager(t, loadRes).ForEachAnnotation(func(a tu.Annotation) {
-						if ann, ok := a.(tu.AnnBlocks); ok {
-							isChOp := false
-							for node := range ann.Nodes() {
-								if node.IsChannelOp() {
-									isChOp = true
-									break
-								}
-							}
-
-							if !isChOp {
-								return
-							}
-
-							if !blocks.Exists(findCl(ann)) {
-								t.Error("False negative:", ann)
-								metrics.falseNegatives++
-							} else {
-								metrics.truePositives++
-							}
+					if test == "gobench/goker/blocking/kubernetes/38669" {
+						for _, ann := range results["intra-dependent"].missed {
+							t.Error("Not found with intra-dependent PSets:", ann)
 						}
-					})
-
-					if t.Failed() {
-						t.Log("Detected blocks:\n", blocks)
 					}
 				})
 		})
 	}
-
-	//t.Log(metrics)
 }
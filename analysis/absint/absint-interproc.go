@@ -206,6 +206,11 @@ func (C AnalysisCtxt) callSuccs(
 
 	expandedFunctions := make(map[*ssa.Function]struct{})
 
+	// Deferred calls made while unwinding the defer stack of a panic may
+	// recover from the panic.
+	_, isDefer := n.(*cfg.DeferCall)
+	recovering := isDefer && cl.Panicked()
+
 	blacklists := make(map[*ssa.Function]struct{})
 	for succ := range cl.Successors() {
 		if _, isWaiting := succ.Node().(*cfg.Waiting); isWaiting {
@@ -231,20 +236,43 @@ func (C AnalysisCtxt) callSuccs(
 		if nsuccs, hasModel := C.stdCall(g, cl, callIns, state, sfun); hasModel {
 			succs = succs.MonoJoin(nsuccs)
 		} else if C.Blacklisted(callIns, sfun) {
+			if exitsProgram(sfun) {
+				// Calls to functions that are not analyzed still end the
+				// program if they always end up calling e.g. os.Exit.
+				succs = succs.MonoJoin(C.exitProgram(cl, state))
+				continue
+			}
 			blacklists[sfun] = struct{}{}
 		} else {
 			C.Metrics.ExpandFunction(sfun)
-			// Clear the exiting flag when entering a function
-			funEntryCl := succ.WithExiting(false)
+			// Clear the exiting and panicked flags when entering a function
+			funEntryCl := succ.WithExiting(false).WithPanicked(false).WithRecovering(recovering)
 			// NOTE: Assumes that FunctionEntry defer link goes to FunctionExit
 			funExitCl := funEntryCl.Derive(funEntryCl.Node().DeferLink())
+			post := cl.Derive(postCall)
+
+			// Add charged return edges from function exit to postcall node
+			newState := state.UpdateMemory(newMem)
+			if recovering {
+				// If the deferred function returns normally after calling recover,
+				// the panic stops and the defer stack is unwound normally.
+				may, must := recovers(sfun)
+				if !must {
+					newState = newState.AddCharge(g, funExitCl, post)
+				}
+				if may {
+					newState = newState.AddCharge(g, funExitCl, post.WithPanicked(false))
+				}
+			} else {
+				newState = newState.AddCharge(g, funExitCl, post)
+			}
+
 			succs = succs.Update(
 				funEntryCl,
-				// Add charged return edge from function exit to postcall node
-				state.UpdateMemory(newMem).AddCharge(
-					g, funExitCl, cl.Derive(postCall),
+				newState.AddCharge(
+					g, funExitCl.WithExiting(true), post.WithExiting(true),
 				).AddCharge(
-					g, funExitCl.WithExiting(true), cl.Derive(postCall).WithExiting(true),
+					g, funExitCl.WithPanicked(true), post.WithPanicked(true),
 				),
 			)
 			expandedFunctions[sfun] = struct{}{}
@@ -274,6 +302,47 @@ func (C AnalysisCtxt) callSuccs(
 	return succs
 }
 
+// recovers determines whether the function may, or must, call the built-in
+// recover before returning normally.
+func recovers(fun *ssa.Function) (may, must bool) {
+	var recoverBlocks []*ssa.BasicBlock
+	for _, block := range fun.Blocks {
+		for _, insn := range block.Instrs {
+			if call, ok := insn.(*ssa.Call); ok {
+				if b, ok := call.Call.Value.(*ssa.Builtin); ok && b.Name() == "recover" {
+					recoverBlocks = append(recoverBlocks, block)
+					break
+				}
+			}
+		}
+	}
+
+	if len(recoverBlocks) == 0 {
+		return false, false
+	}
+
+	// The function must recover if every return is dominated by a call to recover.
+	for _, block := range fun.Blocks {
+		if _, isReturn := block.Instrs[len(block.Instrs)-1].(*ssa.Return); !isReturn {
+			continue
+		}
+
+		dominated := false
+		for _, rblock := range recoverBlocks {
+			if rblock.Dominates(block) {
+				dominated = true
+				break
+			}
+		}
+
+		if !dominated {
+			return true, false
+		}
+	}
+
+	return true, true
+}
+
 // For each possible called function, returns a memory where parameters have been moved from the
 // caller into the memory of the callee.
 // Uses points-to values to determine the possible called functions.
@@ -525,15 +594,21 @@ func (C AnalysisCtxt) exitSuccs(
 					)
 				}
 
-				exitNode := node.Derive(exit)
-				reachableExits[exitNode] = true
-				return chargedReturns.Edges(exitNode)
+				var edges []defs.CtrLoc
+				for _, exiting := range [...]bool{false, true} {
+					for _, panicked := range [...]bool{false, true} {
+						exitNode := node.Derive(exit).WithExiting(exiting).WithPanicked(panicked)
+						reachableExits[exitNode] = true
+						edges = append(edges, chargedReturns.Edges(exitNode)...)
+					}
+				}
+				return edges
 			}).BFSV(func(node defs.CtrLoc) bool {
 				if node.Node().Function() == cl.Root() {
 					reachesRoot = true
 				}
 				return false
-			}, succ, succ.WithExiting(true))
+			}, succ, succ.WithExiting(true), succ.WithPanicked(true), succ.WithExiting(true).WithPanicked(true))
 
 			if !reachesRoot { // safety check
 				log.Fatalf("Mistakes were made when returning from %s to %s %v", cl, succ, reachableExits)
@@ -555,11 +630,21 @@ func (C AnalysisCtxt) exitSuccs(
 		// If the call instruction is a normal call (not defer), we need
 		// to propagate the return value.
 		if ssaNode, ok := succ.Node().CallRelationNode().(*cfg.SSANode); ok {
+			value := ssaNode.Instruction().(*ssa.Call)
 			if !hasReturnVal {
-				panic(fmt.Errorf("missing return value when returning from %v", n))
+				switch {
+				case cl.Panicked() || cl.Exiting():
+					// The return value is never used when unwinding the stack.
+					returnVal = L.Consts().BotValue()
+				case n.Function().Recover != nil:
+					// The function returns normally after recovering from a panic,
+					// with the current values of its named results.
+					returnVal = L.TopValueForType(value.Type())
+				default:
+					panic(fmt.Errorf("missing return value when returning from %v", n))
+				}
 			}
 
-			value := ssaNode.Instruction().(*ssa.Call)
 			updatedRetState = updatedRetState.UpdateMemory(
 				updatedRetState.Memory().Update(loc.LocationFromSSAValue(g, value), returnVal),
 			)
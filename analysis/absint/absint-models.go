@@ -3,12 +3,16 @@ package absint
 import (
 	T "go/types"
 	"log"
+	"math"
 	"strings"
+	"sync"
 
 	A "github.com/cs-au-dk/goat/analysis/absint/ops"
+	"github.com/cs-au-dk/goat/analysis/cfg"
 	"github.com/cs-au-dk/goat/analysis/defs"
 	L "github.com/cs-au-dk/goat/analysis/lattice"
 	loc "github.com/cs-au-dk/goat/analysis/location"
+	"github.com/cs-au-dk/goat/analysis/upfront/models"
 	"github.com/cs-au-dk/goat/utils"
 
 	"golang.org/x/tools/go/ssa"
@@ -64,6 +68,138 @@ func (C AnalysisCtxt) stdInvoke(g defs.Goro,
 	return mem, false
 }
 
+// Functions that end the program immediately.
+// NOTE (unsound): we ignore the calls to .String() that may happen for
+// the arguments of the log functions.
+var exitFunctions = map[string]struct{}{
+	"os.Exit":               {},
+	"syscall.Exit":          {},
+	"log.Fatal":             {},
+	"log.Fatalf":            {},
+	"log.Fatalln":           {},
+	"(*log.Logger).Fatal":   {},
+	"(*log.Logger).Fatalf":  {},
+	"(*log.Logger).Fatalln": {},
+}
+
+// Results of exitsProgram, by function.
+var exitsProgramCache sync.Map
+
+// exitsProgram returns true if every call to the function ends the program,
+// because every path through the function calls a function that ends the program.
+func exitsProgram(fun *ssa.Function) bool {
+	exits, _ := exitsProgramRec(fun, make(map[*ssa.Function]int))
+	return exits
+}
+
+// exitsProgramRec computes exitsProgram, where visiting maps the functions
+// that are currently being visited to their depth in the call stack.
+// Additionally returns the smallest depth of the functions on the stack that
+// were assumed to return because of recursion. Results that depend on such an
+// assumption for a function deeper in the stack are not cached, since they
+// depend on the order in which functions are visited.
+func exitsProgramRec(fun *ssa.Function, visiting map[*ssa.Function]int) (bool, int) {
+	if _, exits := exitFunctions[fun.String()]; exits {
+		return true, math.MaxInt
+	}
+	if exits, found := exitsProgramCache.Load(fun); found {
+		return exits.(bool), math.MaxInt
+	}
+	// Recursive calls are assumed to return.
+	if depth, found := visiting[fun]; found {
+		return false, depth
+	}
+	if len(fun.Blocks) == 0 {
+		return false, math.MaxInt
+	}
+
+	depth := len(visiting)
+	visiting[fun] = depth
+	defer delete(visiting, fun)
+
+	exits, assumed := exitsProgramBlocks(fun, visiting)
+	if assumed >= depth {
+		exitsProgramCache.Store(fun, exits)
+	}
+	return exits, assumed
+}
+
+func exitsProgramBlocks(fun *ssa.Function, visiting map[*ssa.Function]int) (bool, int) {
+	assumed := math.MaxInt
+	exitBlocks := make(map[*ssa.BasicBlock]bool)
+	for _, block := range fun.Blocks {
+		for _, insn := range block.Instrs {
+			if call, ok := insn.(*ssa.Call); ok {
+				if callee := call.Call.StaticCallee(); callee != nil {
+					exits, a := exitsProgramRec(callee, visiting)
+					if a < assumed {
+						assumed = a
+					}
+					if exits {
+						exitBlocks[block] = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	if len(exitBlocks) == 0 {
+		return false, assumed
+	}
+
+	// Every path from the entry must reach a call that ends the program.
+	// The function may otherwise return, panic, or loop forever without
+	// ending the program.
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make(map[*ssa.BasicBlock]int)
+	var alwaysExits func(block *ssa.BasicBlock) bool
+	alwaysExits = func(block *ssa.BasicBlock) bool {
+		switch {
+		case exitBlocks[block]:
+			return true
+		case state[block] == onStack:
+			// A loop that does not end the program.
+			return false
+		case state[block] == done:
+			return true
+		}
+
+		switch block.Instrs[len(block.Instrs)-1].(type) {
+		case *ssa.Return, *ssa.Panic:
+			return false
+		}
+
+		state[block] = onStack
+		for _, succ := range block.Succs {
+			if !alwaysExits(succ) {
+				return false
+			}
+		}
+		state[block] = done
+		return true
+	}
+
+	return alwaysExits(fun.Blocks[0]), assumed
+}
+
+// exitProgram moves the goroutine at the given control location to a
+// termination node that ends the program.
+func (C AnalysisCtxt) exitProgram(cl defs.CtrLoc, state L.AnalysisState) L.AnalysisIntraprocess {
+	return Elements().AnalysisIntraprocess().Update(
+		cl.Derive(C.LoadRes.Cfg.AddSynthetic(cfg.SynthConfig{
+			Type:             cfg.SynthTypes.TERMINATE_GORO,
+			Function:         cl.Node().Function(),
+			TerminationCause: cfg.GoroTermination.EXIT_PROGRAM,
+		})),
+		state,
+	)
+}
+
 // TODO: too ad-hoc...
 func (C AnalysisCtxt) stdCall(
 	g defs.Goro, cl defs.CtrLoc,
@@ -95,8 +231,9 @@ func (C AnalysisCtxt) stdCall(
 
 	mem := state.Memory()
 
-	// Used by time.NewTimer and time.NewTicker
-	constructTimer := func() (L.AnalysisIntraprocess, bool) {
+	// Used by time.NewTimer, time.NewTicker and time.AfterFunc.
+	// The channel of the timer is allocated at the given site.
+	constructTimer := func(chSite loc.AllocationSiteLocation) (L.AnalysisIntraprocess, bool) {
 		namedTimerType := call.Value().Type().(*T.Pointer).Elem().(*T.Named)
 		timerType := namedTimerType.Underlying().(*T.Struct)
 		timerVal := L.ZeroValueForType(timerType)
@@ -118,35 +255,18 @@ func (C AnalysisCtxt) stdCall(
 			)
 		}
 
-		for i := 0; i < timerType.NumFields(); i++ {
-			// Find the field named C (which contains the timer channel)
-			if field := timerType.Field(i); field.Name() == "C" {
-				payloadType := field.Type().(*T.Chan).Elem()
-				// Put a zero-payload into the channel
-				chVal = chVal.Update(chVal.ChanValue().UpdatePayload(
-					L.ZeroValueForType(payloadType),
-				))
-
-				// Put a pointer to the channel into the struct
-				mkChan, found := utils.FindSSAInstruction(fun, func(insn ssa.Instruction) bool {
-					_, ok := insn.(*ssa.MakeChan)
-					return ok
-				})
-				if !found {
-					log.Fatalln("???")
-				}
-
-				mops := L.MemOps(mem)
+		if i, found := timerChanField(timerType); found {
+			payloadType := timerType.Field(i).Type().(*T.Chan).Elem()
+			// Put a zero-payload into the channel
+			chVal = chVal.Update(chVal.ChanValue().UpdatePayload(
+				L.ZeroValueForType(payloadType),
+			))
 
-				chPtr := mops.HeapAlloc(loc.AllocationSiteLocation{
-					Goro:    g,
-					Context: fun,
-					Site:    mkChan.(*ssa.MakeChan),
-				}, chVal)
-				timerVal = timerVal.Update(timerVal.StructValue().Update(i, chPtr))
-				mem = mops.Memory()
-				break
-			}
+			// Put a pointer to the channel into the struct
+			mops := L.MemOps(mem)
+			chPtr := mops.HeapAlloc(chSite, chVal)
+			timerVal = timerVal.Update(timerVal.StructValue().Update(i, chPtr))
+			mem = mops.Memory()
 		}
 
 		mops := L.MemOps(mem)
@@ -154,6 +274,24 @@ func (C AnalysisCtxt) stdCall(
 		return updMem(mops.Memory().Update(callLoc, ptr))
 	}
 
+	// The channels of timers and tickers are allocated at the make instruction
+	// in the constructor.
+	makeChanSite := func() loc.AllocationSiteLocation {
+		mkChan, found := utils.FindSSAInstruction(fun, func(insn ssa.Instruction) bool {
+			_, ok := insn.(*ssa.MakeChan)
+			return ok
+		})
+		if !found {
+			log.Fatalln("???")
+		}
+
+		return loc.AllocationSiteLocation{
+			Goro:    g,
+			Context: fun,
+			Site:    mkChan.(*ssa.MakeChan),
+		}
+	}
+
 	constructCond := func() (L.AnalysisIntraprocess, bool) {
 		if len(call.Common().Args) != 1 {
 			panic("what?")
@@ -172,6 +310,10 @@ func (C AnalysisCtxt) stdCall(
 	}
 
 	funName := fun.String()
+	if _, exits := exitFunctions[funName]; exits {
+		return C.exitProgram(cl, state), true
+	}
+
 	switch funName {
 	case "time.After":
 		val := makeChannelValue(
@@ -186,10 +328,21 @@ func (C AnalysisCtxt) stdCall(
 			val.Update(ch.UpdatePayload(L.ZeroValueForType(payloadType))))
 		return updMem(mops.Memory().Update(callLoc, ptr))
 
-	case "time.NewTimer":
-		return constructTimer()
-	case "time.NewTicker":
-		return constructTimer()
+	case "time.NewTimer", "time.NewTicker":
+		return constructTimer(makeChanSite())
+	case "time.AfterFunc":
+		// The channel of the timer is allocated at the call site, since
+		// AfterFunc timers have no channel. The goroutine running f is spawned
+		// by the upfront rewriting in the timers package, and waits for a
+		// value on this channel.
+		return constructTimer(allocSite)
+	case "(*time.Timer).Stop", "(*time.Timer).Reset",
+		"(*time.Ticker).Stop", "(*time.Ticker).Reset":
+		mem, result := C.stopOrResetTimer(g, mem, call.Common().Args[0], fun.Name() == "Reset")
+		if call.Value() != nil && fun.Signature.Results().Len() == 1 {
+			mem = mem.Update(callLoc, result)
+		}
+		return updMem(mem)
 	case "(*sync.RWMutex).RLocker":
 		if !utils.Opts().SkipSync() {
 			lockVal := evaluateSSA(g, mem, call.Common().Args[0])
@@ -253,6 +406,32 @@ func (C AnalysisCtxt) stdCall(
 		), true
 	}
 
+	// User-supplied models of functions returning fresh channels.
+	// Other user-supplied models are implemented by rewriting the function body.
+	if m, found := models.Lookup(fun); found && m.Returns != nil {
+		cv := call.Value()
+		if cv == nil {
+			return updMem(mem)
+		}
+
+		// The signature is checked when the models are applied, see models.Rewrite.
+		chType, ok := cv.Type().Underlying().(*T.Chan)
+		if !ok {
+			log.Fatalf("Model for %s returns a channel, but the function returns %s", funName, cv.Type())
+		}
+
+		val := makeChannelValue(
+			Elements().FlatInt(m.Returns.Capacity),
+			!m.Returns.Closed,
+			0,
+		)
+		ch := val.ChanValue()
+		mops := L.MemOps(mem)
+		ptr := mops.HeapAlloc(allocSite,
+			val.Update(ch.UpdatePayload(L.ZeroValueForType(chType.Elem()))))
+		return updMem(mops.Memory().Update(callLoc, ptr))
+	}
+
 	// Implement models by a by-need basis but let us know if we need one.
 	if strings.HasPrefix(funName, "(*sync/atomic.Value).") {
 		log.Fatalf("Missing model for %s", funName)
@@ -261,6 +440,121 @@ func (C AnalysisCtxt) stdCall(
 	return rsuccs, false
 }
 
+// timerChanField returns the index of the field C of time.Timer or time.Ticker.
+func timerChanField(timerType *T.Struct) (int, bool) {
+	for i := 0; i < timerType.NumFields(); i++ {
+		if timerType.Field(i).Name() == "C" {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// isAfterFuncChan returns true if the channel location was allocated by the
+// model of time.AfterFunc.
+func isAfterFuncChan(l loc.Location) bool {
+	site, ok := l.(loc.AllocationSiteLocation)
+	if !ok {
+		return false
+	}
+	call, ok := site.Site.(*ssa.Call)
+	if !ok {
+		return false
+	}
+	callee := call.Call.StaticCallee()
+	return callee != nil && callee.String() == "time.AfterFunc"
+}
+
+// stopOrResetTimer models Stop and Reset on a *time.Timer or *time.Ticker by
+// updating the channel of the timer. It returns the updated memory and the
+// result of calling the method on a Timer.
+//
+// A timer channel with a value in the buffer is either pending or has fired
+// without being drained, and Stop or Reset may return either true or false.
+// With an empty buffer the timer has fired and been drained, and they return
+// false. Stop leaves the buffer of a pending timer empty, while Reset makes the
+// timer pending. The "Stop returned false, but the channel was already drained"
+// hang therefore shows up as a receive from a channel with an empty buffer.
+//
+// Stopping a pending AfterFunc timer closes its channel, which tells the
+// goroutine waiting to call the function to exit instead.
+//
+// Tickers are modelled as closed channels delivering infinitely many values.
+// Stop opens the channel again with an empty buffer, so later receives block,
+// while Reset restores the closed channel.
+func (C AnalysisCtxt) stopOrResetTimer(
+	g defs.Goro, mem L.Memory, timer ssa.Value, reset bool,
+) (L.Memory, L.AbstractValue) {
+	TRUE, FALSE := L.Consts().AbstractBasicBooleans()
+	OPEN, CLOSED := L.Consts().ForChanStatus()
+
+	timerType := timer.Type().Underlying().(*T.Pointer).Elem().Underlying().(*T.Struct)
+	field, found := timerChanField(timerType)
+	if !found {
+		log.Fatalln("Timer without a channel?", timer.Type())
+	}
+	isTicker := utils.IsNamedType(timer.Type().(*T.Pointer).Elem(), "time", "Ticker")
+
+	wrapped, mem, _ := C.wrapPointers(g, mem, timer, field)
+	chPtrs := A.Load(wrapped, mem)
+	if !chPtrs.IsPointer() {
+		// The channel is unknown, so the result is as well.
+		return mem, TRUE.MonoJoin(FALSE)
+	}
+
+	result := L.Consts().BotValue()
+	mops := L.MemOps(mem)
+	isWeak := !mops.CanStrongUpdate(chPtrs.PointerValue())
+	for _, l := range chPtrs.PointerValue().NonNilEntries() {
+		val := mops.GetUnsafe(l)
+		if !val.IsChan() {
+			result = result.MonoJoin(TRUE).MonoJoin(FALSE)
+			continue
+		}
+		ch := val.ChanValue()
+
+		switch {
+		case isTicker && reset:
+			ch = ch.UpdateStatus(CLOSED)
+		case isTicker:
+			ch = ch.UpdateStatus(OPEN)
+		case reset:
+			if ch.BufferFlat().Is(0) {
+				result = result.MonoJoin(FALSE)
+			} else {
+				result = result.MonoJoin(TRUE).MonoJoin(FALSE)
+			}
+			ch = ch.UpdateStatus(OPEN)
+		case ch.BufferFlat().Is(0):
+			result = result.MonoJoin(FALSE)
+		default:
+			// The timer may be pending, in which case Stop returns true and
+			// the timer never fires, or it may have fired, in which case Stop
+			// returns false and the value remains in the buffer.
+			result = result.MonoJoin(TRUE).MonoJoin(FALSE)
+			if isAfterFuncChan(l) && ch.Status().Eq(OPEN) {
+				ch = ch.UpdateStatus(Lattices().ChannelInfo().Status().Top().Flat())
+			}
+		}
+
+		switch {
+		case isTicker:
+			ch = ch.UpdateBufferFlat(Elements().FlatInt(0)).
+				UpdateBufferInterval(Elements().IntervalFinite(0, 0))
+		case reset:
+			ch = ch.UpdateBufferFlat(Elements().FlatInt(1)).
+				UpdateBufferInterval(Elements().IntervalFinite(1, 1))
+		case !ch.BufferFlat().Is(0):
+			ch = ch.UpdateBufferFlat(Lattices().FlatInt().Top().Flat()).
+				UpdateBufferInterval(Elements().IntervalFinite(0, 1))
+		}
+
+		mops.UpdateW(l, val.Update(ch), isWeak)
+	}
+
+	return mops.Memory(), result
+}
+
 func spoofCall(g defs.Goro, call ssa.CallInstruction, mem L.Memory) L.Memory {
 	opts.OnVerbose(func() {
 		log.Println("Spoofing call:", call, "in", call.Parent())
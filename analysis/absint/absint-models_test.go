@@ -0,0 +1,114 @@
+package absint
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestExitsProgram(t *testing.T) {
+	const src = `package main
+
+	import (
+		"log"
+		"os"
+	)
+
+	func die() { os.Exit(1) }
+
+	func check(err error) {
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	func fatal(format string) {
+		log.Printf(format)
+		die()
+	}
+
+	func loop(n int) {
+		if n > 0 {
+			loop(n - 1)
+		}
+		die()
+	}
+
+	func spin(c bool) {
+		for {
+			if c {
+				os.Exit(1)
+			}
+		}
+	}
+
+	func crash(bad bool) {
+		if bad {
+			os.Exit(1)
+		}
+		panic("crash")
+	}
+
+	func retry(ok func() bool) {
+		for !ok() {
+		}
+		os.Exit(0)
+	}
+
+	func halt(n int) {
+		stop(n)
+	}
+
+	func stop(n int) {
+		if n > 0 {
+			halt(n - 1)
+		}
+		os.Exit(1)
+	}
+
+	func main() {}
+	`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, _, err := ssautil.BuildPackage(
+		&types.Config{Importer: importer.ForCompiler(fset, "source", nil)},
+		fset, types.NewPackage("main", ""), []*ast.File{file}, ssa.SanityCheckFunctions,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// While visiting stop, the recursive call to stop from halt is assumed to
+	// return, which must not be cached as the result for halt.
+	for _, name := range []string{"stop", "halt"} {
+		if !exitsProgram(pkg.Func(name)) {
+			t.Errorf("Expected exitsProgram(%s) to be true", name)
+		}
+	}
+
+	for name, expected := range map[string]bool{
+		"die":   true,
+		"check": false,
+		"fatal": true,
+		"loop":  true,
+		"spin":  false,
+		"crash": false,
+		"retry": false,
+		"main":  false,
+	} {
+		if actual := exitsProgram(pkg.Func(name)); actual != expected {
+			t.Errorf("Expected exitsProgram(%s) to be %v, got %v", name, expected, actual)
+		}
+	}
+}
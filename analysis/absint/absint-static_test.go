@@ -78,7 +78,7 @@ func runTest(
 	goroToClCount := utils.NewImmMap[defs.Goro, *immutable.Map[defs.CtrLoc, int]]()
 	visited := 0
 	S.ForEach(func(conf *AbsConfiguration) {
-		if conf.IsPanicked() || !conf.IsSynchronizing(C, result.GetUnsafe(conf.Superlocation())) {
+		if conf.IsCrashed() || !conf.IsSynchronizing(C, result.GetUnsafe(conf.Superlocation())) {
 			return
 		}
 
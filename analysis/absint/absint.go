@@ -440,8 +440,8 @@ func (s *AbsConfiguration) singleSilent(C AnalysisCtxt, g defs.Goro, cl defs.Ctr
 	case *cfg.FunctionExit:
 		succs = C.exitSuccs(g, cl, initState)
 	case *cfg.PostCall:
-		if cl.Exiting() {
-			// If the exiting flag is set we should immediately begin processing deferred calls.
+		if cl.Exiting() || cl.Panicked() {
+			// If the exiting or panicked flag is set we should immediately begin processing deferred calls.
 			// We set the return value to bottom to avoid crashes when looking it up.
 			succs = succs.Update(
 				cl.Derive(n.PanicCont()),
@@ -456,13 +456,16 @@ func (s *AbsConfiguration) singleSilent(C AnalysisCtxt, g defs.Goro, cl defs.Ctr
 	case *cfg.BuiltinCall:
 		switch n.Builtin().Name() {
 		case "recover":
-			// Optimistically assume built-in recover calls are always performed
-			// when not panicking, making the results nil. Any branching on the
-			// recover pointer will resolve to the nil branch
-			singleUpd(initMem.Update(
-				loc.LocationFromSSAValue(g, n.Call.Value()),
-				L.Elements().AbstractPointerV(loc.NilLocation{}),
-			))
+			// Built-in recover calls only stop a panic in deferred calls made
+			// while panicking. Elsewhere the result is nil, and any branching on
+			// the recover pointer will resolve to the nil branch.
+			rval := L.Elements().AbstractPointerV(loc.NilLocation{})
+			if cl.Recovering() {
+				// The panic value is unknown, and it is nil if the panic was
+				// already recovered by an earlier call to recover.
+				rval = L.TopValueForType(n.Call.Value().Type())
+			}
+			singleUpd(initMem.Update(loc.LocationFromSSAValue(g, n.Call.Value()), rval))
 		case "append":
 			// BaseV contains a set of pointers to possible base arrays
 			slice, apps := n.Args()[0], n.Args()[1]
@@ -586,11 +589,14 @@ func (s *AbsConfiguration) singleSilent(C AnalysisCtxt, g defs.Goro, cl defs.Ctr
 			// 	select {}
 			// the defer operation will not have a defer link.
 			if dfr := n.DeferLink(); dfr != nil {
-				// Charge deferlink in both exiting and non-exiting states
+				// Charge deferlink in both exiting and non-exiting, and in both
+				// panicked and non-panicked states
 				for pred := range dfr.Predecessors() {
 					for _, exiting := range [...]bool{false, true} {
-						from := cl.Derive(pred).WithExiting(exiting)
-						newState = newState.AddCharge(g, from, from.Derive(dfr))
+						for _, panicked := range [...]bool{false, true} {
+							from := cl.Derive(pred).WithExiting(exiting).WithPanicked(panicked)
+							newState = newState.AddCharge(g, from, from.Derive(dfr))
+						}
 					}
 				}
 			}
@@ -86,7 +86,9 @@ func TestAbstractInterpretation(t *testing.T) {
 			succs := ctxt.InitConf.GetTransitions(ctxt, ctxt.InitState)
 			// Filter out panicked successors
 			for key, succ := range succs {
-				if succ.Configuration().IsPanicked() {
+				if _, _, panicked := succ.Configuration().Superlocation().Find(func(_ defs.Goro, cl defs.CtrLoc) bool {
+					return cl.Panicked()
+				}); panicked {
 					// TODO: Let caller control whether panicking is allowed?
 					delete(succs, key)
 				}
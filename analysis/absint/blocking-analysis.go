@@ -3,10 +3,12 @@ package absint
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/cs-au-dk/goat/analysis/absint/ops"
 	"github.com/cs-au-dk/goat/analysis/cfg"
 	"github.com/cs-au-dk/goat/analysis/defs"
+	"github.com/cs-au-dk/goat/analysis/gotopo"
 	L "github.com/cs-au-dk/goat/analysis/lattice"
 	loc "github.com/cs-au-dk/goat/analysis/location"
 	"github.com/cs-au-dk/goat/analysis/transition"
@@ -27,7 +29,12 @@ func (o Blocks) String() string {
 			continue
 		}
 
-		str += "Potential blocked goroutine at superlocation: " + sl.String() + "\n"
+		if hasExited(sl) {
+			// The program ended while the goroutine was blocked.
+			str += "Goroutine leaked at program exit at superlocation: " + sl.String() + "\n"
+		} else {
+			str += "Potential blocked goroutine at superlocation: " + sl.String() + "\n"
+		}
 		for g := range gs {
 			cl := sl.GetUnsafe(g)
 			str += fmt.Sprintf("Goroutine: %s\nControl location: %s\nSource: %s\n",
@@ -40,58 +47,94 @@ func (o Blocks) String() string {
 	return str
 }
 
-func (o Blocks) PrintPath(G SuperlocGraph, A L.Analysis, g graph.Graph[*ssa.Function]) {
-	// Print shortest path to blocking configuration
-	for sl := range o {
-
-		type link struct {
-			transition transition.Transition
-			sl         defs.Superloc
-		}
+// pathStep is a superlocation on the shortest path to a blocked superlocation,
+// and the transition taken from it to the next superlocation on the path.
+// The transition of the last step is nil.
+type pathStep struct {
+	sl         defs.Superloc
+	transition transition.Transition
+}
 
-		preds := make(map[defs.Superloc]link)
+// shortestPath computes the shortest path from the entry of the superlocation graph to sl.
+func shortestPath(G SuperlocGraph, sl defs.Superloc) []pathStep {
+	preds := make(map[defs.Superloc]pathStep)
 
-		G.ToGraph().BFS(G.Entry(), func(next *AbsConfiguration) bool {
-			nextSl := next.superloc
+	G.ToGraph().BFS(G.Entry(), func(next *AbsConfiguration) bool {
+		nextSl := next.superloc
 
-			if sl.Equal(nextSl) {
-				return true
-			}
+		if sl.Equal(nextSl) {
+			return true
+		}
 
-			for _, succ := range next.Successors {
-				if _, ok := preds[succ.configuration.superloc]; !ok {
-					preds[succ.configuration.superloc] = link{
-						succ.transition,
-						nextSl,
-					}
+		for _, succ := range next.Successors {
+			if _, ok := preds[succ.configuration.superloc]; !ok {
+				preds[succ.configuration.superloc] = pathStep{
+					nextSl,
+					succ.transition,
 				}
 			}
+		}
 
-			return false
-		})
+		return false
+	})
 
-		path := []link{{nil, sl}}
+	path := []pathStep{{sl, nil}}
+	for pred, ok := preds[sl]; ok; pred, ok = preds[pred.sl] {
+		path = append(path, pred)
+	}
 
-		for pred, ok := preds[sl]; ok; pred, ok = preds[pred.sl] {
-			path = append(path, pred)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// calleeChain computes the shortest chain of calls from one function to another in the call graph.
+// The chain starts with from and ends with to.
+func calleeChain(g graph.Graph[*ssa.Function], from, to *ssa.Function) []*ssa.Function {
+	fpreds := map[*ssa.Function]*ssa.Function{}
+
+	g.BFS(from, func(f *ssa.Function) bool {
+		if f == to {
+			return true
 		}
 
+		for _, e := range g.Edges(f) {
+			if _, ok := fpreds[e]; !ok && e != from {
+				fpreds[e] = f
+			}
+		}
+
+		return false
+	})
+
+	chain := []*ssa.Function{to}
+	for pred, ok := fpreds[to]; ok; pred, ok = fpreds[pred] {
+		chain = append(chain, pred)
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+func (o Blocks) PrintPath(G SuperlocGraph, A L.Analysis, g graph.Graph[*ssa.Function]) {
+	// Print shortest path to blocking configuration
+	for sl := range o {
+		path := shortestPath(G, sl)
+
 		fmt.Println(color.RedString("Blocking path:"))
-		for i := len(path) - 1; i >= 0; i-- {
-			chMem := L.MemOps(A.GetUnsafe(path[i].sl).Memory().Channels())
+		for i, step := range path {
+			chMem := L.MemOps(A.GetUnsafe(step.sl).Memory().Channels())
 			printCh := func(c loc.Location) {
 				if ch, ok := chMem.Get(c); ok {
 					fmt.Println("|", ch)
 				}
 			}
 
-			// chMem.Memory().ForEach(func(al loc.AddressableLocation, av L.AbstractValue) {
-			// 	fmt.Println(al)
-			// 	fmt.Println(al.Position())
-			// 	fmt.Println(av)
-			// })
-			fmt.Println(path[i].sl.StringWithPos())
-			if t := path[i].transition; t != nil {
+			fmt.Println(step.sl.StringWithPos())
+			if t := step.transition; t != nil {
 				fmt.Println("|")
 				fmt.Println("|>", t)
 
@@ -110,33 +153,12 @@ func (o Blocks) PrintPath(G SuperlocGraph, A L.Analysis, g graph.Graph[*ssa.Func
 					fmt.Println("|")
 				case transition.In:
 					// Get the shortest callee path for internal transitions:
-					from := path[i].sl.GetUnsafe(t.Progressed()).Node().Function()
-					to := path[i-1].sl.GetUnsafe(t.Progressed()).Node().Function()
-
-					fpreds := map[*ssa.Function]*ssa.Function{}
-
-					g.BFS(from, func(f *ssa.Function) bool {
-						if f == to {
-							return true
-						}
-
-						for _, e := range g.Edges(f) {
-							if _, ok := fpreds[e]; !ok && e != from {
-								fpreds[e] = f
-							}
-						}
+					from := step.sl.GetUnsafe(t.Progressed()).Node().Function()
+					to := path[i+1].sl.GetUnsafe(t.Progressed()).Node().Function()
 
-						return false
-					})
-
-					path := []*ssa.Function{to}
-					for pred, ok := fpreds[to]; ok; pred, ok = fpreds[pred] {
-						path = append(path, pred)
-					}
-
-					for i := len(path) - 1; i >= 0; i-- {
-						fmt.Println("  |", path[i])
-						fmt.Println("   ", path[i].Prog.Fset.Position(path[i].Pos()))
+					for _, f := range calleeChain(g, from, to) {
+						fmt.Println("  |", f)
+						fmt.Println("   ", f.Prog.Fset.Position(f.Pos()))
 					}
 					fmt.Println("|")
 				}
@@ -151,6 +173,26 @@ func (o Blocks) Log() {
 	fmt.Println(o.String())
 }
 
+// CtrLocs returns the distinct control locations of blocked goroutines,
+// ordered by their string representation.
+func (o Blocks) CtrLocs() []defs.CtrLoc {
+	seen := map[defs.CtrLoc]bool{}
+	cls := []defs.CtrLoc{}
+	for sl, gs := range o {
+		for g := range gs {
+			if cl := sl.GetUnsafe(g); !seen[cl] {
+				seen[cl] = true
+				cls = append(cls, cl)
+			}
+		}
+	}
+
+	sort.Slice(cls, func(i, j int) bool {
+		return cls[i].String() < cls[j].String()
+	})
+	return cls
+}
+
 func (o Blocks) register(sl defs.Superloc, g defs.Goro) {
 	if _, found := o[sl]; !found {
 		o[sl] = make(map[defs.Goro]struct{})
@@ -179,6 +221,35 @@ func (o Blocks) Find(pred func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool
 	return defs.Superloc{}, nil, false
 }
 
+// Filter returns the blocked goroutines for which keep returns true.
+func (o Blocks) Filter(keep func(sl defs.Superloc, g defs.Goro) bool) Blocks {
+	res := make(Blocks)
+	for sl, gs := range o {
+		for g := range gs {
+			if keep(sl, g) {
+				res.register(sl, g)
+			}
+		}
+	}
+	return res
+}
+
+// Deadlocks returns the goroutines that are blocked forever while the
+// program is running.
+func (o Blocks) Deadlocks() Blocks {
+	return o.Filter(func(sl defs.Superloc, _ defs.Goro) bool {
+		return !hasExited(sl)
+	})
+}
+
+// Leaks returns the goroutines that are blocked when the program exits.
+// They are leaked, but do not prevent the program from ending.
+func (o Blocks) Leaks() Blocks {
+	return o.Filter(func(sl defs.Superloc, _ defs.Goro) bool {
+		return hasExited(sl)
+	})
+}
+
 // UpdateWith joins the results of `other` into `o`.
 func (o Blocks) UpdateWith(other Blocks) {
 	for sl, ogs := range other {
@@ -360,15 +431,35 @@ func BlockAnalysisFiltered(
 		guaranteedPanic[ci] = bad
 	}
 
-	G.ForEach(func(conf *AbsConfiguration) {
+	check := func(conf *AbsConfiguration) {
 		analysis := result.GetUnsafe(conf.Superlocation())
 
-		// Skip checking for orphans in configurations where a goroutine has
-		// panicked and in non-synchronizing configurations.
-		if conf.IsPanicked() || guaranteedPanic[scc.ComponentOf(conf)] || !conf.IsSynchronizing(C, analysis) {
+		// Skip checking for orphans in configurations where the program has
+		// crashed and in non-synchronizing configurations.
+		if conf.IsCrashed() || guaranteedPanic[scc.ComponentOf(conf)] || !conf.IsSynchronizing(C, analysis) {
 			return
 		}
 
+		// Goroutines that could synchronize if the program had not exited
+		// were stopped by the exit, and are not leaked.
+		stopped := make(map[defs.Goro]bool)
+		if conf.IsExited() {
+			// A goroutine that was still running when the program exited
+			// could have released any of the goroutines at communication
+			// operations, so none of them are reported as leaked.
+			if conf.nextSilentProgress(C, analysis) != nil {
+				return
+			}
+
+			for _, succ := range conf.getCommTransitions(C, analysis) {
+				succ.Configuration().ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+					if prev, found := conf.Get(g); found && !prev.Equal(cl) {
+						stopped[g] = true
+					}
+				})
+			}
+		}
+
 		// Filter out blocking bugs in configurations where a goroutine has
 		// deadlocked in the context package as these are (most likely) false positives
 		if _, _, deadLockInContext := conf.Superlocation().Find(func(g defs.Goro, cl defs.CtrLoc) bool {
@@ -380,7 +471,13 @@ func BlockAnalysisFiltered(
 		// Check if there is a goroutine at a communication operation which can never progress
 		conf.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
 			// Terminated goroutines are not buggy
-			if isTerminated(cl) {
+			if isTerminated(cl) || stopped[g] {
+				return
+			}
+
+			// The main function of a generated harness only waits for the
+			// goroutines calling the library, which are reported if they block.
+			if C.GeneratedHarness && g.IsRoot() && cl.Node().Function() == cl.Root() {
 				return
 			}
 
@@ -405,8 +502,8 @@ func BlockAnalysisFiltered(
 				ok := false
 			OUT:
 				for _, reg := range cfg.CommunicationPrimitivesOf(cl.Node()) {
-					for _, lab := range C.LoadRes.Pointer.Queries[reg].PointsTo().Labels() {
-						if C.IsPrimitiveFocused(lab.Value()) {
+					for prim := range gotopo.PrimitivesOf(reg, C.LoadRes.Pointer) {
+						if C.IsPrimitiveFocused(prim) {
 							ok = true
 							break OUT
 						}
@@ -430,6 +527,19 @@ func BlockAnalysisFiltered(
 				prevFound[cl] = struct{}{}
 			}
 		})
+	}
+
+	// Check configurations where the program has exited last, such that
+	// goroutines that may also block before the exit are reported as such.
+	G.ForEach(func(conf *AbsConfiguration) {
+		if !conf.IsExited() {
+			check(conf)
+		}
+	})
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsExited() {
+			check(conf)
+		}
 	})
 
 	return
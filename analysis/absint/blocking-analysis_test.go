@@ -717,6 +717,181 @@ func TestBlockingAnalysis(t *testing.T) {
 			}`,
 			BlockAnalysisTest,
 		},
+		{
+			"timer-stop-not-drained",
+			`import "time"
+			func main() {
+				t := time.NewTimer(time.Second)
+				<-t.C //@ releases
+				if !t.Stop() {
+					<-t.C //@ blocks
+				}
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"timer-stop-drain",
+			`import "time"
+			func main() {
+				t := time.NewTimer(time.Second)
+				if !t.Stop() {
+					<-t.C //@ releases
+				}
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"timer-reset",
+			`import "time"
+			func main() {
+				t := time.NewTimer(time.Second)
+				<-t.C //@ releases
+				t.Reset(time.Second)
+				<-t.C //@ releases
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"ticker-stop",
+			`import "time"
+			func main() {
+				tk := time.NewTicker(time.Second)
+				<-tk.C //@ releases
+				tk.Stop()
+				<-tk.C //@ blocks
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"recover-continues",
+			`func mayPanic() {
+				defer func() { recover() }()
+				panic("oh no")
+			}
+			func main() {
+				ch := make(chan int)
+				go func() {
+					mayPanic()
+					ch <- 10 //@ releases
+				}()
+				<-ch //@ releases
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"defer-while-panicking",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					defer func() {
+						ch <- 10 //@ releases
+					}()
+					panic("oh no")
+				}()
+				<-ch //@ releases
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"exit-leaks",
+			`import "os"
+			func fail() {
+				os.Exit(1)
+			}
+			func ubool() bool
+			func main() {
+				ch := make(chan int)
+				go func() {
+					ch <- 10 //@ blocks
+				}()
+				if ubool() {
+					fail()
+				}
+				<-ch //@ releases
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"exit-stops-synchronizing-goroutines",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					ch <- 10 //@ releases
+				}()
+				go func() {
+					<-ch //@ releases
+				}()
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			"exit-stops-silent-progress",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					x := 0
+					for i := 0; i < 10; i++ {
+						x++
+					}
+					ch <- x
+				}()
+			}`,
+			func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+				G.ForEach(func(conf *AbsConfiguration) {
+					if conf.IsExited() && len(conf.Successors) > 0 {
+						t.Errorf("Expected no transitions after the program exited at %v", conf)
+					}
+				})
+			},
+		},
+		{
+			"unrecovered-panic-crashes",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					<-ch //@ releases
+					panic("oh no")
+				}()
+				ch <- 10 //@ releases
+				<-ch //@ releases
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			// The send is only reached if recover stops the panic.
+			"recover-reaches-blocked",
+			`func mayPanic() {
+				defer func() { recover() }()
+				panic("oh no")
+			}
+			func main() {
+				ch := make(chan int)
+				done := make(chan int)
+				go func() {
+					mayPanic()
+					ch <- 10 //@ blocks
+				}()
+				<-done //@ blocks
+			}`,
+			BlockAnalysisTest,
+		},
+		{
+			// A goroutine that is unwinding its defer stack has not crashed
+			// the program yet, so it may block in a deferred call.
+			"blocked-while-panicking",
+			`func main() {
+				ch := make(chan int)
+				done := make(chan int)
+				go func() {
+					defer func() {
+						ch <- 10 //@ blocks
+					}()
+					panic("oh no")
+				}()
+				<-done //@ blocks
+			}`,
+			BlockAnalysisTest,
+		},
 		{
 			// See TODO in absint of FunctionExit
 			"[disabled] comm-separated-calls",
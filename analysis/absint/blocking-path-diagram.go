@@ -0,0 +1,248 @@
+package absint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	"github.com/cs-au-dk/goat/analysis/transition"
+	"github.com/cs-au-dk/goat/utils/graph"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Formats of sequence diagrams of blocking paths.
+const (
+	PATH_FORMAT_MERMAID  = "mermaid"
+	PATH_FORMAT_PLANTUML = "plantuml"
+)
+
+// sequenceDiagram abstracts over the syntax of a sequence diagram language.
+type sequenceDiagram interface {
+	begin(title string) string
+	participant(id, label string) string
+	message(from, to, text string) string
+	note(over []string, text string) string
+	end() string
+}
+
+type mermaidDiagram struct{}
+
+func (mermaidDiagram) begin(title string) string {
+	return "sequenceDiagram\n    title " + mermaidEscape(title)
+}
+
+func (mermaidDiagram) participant(id, label string) string {
+	return fmt.Sprintf("    participant %s as %s", id, mermaidEscape(label))
+}
+
+func (mermaidDiagram) message(from, to, text string) string {
+	return fmt.Sprintf("    %s->>%s: %s", from, to, mermaidEscape(text))
+}
+
+func (mermaidDiagram) note(over []string, text string) string {
+	return fmt.Sprintf("    Note over %s: %s", strings.Join(over, ","), mermaidEscape(text))
+}
+
+func (mermaidDiagram) end() string {
+	return ""
+}
+
+// Mermaid uses ; and # for statement separators and entity codes,
+// and interprets line breaks as the end of a statement.
+func mermaidEscape(text string) string {
+	return strings.NewReplacer(
+		"#", "#35;",
+		";", "#59;",
+		"\n", "<br/>",
+	).Replace(text)
+}
+
+type plantUMLDiagram struct{}
+
+func (plantUMLDiagram) begin(title string) string {
+	return "@startuml\ntitle " + plantUMLEscape(title)
+}
+
+func (plantUMLDiagram) participant(id, label string) string {
+	return fmt.Sprintf("participant \"%s\" as %s", strings.ReplaceAll(plantUMLEscape(label), `"`, "'"), id)
+}
+
+func (plantUMLDiagram) message(from, to, text string) string {
+	return fmt.Sprintf("%s -> %s : %s", from, to, plantUMLEscape(text))
+}
+
+func (plantUMLDiagram) note(over []string, text string) string {
+	return fmt.Sprintf("note over %s : %s", strings.Join(over, ", "), plantUMLEscape(text))
+}
+
+func (plantUMLDiagram) end() string {
+	return "@enduml"
+}
+
+func plantUMLEscape(text string) string {
+	return strings.ReplaceAll(text, "\n", "\\n")
+}
+
+// PathDiagrams renders the shortest path to every blocked superlocation as a
+// sequence diagram in the given format, where every goroutine is a lifeline.
+// Synchronizations between goroutines are messages, and operations of a single
+// goroutine are notes on its lifeline, with the source position of the operation.
+// Internal steps are collapsed into the chain of calls that leads from the
+// function of the goroutine before the step to its function after the step,
+// computed with the call graph g.
+func (o Blocks) PathDiagrams(G SuperlocGraph, g graph.Graph[*ssa.Function], format string) (string, error) {
+	var diagram sequenceDiagram
+	switch format {
+	case PATH_FORMAT_MERMAID:
+		diagram = mermaidDiagram{}
+	case PATH_FORMAT_PLANTUML:
+		diagram = plantUMLDiagram{}
+	default:
+		return "", fmt.Errorf("unknown path format %q", format)
+	}
+
+	sls := make([]defs.Superloc, 0, len(o))
+	for sl, gs := range o {
+		if len(gs) > 0 {
+			sls = append(sls, sl)
+		}
+	}
+	sort.Slice(sls, func(i, j int) bool {
+		return sls[i].String() < sls[j].String()
+	})
+
+	diagrams := make([]string, 0, len(sls))
+	for i, sl := range sls {
+		title := fmt.Sprintf("Blocking path %d of %d", i+1, len(sls))
+		diagrams = append(diagrams, pathDiagram(diagram, title, shortestPath(G, sl), o[sl], g))
+	}
+
+	return strings.Join(diagrams, "\n\n"), nil
+}
+
+func pathDiagram(
+	diagram sequenceDiagram,
+	title string,
+	path []pathStep,
+	blocked map[defs.Goro]struct{},
+	g graph.Graph[*ssa.Function],
+) string {
+	last := path[len(path)-1].sl
+
+	// Goroutines get a lifeline in the order of their first appearance on the path.
+	ids := map[defs.Goro]string{}
+	goros := []defs.Goro{}
+	for _, step := range path {
+		step.sl.ForEach(func(g defs.Goro, _ defs.CtrLoc) {
+			for _, g2 := range goros {
+				if g2.Equal(g) {
+					return
+				}
+			}
+			goros = append(goros, g)
+		})
+	}
+	// ForEach visits goroutines in an unspecified order, so parents
+	// are placed before the goroutines they spawn.
+	sort.SliceStable(goros, func(i, j int) bool {
+		return goros[i].Length() < goros[j].Length()
+	})
+
+	id := func(g defs.Goro) string {
+		for g2, id := range ids {
+			if g2.Equal(g) {
+				return id
+			}
+		}
+		return "unknown"
+	}
+
+	plain := func(s fmt.Stringer) string {
+		return ansiEscape.ReplaceAllString(s.String(), "")
+	}
+
+	// Source position of the operation a goroutine performs at a superlocation.
+	at := func(sl defs.Superloc, g defs.Goro) string {
+		if cl, ok := sl.Get(g); ok {
+			if pos := cl.PosString(); pos != "" {
+				return "\nat " + pos
+			}
+		}
+		return ""
+	}
+
+	lines := []string{diagram.begin(title)}
+	for i, g := range goros {
+		ids[g] = fmt.Sprintf("g%d", i)
+		lines = append(lines, diagram.participant(ids[g], plain(g)))
+	}
+
+	for i, step := range path {
+		sl := step.sl
+		switch t := step.transition.(type) {
+		case nil:
+		case transition.Sync:
+			lines = append(lines, diagram.message(id(t.Progressed1), id(t.Progressed2),
+				plain(t.Channel)+at(sl, t.Progressed1)+at(sl, t.Progressed2)))
+		case transition.Send:
+			lines = append(lines, diagram.note([]string{id(t.Progressed())},
+				"buffered send on "+plain(t.Chan)+at(sl, t.Progressed())))
+		case transition.Receive:
+			lines = append(lines, diagram.note([]string{id(t.Progressed())},
+				"buffered receive on "+plain(t.Chan)+at(sl, t.Progressed())))
+		case transition.Signal:
+			if t.Missed() {
+				lines = append(lines, diagram.note([]string{id(t.Progressed1)},
+					plain(t.Cond)+".Signal() with no waiters"+at(sl, t.Progressed1)))
+			} else {
+				lines = append(lines, diagram.message(id(t.Progressed1), id(t.Progressed2),
+					plain(t.Cond)+".Signal()"+at(sl, t.Progressed1)))
+			}
+		case transition.Broadcast:
+			if len(t.Broadcastees) == 0 {
+				lines = append(lines, diagram.note([]string{id(t.Broadcaster)},
+					plain(t.Cond)+".Broadcast() with no waiters"+at(sl, t.Broadcaster)))
+			}
+			for _, g := range goros {
+				if _, ok := t.Broadcastees[g]; ok {
+					lines = append(lines, diagram.message(id(t.Broadcaster), id(g),
+						plain(t.Cond)+".Broadcast()"+at(sl, t.Broadcaster)))
+				}
+			}
+		case transition.In:
+			from := sl.GetUnsafe(t.Progressed()).Node().Function()
+			to := path[i+1].sl.GetUnsafe(t.Progressed()).Node().Function()
+			if from == to {
+				continue
+			}
+
+			chain := calleeChain(g, from, to)
+			names := make([]string, 0, len(chain))
+			for _, f := range chain {
+				names = append(names, f.String())
+			}
+			lines = append(lines, diagram.note([]string{id(t.Progressed())},
+				"calls "+strings.Join(names, " → ")))
+		case transition.TransitionSingle:
+			// Remaining single goroutine operations, e.g. close and (un)locking.
+			lines = append(lines, diagram.note([]string{id(t.Progressed())},
+				plain(step.transition)+at(sl, t.Progressed())))
+		default:
+			lines = append(lines, diagram.note([]string{id(goros[0])}, plain(step.transition)))
+		}
+	}
+
+	for _, g := range goros {
+		if _, ok := blocked[g]; ok {
+			lines = append(lines, diagram.note([]string{id(g)},
+				"blocked at "+plain(last.GetUnsafe(g))+at(last, g)))
+		}
+	}
+
+	if end := diagram.end(); end != "" {
+		lines = append(lines, end)
+	}
+	return strings.Join(lines, "\n")
+}
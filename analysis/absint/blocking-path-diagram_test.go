@@ -0,0 +1,84 @@
+package absint
+
+import (
+	"strings"
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+	"github.com/cs-au-dk/goat/utils/graph"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestPathDiagrams(t *testing.T) {
+	expectDiagram := func(format string, expected ...string) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			blocks := BlockAnalysis(C, G, result)
+			if len(blocks) == 0 {
+				t.Fatal("Expected a blocked goroutine")
+			}
+
+			cg := graph.FromCallGraph(C.LoadRes.Pointer.CallGraph, true)
+			diagram, err := blocks.PathDiagrams(G, cg, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, str := range expected {
+				if !strings.Contains(diagram, str) {
+					t.Errorf("Expected diagram to contain %q:\n%s", str, diagram)
+				}
+			}
+			if strings.Contains(diagram, "\x1b[") {
+				t.Errorf("Expected diagram without color codes:\n%s", diagram)
+			}
+		}
+	}
+
+	content := `func main() {
+		ch := make(chan int)
+		go func() {
+			ch <- 10
+		}()
+		<-ch
+		<-ch
+	}`
+
+	tests := []absIntCommTest{
+		{
+			"mermaid",
+			content,
+			expectDiagram(PATH_FORMAT_MERMAID,
+				"sequenceDiagram",
+				"participant g0 as",
+				"participant g1 as",
+				"g1->>g0: ",
+				"Note over g0: blocked at",
+			),
+		},
+		{
+			"plantuml",
+			content,
+			expectDiagram(PATH_FORMAT_PLANTUML,
+				"@startuml",
+				"\" as g0",
+				"g1 -> g0 : ",
+				"note over g0 : blocked at",
+				"@enduml",
+			),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
+
+func TestPathDiagramsUnknownFormat(t *testing.T) {
+	if _, err := (Blocks{}).PathDiagrams(SuperlocGraph{}, graph.Graph[*ssa.Function]{}, "dot"); err == nil {
+		t.Error("Expected an error for an unknown path format")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/cs-au-dk/goat/analysis/defs"
 	L "github.com/cs-au-dk/goat/analysis/lattice"
 	loc "github.com/cs-au-dk/goat/analysis/location"
+	"github.com/cs-au-dk/goat/analysis/upfront/models"
 	"github.com/cs-au-dk/goat/pkgutil"
 	tu "github.com/cs-au-dk/goat/testutil"
 	"github.com/cs-au-dk/goat/utils"
@@ -32,6 +33,11 @@ func (C *AnalysisCtxt) setFragmentPredicate(Localized, AnalyzeCallsWithoutConcur
 			return true
 		}
 
+		// Functions with user-supplied models are analyzed according to the model.
+		if _, found := models.Lookup(sfun); found {
+			return true
+		}
+
 		// Methods on sync.Once are easy to handle.
 		if recv := sfun.Signature.Recv(); recv != nil &&
 			utils.IsNamedType(recv.Type(), "sync", "Once") {
@@ -74,21 +80,25 @@ func (C *AnalysisCtxt) setFragmentPredicate(Localized, AnalyzeCallsWithoutConcur
 }
 
 type prepAI struct {
-	metrics bool
-	log     bool
+	metrics          bool
+	log              bool
+	generatedHarness bool
 }
 
 type AIConfig = struct {
 	Metrics bool
 	Log     bool
+	// The program is a harness generated for a library (see -harness).
+	GeneratedHarness bool
 }
 
 // Prepare Abstract Interpretation based on a
 // configuration (e. g. collect metrics)
 func ConfigAI(c AIConfig) prepAI {
 	return prepAI{
-		metrics: c.Metrics,
-		log:     c.Log,
+		metrics:          c.Metrics,
+		log:              c.Log,
+		generatedHarness: c.GeneratedHarness,
 	}
 }
 
@@ -217,10 +227,17 @@ type AnalysisCtxt struct {
 	// Determines which functions to "expand", essentially defining the
 	// fragment of the program to analyze.
 	FragmentPredicate FragmentPredicate
+	// The functions that are expanded, if the fragment predicate
+	// was computed from a set of functions.
+	fragmentFunctions map[*ssa.Function]bool
 
 	// Akin to "PSet" in GCatch
 	FocusedPrimitives []ssa.Value
 
+	// The program is a harness generated for a library, whose main function
+	// only waits for the goroutines calling the library.
+	GeneratedHarness bool
+
 	// Metrics collection
 	Metrics *Metrics
 
@@ -471,6 +488,8 @@ func (p prepAI) prep(
 		InitConf:  s0,
 		InitState: initState,
 		Metrics:   p.InitializeMetrics()(entryFun),
+
+		GeneratedHarness: p.generatedHarness,
 	}
 
 	if p.log {
@@ -570,12 +589,23 @@ func (C *AnalysisCtxt) FragmentPredicateFromPrimitives(
 		included[compIdx] = isInteresting
 	}
 
+	funs := map[*ssa.Function]bool{}
+	for compIdx, component := range scc.Components {
+		if included[compIdx] {
+			for _, fun := range component {
+				funs[fun] = true
+			}
+		}
+	}
+
 	C.FocusedPrimitives = primitives
+	C.FragmentPredicateFromFunctions(funs)
+}
 
+// Sets the fragment predicate such that exactly the given functions are expanded.
+func (C *AnalysisCtxt) FragmentPredicateFromFunctions(funs map[*ssa.Function]bool) {
+	C.fragmentFunctions = funs
 	C.FragmentPredicate = func(callIns ssa.CallInstruction, sfun *ssa.Function) bool {
-		if idx := scc.ComponentOf(sfun); idx != -1 {
-			return included[idx]
-		}
-		return false
+		return funs[sfun]
 	}
 }
@@ -0,0 +1,376 @@
+package absint
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/cfg"
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+	T "github.com/cs-au-dk/goat/analysis/transition"
+	"github.com/cs-au-dk/goat/utils/graph"
+
+	"github.com/fatih/color"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+type condWarningKind int
+
+const (
+	// Cond.Wait is not guarded by a loop re-checking a condition.
+	COND_UNGUARDED_WAIT condWarningKind = iota
+	// Cond.Signal or Cond.Broadcast may wake no goroutine, and a later
+	// waiter cannot observe that it happened.
+	COND_LOST_WAKEUP
+)
+
+// CondWarning describes a suspicious use of a sync.Cond.
+type CondWarning struct {
+	Kind condWarningKind
+	// Location of the condition variable.
+	Cond loc.Location
+	// Goroutine and control location of the offending operation. For lost
+	// wake-ups this is the Signal/Broadcast operation.
+	Goro defs.Goro
+	Op   defs.CtrLoc
+	// For lost wake-ups, a later call to Wait that cannot observe the signal.
+	Waiter    defs.Goro
+	WaiterLoc defs.CtrLoc
+	// The lockers of the condition variable. Unknown if IsLockerKnown is false.
+	Lockers L.PointsTo
+	// Whether the lockers of the condition variable are known.
+	IsLockerKnown bool
+}
+
+type CondWarnings []CondWarning
+
+func (w CondWarning) String() string {
+	fset := w.Op.Node().Function().Prog.Fset
+
+	var str string
+	switch w.Kind {
+	case COND_UNGUARDED_WAIT:
+		str = "Cond.Wait is not guarded by a loop re-checking a condition\n"
+	case COND_LOST_WAKEUP:
+		str = "Cond wake-up may be lost: no goroutine is waiting\n"
+	}
+
+	str += fmt.Sprintf("Cond: %s\nGoroutine: %s\nControl location: %s\nSource: %s\n",
+		w.Cond, w.Goro, w.Op, fset.Position(w.Op.Node().Pos()))
+
+	if w.Kind == COND_LOST_WAKEUP {
+		str += fmt.Sprintf("Later waiting goroutine: %s\nWaiting at: %s\nSource: %s\n",
+			w.Waiter, w.WaiterLoc, fset.Position(w.WaiterLoc.Node().Pos()))
+	}
+
+	if w.IsLockerKnown {
+		str += "Locker: " + w.Lockers.String() + "\n"
+	} else {
+		str += "Locker: unknown\n"
+	}
+
+	return str
+}
+
+func (ws CondWarnings) String() string {
+	str := "\n"
+	for _, w := range ws {
+		str += w.String() + "\n"
+	}
+	return str
+}
+
+func (ws CondWarnings) Log() {
+	fmt.Println(color.YellowString("Warning:"), ws.String())
+}
+
+// CondAnalysis reports suspicious uses of condition variables:
+//
+// 1. Calls to Cond.Wait that are not inside a loop with a conditional exit.
+// Since waking up does not guarantee that the awaited condition holds,
+// Wait should always be called in a loop re-checking the condition.
+//
+// 2. Calls to Cond.Signal and Cond.Broadcast that may wake no goroutine,
+// after which a goroutine may call Wait. The later waiter cannot observe that
+// the wake-up already happened if it does not re-check a condition, or if
+// the locations read by the loop guarding its call to Wait hold the same
+// abstract values as when the signal was sent.
+func CondAnalysis(C AnalysisCtxt, G SuperlocGraph, result L.Analysis) (res CondWarnings) {
+	// Inspecting the abstract memory should not affect metrics.
+	C.Metrics = nil
+
+	type warnKey struct {
+		kind     condWarningKind
+		op, wait defs.CtrLoc
+	}
+	seen := make(map[warnKey]bool)
+	register := func(w CondWarning) {
+		key := warnKey{w.Kind, w.Op, w.WaiterLoc}
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, w)
+		}
+	}
+
+	// Cache loop information per call to Wait.
+	guards := make(map[ssa.CallInstruction]waitGuard)
+	guardOf := func(n *cfg.Waiting) waitGuard {
+		call := n.CallInstruction()
+		if res, found := guards[call]; found {
+			return res
+		}
+		res := loopGuard(call.Block())
+		guards[call] = res
+		return res
+	}
+
+	// lockersOf loads the condition variable at the given location and returns its lockers.
+	lockersOf := func(mem L.Memory, cond loc.Location) (L.PointsTo, bool) {
+		if addr, ok := cond.(loc.AddressableLocation); ok {
+			if av, found := mem.Get(addr); found && av.IsCond() {
+				condV := av.CondValue()
+				if condV.IsLockerKnown() {
+					return condV.KnownLockers(), true
+				}
+			}
+		}
+		return Elements().PointsTo(), false
+	}
+
+	// waitingOn returns the condition variables the goroutine waits on.
+	waitingOn := func(g defs.Goro, mem L.Memory, n *cfg.Waiting) []loc.Location {
+		av, _ := C.swapWildcard(g, mem, n.Cond())
+		return av.PointerValue().NonNilEntries()
+	}
+
+	// A goroutine waiting on a condition variable.
+	type waiter struct {
+		conf *AbsConfiguration
+		g    defs.Goro
+		cl   defs.CtrLoc
+		n    *cfg.Waiting
+	}
+
+	// Compute which waiters are reachable from each component of the
+	// superlocation graph once, instead of searching the graph from every
+	// missed wake-up.
+	scc := G.ToGraph().SCC([]*AbsConfiguration{G.Entry()})
+	sccGraph := scc.ToGraph()
+	waiters := make(map[int][]waiter)
+	reach := make(map[int]map[int]struct{})
+	var reachable func(ci int) map[int]struct{}
+	reachable = func(ci int) map[int]struct{} {
+		if res, found := reach[ci]; found {
+			return res
+		}
+
+		res := make(map[int]struct{})
+		if len(waiters[ci]) > 0 {
+			res[ci] = struct{}{}
+		}
+		for _, cj := range sccGraph.Edges(ci) {
+			if cj == ci {
+				continue
+			}
+			for ck := range reachable(cj) {
+				res[ck] = struct{}{}
+			}
+		}
+		reach[ci] = res
+		return res
+	}
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		mem := result.GetUnsafe(conf.Superlocation()).Memory()
+
+		conf.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+			n, ok := cl.Node().(*cfg.Waiting)
+			if !ok {
+				return
+			}
+
+			ci := scc.ComponentOf(conf)
+			waiters[ci] = append(waiters[ci], waiter{conf, g, cl, n})
+
+			if guardOf(n).guarded {
+				return
+			}
+
+			for _, cond := range waitingOn(g, mem, n) {
+				lockers, known := lockersOf(mem, cond)
+				register(CondWarning{
+					Kind:          COND_UNGUARDED_WAIT,
+					Cond:          cond,
+					Goro:          g,
+					Op:            cl,
+					Lockers:       lockers,
+					IsLockerKnown: known,
+				})
+			}
+		})
+	})
+
+	// observes returns true if the waiter may observe that the state changed
+	// since the configuration where the signal was sent, by re-checking the
+	// condition guarding its call to Wait.
+	observes := func(w waiter, signalled *AbsConfiguration) bool {
+		guard := guardOf(w.n)
+		if !guard.guarded {
+			return false
+		}
+
+		before := result.GetUnsafe(signalled.Superlocation()).Memory()
+		after := result.GetUnsafe(w.conf.Superlocation()).Memory()
+
+		// Reads whose address is not available at the waiter are
+		// conservatively assumed to be observable.
+		for _, addr := range guard.reads {
+			var ptr L.AbstractValue
+			switch addr := addr.(type) {
+			case *ssa.Global:
+				ptr = evaluateSSA(w.g, after, addr)
+			default:
+				av, found := after.Get(loc.LocationFromSSAValue(w.g, addr))
+				if !found {
+					return true
+				}
+				ptr = av
+			}
+
+			for _, l := range ptr.PointerValue().NonNilEntries() {
+				l, ok := l.(loc.AddressableLocation)
+				if !ok {
+					return true
+				}
+				v1, found1 := before.Get(l)
+				v2, found2 := after.Get(l)
+				if found1 != found2 || (found1 && !v1.Eq(v2)) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		for _, succ := range conf.GetSuccessorMap() {
+			var (
+				signaller defs.Goro
+				cond      loc.Location
+			)
+			switch t := succ.Transition().(type) {
+			case T.Signal:
+				if !t.Missed() {
+					continue
+				}
+				signaller, cond = t.Progressed1, t.Cond
+			case T.Broadcast:
+				if len(t.Broadcastees) > 0 {
+					continue
+				}
+				signaller, cond = t.Broadcaster, t.Cond
+			default:
+				continue
+			}
+
+			next := succ.Configuration()
+			if next.IsCrashed() {
+				continue
+			}
+
+			// Find later goroutines waiting on the same condition variable
+			// that cannot observe the wake-up.
+			for ci := range reachable(scc.ComponentOf(next)) {
+				for _, w := range waiters[ci] {
+					laterMem := result.GetUnsafe(w.conf.Superlocation()).Memory()
+					for _, c := range waitingOn(w.g, laterMem, w.n) {
+						if !c.Equal(cond) || observes(w, conf) {
+							continue
+						}
+
+						lockers, known := lockersOf(laterMem, cond)
+						register(CondWarning{
+							Kind:          COND_LOST_WAKEUP,
+							Cond:          cond,
+							Goro:          signaller,
+							Op:            conf.GetUnsafe(signaller),
+							Waiter:        w.g,
+							WaiterLoc:     w.cl,
+							Lockers:       lockers,
+							IsLockerKnown: known,
+						})
+					}
+				}
+			}
+		}
+	})
+
+	// Ensure consistent ordering
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+
+	return
+}
+
+// waitGuard describes the loop around a call to Wait.
+type waitGuard struct {
+	// The call is part of a loop that may be exited by a conditional branch.
+	guarded bool
+	// Addresses loaded in the loop, from which the condition is computed.
+	reads []ssa.Value
+}
+
+// loopGuard finds the loop containing the basic block in its function,
+// and whether it may be exited by a conditional branch.
+func loopGuard(bb *ssa.BasicBlock) (res waitGuard) {
+	fun := bb.Parent()
+	scc := graph.FromBasicBlocks(fun).SCC([]int{0})
+	ci := scc.ComponentOf(bb.Index)
+	comp := scc.Components[ci]
+
+	// Check that the block is in a cycle.
+	if len(comp) == 1 {
+		selfLoop := false
+		for _, succ := range bb.Succs {
+			selfLoop = selfLoop || succ == bb
+		}
+		if !selfLoop {
+			return
+		}
+	}
+
+	for _, idx := range comp {
+		blk := fun.Blocks[idx]
+		for _, instr := range blk.Instrs {
+			if load, ok := instr.(*ssa.UnOp); ok && load.Op == token.MUL {
+				res.reads = append(res.reads, load.X)
+			}
+		}
+
+		// Check that the loop has a conditional exit.
+		if _, isIf := blk.Instrs[len(blk.Instrs)-1].(*ssa.If); !isIf {
+			continue
+		}
+
+		for _, succ := range blk.Succs {
+			if scc.ComponentOf(succ.Index) != ci {
+				res.guarded = true
+			}
+		}
+	}
+
+	return
+}
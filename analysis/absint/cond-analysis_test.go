@@ -0,0 +1,140 @@
+package absint
+
+import (
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestCondAnalysis(t *testing.T) {
+	expectWarnings := func(kind condWarningKind, expected int) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			warnings := CondAnalysis(C, G, result)
+
+			found := 0
+			for _, w := range warnings {
+				if w.Kind == kind {
+					found++
+					if !w.IsLockerKnown || len(w.Lockers.NonNilEntries()) == 0 {
+						t.Errorf("Expected the locker of the condition variable to be known:\n%v", w)
+					}
+				}
+			}
+
+			if found != expected {
+				t.Errorf("Expected %d warnings, found %d:\n%v", expected, found, warnings)
+			}
+		}
+	}
+
+	tests := []absIntCommTest{
+		{
+			"unguarded-wait",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					cond.Signal()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				cond.Wait()
+				mu.Unlock()
+			}`,
+			expectWarnings(COND_UNGUARDED_WAIT, 1),
+		},
+		{
+			"guarded-wait",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ready := false
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					ready = true
+					cond.Signal()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				for !ready {
+					cond.Wait()
+				}
+				mu.Unlock()
+			}`,
+			expectWarnings(COND_UNGUARDED_WAIT, 0),
+		},
+		{
+			"lost-wakeup",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					cond.Broadcast()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				cond.Wait()
+				mu.Unlock()
+			}`,
+			expectWarnings(COND_LOST_WAKEUP, 1),
+		},
+		{
+			"observable-wakeup",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ready := false
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					ready = true
+					cond.Broadcast()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				for !ready {
+					cond.Wait()
+				}
+				mu.Unlock()
+			}`,
+			expectWarnings(COND_LOST_WAKEUP, 0),
+		},
+		{
+			"guarded-lost-wakeup",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ready := false
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					cond.Signal()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				for !ready {
+					cond.Wait()
+				}
+				mu.Unlock()
+			}`,
+			expectWarnings(COND_LOST_WAKEUP, 1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
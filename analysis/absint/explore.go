@@ -0,0 +1,322 @@
+package absint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+	T "github.com/cs-au-dk/goat/analysis/transition"
+
+	"github.com/fatih/color"
+)
+
+const exploreHelp = `Commands:
+  l, list             List the enabled transitions
+  t, take <n>         Take the n-th enabled transition
+  b, back [<n>]       Backtrack n steps (default 1)
+  B, blocked [<n>]    List blocked configurations, or jump to the n-th one
+  m, mem [<loc>...]   Print the memory, or the locations whose name contains any <loc>
+  s, show             Print the current superlocation and the path to it
+  h, help             Print this message
+  q, quit             Stop exploring`
+
+// exploreStep is a configuration on the explored path, together with the
+// abstract state in which it was reached, and the transition taken to get there.
+type exploreStep struct {
+	conf       *AbsConfiguration
+	state      L.AnalysisState
+	transition T.Transition
+}
+
+type explorer struct {
+	C   AnalysisCtxt
+	out io.Writer
+
+	// Path from the initial configuration to the current configuration.
+	path []exploreStep
+	// Enabled transitions of the current configuration, sorted by their string representation.
+	succs []getSuccResult
+
+	// Result of the static analysis, computed on the first request for blocked configurations.
+	G       *SuperlocGraph
+	A       L.Analysis
+	blocked []defs.Superloc
+}
+
+// Explore is a read-eval-print loop for stepping through the abstract
+// transition system, starting at the initial configuration of the analysis
+// context. Commands are read from in, one per line, and their results are
+// written to out.
+//
+// Taking a transition computes the successor state from the current state
+// alone, i.e., unlike the static analysis, states are not joined with states
+// found along other paths. Jumping to a blocked configuration runs the full
+// static analysis, after which the path to it follows the shortest path in
+// the superlocation graph, using the states of the fixpoint.
+func Explore(C AnalysisCtxt, in io.Reader, out io.Writer) {
+	e := &explorer{C: C, out: out}
+	e.moveTo([]exploreStep{{conf: C.InitConf, state: C.InitState}})
+
+	fmt.Fprintln(out, exploreHelp)
+	e.printConf()
+	e.list()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, color.BlueString("explore> "))
+		if !scanner.Scan() {
+			return
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "l", "list":
+			e.list()
+		case "t", "take":
+			if n, ok := e.index(args, len(e.succs)); ok {
+				e.take(n)
+			}
+		case "b", "back":
+			steps := 1
+			if len(args) > 0 {
+				var err error
+				if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+					fmt.Fprintln(out, "Expected a positive number of steps, got", args[0])
+					continue
+				}
+			}
+			e.back(steps)
+		case "B", "blocked":
+			e.analyze()
+			if len(args) == 0 {
+				e.listBlocked()
+			} else if n, ok := e.index(args, len(e.blocked)); ok {
+				e.jump(e.blocked[n])
+			}
+		case "m", "mem":
+			e.printMemory(args)
+		case "s", "show":
+			e.printPath()
+			e.printConf()
+		case "h", "help":
+			fmt.Fprintln(out, exploreHelp)
+		case "q", "quit":
+			return
+		default:
+			fmt.Fprintf(out, "Unknown command %q, type help for a list of commands\n", cmd)
+		}
+	}
+}
+
+func (e *explorer) current() exploreStep {
+	return e.path[len(e.path)-1]
+}
+
+// moveTo replaces the explored path and computes the enabled transitions
+// of its last configuration.
+func (e *explorer) moveTo(path []exploreStep) {
+	e.path = path
+	cur := e.current()
+
+	e.succs = e.succs[:0]
+	if !cur.conf.IsCrashed() {
+		for _, succ := range cur.conf.GetTransitions(e.C, cur.state) {
+			e.succs = append(e.succs, succ)
+		}
+	}
+	sort.Slice(e.succs, func(i, j int) bool {
+		return e.succs[i].Transition().String() < e.succs[j].Transition().String()
+	})
+}
+
+// index parses the first argument as an index in [0, n).
+func (e *explorer) index(args []string, n int) (int, bool) {
+	if len(args) == 0 {
+		fmt.Fprintln(e.out, "Expected an index")
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(args[0])
+	if err != nil || i < 0 || i >= n {
+		fmt.Fprintf(e.out, "Expected an index between 0 and %d, got %s\n", n-1, args[0])
+		return 0, false
+	}
+	return i, true
+}
+
+func (e *explorer) list() {
+	cur := e.current()
+	switch {
+	case cur.conf.IsCrashed():
+		fmt.Fprintln(e.out, color.RedString("The program has crashed."))
+		return
+	case len(e.succs) == 0 && cur.conf.IsExited():
+		fmt.Fprintln(e.out, color.GreenString("The program has exited."))
+		return
+	case len(e.succs) == 0:
+		fmt.Fprintln(e.out, color.RedString("No transitions are enabled."))
+		return
+	}
+
+	fmt.Fprintln(e.out, color.CyanString("Enabled transitions:"))
+	for i, succ := range e.succs {
+		fmt.Fprintf(e.out, "  [%d] %s\n", i, succ.Transition())
+	}
+}
+
+func (e *explorer) take(n int) {
+	prev, succ := e.current(), e.succs[n]
+	e.moveTo(append(e.path, exploreStep{succ.Configuration(), succ.State, succ.Transition()}))
+
+	fmt.Fprintln(e.out, color.CyanString("Took transition:"), succ.Transition())
+	e.printConf()
+
+	diff := prev.state.Memory().Difference(succ.State.Memory())
+	fmt.Fprintln(e.out, color.CyanString("Memory changes:"))
+	fmt.Fprintln(e.out, diff)
+
+	e.list()
+}
+
+func (e *explorer) back(steps int) {
+	if steps >= len(e.path) {
+		steps = len(e.path) - 1
+	}
+	if steps == 0 {
+		fmt.Fprintln(e.out, "Already at the initial configuration.")
+		return
+	}
+
+	e.moveTo(e.path[:len(e.path)-steps])
+	e.printConf()
+	e.list()
+}
+
+// analyze runs the static analysis and the blocking analysis, unless they have already been run.
+func (e *explorer) analyze() {
+	if e.G != nil {
+		return
+	}
+
+	fmt.Fprintln(e.out, "Running the static analysis...")
+	G, A := StaticAnalysis(e.C)
+	e.G, e.A = &G, A
+
+	for sl := range BlockAnalysis(e.C, G, A) {
+		e.blocked = append(e.blocked, sl)
+	}
+	sort.Slice(e.blocked, func(i, j int) bool {
+		return e.blocked[i].String() < e.blocked[j].String()
+	})
+}
+
+func (e *explorer) listBlocked() {
+	if len(e.blocked) == 0 {
+		fmt.Fprintln(e.out, color.GreenString("No blocked configurations were found."))
+		return
+	}
+
+	fmt.Fprintln(e.out, color.RedString("Blocked configurations:"))
+	for i, sl := range e.blocked {
+		fmt.Fprintf(e.out, "  [%d] %s\n", i, sl)
+	}
+}
+
+// jump moves to the given superlocation along the shortest path from the entry of the superlocation graph.
+func (e *explorer) jump(target defs.Superloc) {
+	type link struct {
+		pred       *AbsConfiguration
+		transition T.Transition
+	}
+
+	G := *e.G
+	preds := map[*AbsConfiguration]link{G.Entry(): {}}
+	var found *AbsConfiguration
+	G.ToGraph().BFS(G.Entry(), func(conf *AbsConfiguration) bool {
+		if conf.Superlocation().Equal(target) {
+			found = conf
+			return true
+		}
+
+		for _, succ := range conf.Successors {
+			if _, ok := preds[succ.Configuration()]; !ok {
+				preds[succ.Configuration()] = link{conf, succ.Transition()}
+			}
+		}
+		return false
+	})
+
+	if found == nil {
+		fmt.Fprintln(e.out, "The configuration is not reachable in the superlocation graph.")
+		return
+	}
+
+	path := []exploreStep{}
+	for conf := found; conf != nil; conf = preds[conf].pred {
+		path = append(path, exploreStep{
+			conf,
+			e.A.GetUnsafe(conf.Superlocation()),
+			preds[conf].transition,
+		})
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	e.moveTo(path)
+	e.printPath()
+	e.printConf()
+	e.list()
+}
+
+func (e *explorer) printConf() {
+	fmt.Fprintf(e.out, "%s (depth %d)\n", color.CyanString("Superlocation:"), len(e.path)-1)
+	fmt.Fprintln(e.out, e.current().conf.Superlocation().StringWithPos())
+}
+
+func (e *explorer) printPath() {
+	fmt.Fprintln(e.out, color.CyanString("Path:"))
+	for i, step := range e.path[1:] {
+		fmt.Fprintf(e.out, "  %d: %s\n", i+1, step.transition)
+	}
+}
+
+// printMemory prints the memory of the current configuration. If patterns are given,
+// only locations with a name containing one of them are printed.
+func (e *explorer) printMemory(patterns []string) {
+	mem := e.current().state.Memory()
+	if len(patterns) == 0 {
+		fmt.Fprintln(e.out, mem)
+		return
+	}
+
+	lines := []string{}
+	mem.ForEach(func(al loc.AddressableLocation, av L.AbstractValue) {
+		name := al.String()
+		for _, pattern := range patterns {
+			if strings.Contains(name, pattern) {
+				lines = append(lines, fmt.Sprintf("  %s ↦ %s", name, av))
+				return
+			}
+		}
+	})
+
+	if len(lines) == 0 {
+		fmt.Fprintln(e.out, "No locations match", strings.Join(patterns, ", "))
+		return
+	}
+
+	sort.Strings(lines)
+	fmt.Fprintln(e.out, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,59 @@
+package absint
+
+import (
+	"strings"
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestExplore(t *testing.T) {
+	runEmbeddedTest(t, absIntCommTest{
+		"explore-blocked-receive",
+		`func main() {
+			ch := make(chan int)
+			go func() {
+				ch <- 10
+			}()
+			<-ch
+			<-ch
+		}`,
+		func(t *testing.T, C AnalysisCtxt, _ L.Analysis, _ SuperlocGraph, _ tu.NotesManager) {
+			script := strings.Join([]string{
+				"take 0",
+				"back",
+				"back",
+				"take 100",
+				"blocked",
+				"blocked 0",
+				"mem ch",
+				"frobnicate",
+				"quit",
+				"list",
+			}, "\n")
+
+			out := &strings.Builder{}
+			Explore(C, strings.NewReader(script), out)
+			res := out.String()
+
+			for _, expected := range []string{
+				"Took transition:",
+				"Memory changes:",
+				"Already at the initial configuration.",
+				"Expected an index between 0 and",
+				"Blocked configurations:",
+				"Path:",
+				`Unknown command "frobnicate"`,
+			} {
+				if !strings.Contains(res, expected) {
+					t.Errorf("Expected output to contain %q:\n%s", expected, res)
+				}
+			}
+
+			if strings.Count(res, "explore> ") != 9 {
+				t.Errorf("Expected the prompt to stop at quit:\n%s", res)
+			}
+		},
+	})
+}
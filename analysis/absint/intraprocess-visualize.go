@@ -21,6 +21,7 @@ func VisualizeIntraprocess(
 	analysis map[defs.CtrLoc]L.AnalysisState,
 ) {
 	G := &dot.DotGraph{
+		Name: "intraprocess",
 		Options: map[string]string{
 			"minlen":  fmt.Sprint(opts.Minlen()),
 			"nodesep": fmt.Sprint(opts.Nodesep()),
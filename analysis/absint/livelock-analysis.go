@@ -0,0 +1,121 @@
+package absint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	T "github.com/cs-au-dk/goat/analysis/transition"
+)
+
+// Livelock describes a set of goroutines that may loop forever without
+// synchronizing, e.g., by repeatedly taking the default branch of a select
+// statement in a for loop.
+type Livelock struct {
+	// The configurations of the strongly connected component the program cannot leave.
+	Component []*AbsConfiguration
+	// The control locations visited by each looping goroutine.
+	Loops map[defs.Goro]map[defs.CtrLoc]struct{}
+}
+
+type Livelocks []Livelock
+
+func (l Livelock) String() string {
+	goros := make([]defs.Goro, 0, len(l.Loops))
+	for g := range l.Loops {
+		goros = append(goros, g)
+	}
+	sort.Slice(goros, func(i, j int) bool {
+		return goros[i].String() < goros[j].String()
+	})
+
+	str := fmt.Sprintf("Potential livelock/busy-wait in a cycle of %d superlocations\n", len(l.Component))
+	for _, g := range goros {
+		str += fmt.Sprintf("Goroutine: %s loops without synchronizing at:\n", g)
+
+		cls := []string{}
+		for cl := range l.Loops[g] {
+			pos := g.CtrLoc().Root().Prog.Fset.Position(cl.Node().Pos())
+			cls = append(cls, fmt.Sprintf("  %s at %s\n", cl, pos))
+		}
+		sort.Strings(cls)
+
+		for _, cl := range cls {
+			str += cl
+		}
+	}
+
+	return str
+}
+
+func (ls Livelocks) String() string {
+	str := "\n"
+	for _, l := range ls {
+		str += l.String() + "\n"
+	}
+	return str
+}
+
+func (ls Livelocks) Log() {
+	fmt.Println(ls.String())
+}
+
+// LivelockAnalysis finds strongly connected components of the superlocation
+// graph that the program can never leave, except by crashing or exiting, and
+// reports the goroutines that only take silent transitions (including default
+// branches of select statements) in them. Such goroutines spin forever
+// without performing useful synchronization and without terminating, even
+// if other goroutines in the component keep synchronizing.
+func LivelockAnalysis(G SuperlocGraph) (res Livelocks) {
+	scc := G.ToGraph().SCC([]*AbsConfiguration{G.Entry()})
+
+	for ci, comp := range scc.Components {
+		loops := make(map[defs.Goro]map[defs.CtrLoc]struct{})
+		synchronizes := make(map[defs.Goro]bool)
+		escapes := false
+
+		for _, conf := range comp {
+			for _, succ := range conf.GetSuccessorMap() {
+				next := succ.Configuration()
+				if scc.ComponentOf(next) != ci {
+					// Ending the program does not release the looping goroutines.
+					if !next.IsCrashed() && !next.IsExited() {
+						escapes = true
+					}
+					continue
+				}
+
+				if in, isSilent := succ.Transition().(T.In); isSilent {
+					g := in.Progressed()
+					if _, found := loops[g]; !found {
+						loops[g] = make(map[defs.CtrLoc]struct{})
+					}
+					loops[g][conf.GetUnsafe(g)] = struct{}{}
+					loops[g][next.GetUnsafe(g)] = struct{}{}
+					continue
+				}
+
+				// Every goroutine that progresses in a synchronizing transition synchronizes.
+				conf.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+					if ncl, found := next.Get(g); !found || !ncl.Equal(cl) {
+						synchronizes[g] = true
+					}
+				})
+			}
+		}
+
+		// Components with an exit are not livelocked.
+		if escapes {
+			continue
+		}
+
+		for g := range synchronizes {
+			delete(loops, g)
+		}
+		if len(loops) > 0 {
+			res = append(res, Livelock{comp, loops})
+		}
+	}
+
+	return
+}
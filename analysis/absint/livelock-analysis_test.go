@@ -0,0 +1,118 @@
+package absint
+
+import (
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestLivelockAnalysis(t *testing.T) {
+	expectLivelocks := func(expected int) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			if res := LivelockAnalysis(G); len(res) != expected {
+				t.Errorf("Expected %d livelocks, found %d:\n%v", expected, len(res), res)
+			}
+		}
+	}
+
+	tests := []absIntCommTest{
+		{
+			"select-default-spin",
+			`func main() {
+				ch := make(chan int)
+				for {
+					select {
+					case <-ch:
+						return
+					default:
+					}
+				}
+			}`,
+			expectLivelocks(1),
+		},
+		{
+			"select-default-eventually-released",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					ch <- 10
+				}()
+				for {
+					select {
+					case <-ch:
+						return
+					default:
+					}
+				}
+			}`,
+			expectLivelocks(0),
+		},
+		{
+			"blocking-loop",
+			`func main() {
+				ch := make(chan int)
+				for {
+					<-ch
+				}
+			}`,
+			expectLivelocks(0),
+		},
+		{
+			"spin-while-others-synchronize",
+			`func main() {
+				ch := make(chan int)
+				go func() {
+					for {
+						ch <- 1
+					}
+				}()
+				go func() {
+					for {
+						<-ch
+					}
+				}()
+
+				done := make(chan int)
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+				}
+			}`,
+			func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+				res := LivelockAnalysis(G)
+				if len(res) != 1 || len(res[0].Loops) != 1 {
+					t.Errorf("Expected only the main goroutine to spin, found:\n%v", res)
+				}
+			},
+		},
+		{
+			"spin-until-crash",
+			`var ubool bool
+
+			func main() {
+				ch := make(chan int)
+				for {
+					select {
+					case <-ch:
+						return
+					default:
+						if ubool {
+							panic("gave up")
+						}
+					}
+				}
+			}`,
+			expectLivelocks(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package absint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/cfg"
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+	"github.com/cs-au-dk/goat/utils"
+)
+
+// LockLeak describes a goroutine that may terminate while holding a lock.
+type LockLeak struct {
+	// Superlocation at which the goroutine terminates.
+	Superloc defs.Superloc
+	Goro     defs.Goro
+	Mu       loc.Location
+	// How the lock was acquired by the terminating goroutine.
+	Acquisition LockAcquisition
+	// Goroutines that are blocked trying to acquire the leaked lock.
+	Blocked Blocks
+	// The goroutine crashed the program with an unrecovered panic.
+	Crashed bool
+}
+
+type LockLeaks []LockLeak
+
+func (l LockLeak) String() string {
+	cl := l.Superloc.GetUnsafe(l.Goro)
+	fset := cl.Node().Function().Prog.Fset
+
+	str := "Goroutine may terminate while holding lock: " + l.Mu.String() + "\n"
+	if l.Crashed {
+		str = "Goroutine may crash while holding lock: " + l.Mu.String() + "\n"
+	}
+	str += fmt.Sprintf("Goroutine: %s\nControl location: %s\nSource: %s\n",
+		l.Goro, cl, fset.Position(cl.Node().Pos()))
+
+	sites := make([]string, 0, len(l.Acquisition.Sites))
+	for site := range l.Acquisition.Sites {
+		sites = append(sites, fmt.Sprintf("%s at %s", site.Node(), fset.Position(site.Node().Pos())))
+	}
+	sort.Strings(sites)
+
+	str += "Acquired at:\n"
+	for _, site := range sites {
+		str += "  " + site + "\n"
+	}
+
+	if len(l.Blocked) > 0 {
+		str += "Goroutines blocked on the leaked lock:"
+		str += l.Blocked.String()
+	}
+
+	return str
+}
+
+func (ls LockLeaks) String() string {
+	str := "\n"
+	for _, l := range ls {
+		str += l.String() + "\n"
+	}
+	return str
+}
+
+func (ls LockLeaks) Log() {
+	fmt.Println(ls.String())
+}
+
+// LockLeakAnalysis finds goroutines that may terminate while holding a lock.
+// A goroutine terminates while holding a lock if it is at a cfg.TerminateGoro
+// node, or at the exit node of its root function, while the lockset
+// analysis determines that it holds the lock, and the abstract memory states
+// that the lock may still be locked.
+//
+// Goroutines that crash the program with an unrecovered panic are also
+// checked. Locksets are not computed for crashed configurations, so the
+// lockset of the goroutine at the location where it panicked is used.
+//
+// Blocked goroutines in `blocks` that wait to acquire a leaked lock are
+// included in the report, connecting the blocked goroutines to the culprit.
+func LockLeakAnalysis(C AnalysisCtxt, G SuperlocGraph, result L.Analysis, blocks Blocks) (res LockLeaks) {
+	// Inspecting the abstract memory should not affect metrics.
+	C.Metrics = nil
+	locksets := LocksetAnalysis(G)
+
+	// Only report each goroutine/lock/control location combination once.
+	type leakKey struct {
+		g  defs.Goro
+		mu loc.Location
+		cl defs.CtrLoc
+	}
+	seen := make(map[leakKey]bool)
+
+	// report registers the locks in ls that may still be locked when
+	// goroutine g terminates at configuration conf.
+	report := func(conf *AbsConfiguration, g defs.Goro, ls Lockset, crashed bool) {
+		cl := conf.GetUnsafe(g)
+		mem := result.GetUnsafe(conf.Superlocation()).Memory()
+
+		for mu, acq := range ls {
+			key := leakKey{g, mu, cl}
+			if seen[key] || !mayBeLocked(mem, mu) {
+				continue
+			}
+			seen[key] = true
+
+			res = append(res, LockLeak{
+				Superloc:    conf.Superlocation(),
+				Goro:        g,
+				Mu:          mu,
+				Acquisition: acq,
+				Blocked:     C.blockedOnLock(result, blocks, mu),
+				Crashed:     crashed,
+			})
+		}
+	}
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		conf.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+			switch n := cl.Node().(type) {
+			case *cfg.TerminateGoro:
+				if n.Cause() != cfg.GoroTermination.EXIT_ROOT {
+					return
+				}
+			case *cfg.FunctionExit:
+				if n.Function() != cl.Root() {
+					return
+				}
+			default:
+				return
+			}
+
+			report(conf, g, locksets.Get(conf, g), false)
+		})
+
+		// Find the goroutines that crash the program in a successor.
+		for _, succ := range conf.GetSuccessorMap() {
+			next := succ.Configuration()
+			if !next.IsCrashed() {
+				continue
+			}
+
+			next.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+				n, ok := cl.Node().(*cfg.TerminateGoro)
+				if !ok || n.Cause() != cfg.GoroTermination.CRASHED {
+					return
+				}
+
+				if prev, found := conf.Get(g); found && !prev.Equal(cl) {
+					report(next, g, locksets.Get(conf, g), true)
+				}
+			})
+		}
+	})
+
+	// Ensure consistent ordering
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+
+	return
+}
+
+// mayBeLocked returns true if the mutex or RWMutex at the given location
+// may be locked (for writing or reading) according to the abstract memory.
+func mayBeLocked(mem L.Memory, mu loc.Location) bool {
+	addr, ok := mu.(loc.AddressableLocation)
+	if !ok {
+		return true
+	}
+
+	av, found := mem.Get(addr)
+	if !found {
+		return true
+	}
+
+	LOCKED, _ := L.Consts().Mutex()
+	switch {
+	case av.IsMutex():
+		return av.MutexValue().Geq(LOCKED)
+	case av.IsRWMutex():
+		rw := av.RWMutexValue()
+		return rw.Status().Geq(LOCKED) || rw.MaybeRLocked()
+	}
+
+	return true
+}
+
+// blockedOnLock returns the subset of blocked goroutines that are trying to
+// acquire the lock at location mu.
+func (C AnalysisCtxt) blockedOnLock(result L.Analysis, blocks Blocks, mu loc.Location) Blocks {
+	res := make(Blocks)
+	blocks.ForEach(func(sl defs.Superloc, gs map[defs.Goro]struct{}) {
+		mem := result.GetUnsafe(sl).Memory()
+
+		for g := range gs {
+			for _, prim := range cfg.CommunicationPrimitivesOf(sl.GetUnsafe(g).Node()) {
+				if utils.IsNamedType(prim.Type(), "sync", "Cond") {
+					continue
+				}
+
+				av, _ := C.swapWildcard(g, mem, prim)
+				if av.IsPointer() && av.PointerValue().Contains(mu) {
+					res.register(sl, g)
+				}
+			}
+		}
+	})
+	return res
+}
@@ -0,0 +1,129 @@
+package absint
+
+import (
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestLockLeakAnalysis(t *testing.T) {
+	expectLeaks := func(expected int, expectBlocked bool) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			leaks := LockLeakAnalysis(C, G, result, BlockAnalysis(C, G, result))
+			if len(leaks) != expected {
+				t.Errorf("Expected %d lock leaks, found %d:\n%v", expected, len(leaks), leaks)
+				return
+			}
+
+			for _, leak := range leaks {
+				if expectBlocked && len(leak.Blocked) == 0 {
+					t.Errorf("Expected blocked goroutines to be connected to:\n%v", leak)
+				}
+			}
+		}
+	}
+
+	tests := []absIntCommTest{
+		{
+			"exit-while-locked",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				done := make(chan bool)
+				go func() {
+					mu.Lock()
+					done <- true
+				}()
+				<-done
+				mu.Lock()
+			}`,
+			expectLeaks(1, true),
+		},
+		{
+			"unlocked-before-exit",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				done := make(chan bool)
+				go func() {
+					mu.Lock()
+					defer mu.Unlock()
+					done <- true
+				}()
+				<-done
+				mu.Lock()
+			}`,
+			expectLeaks(0, false),
+		},
+		{
+			"missing-unlock-on-path",
+			`import "sync"
+
+			var ubool bool
+
+			func main() {
+				var mu sync.RWMutex
+				go func() {
+					mu.RLock()
+					if ubool {
+						mu.RUnlock()
+					}
+				}()
+				mu.Lock()
+			}`,
+			expectLeaks(1, true),
+		},
+		{
+			"crash-while-locked",
+			`import "sync"
+
+			var ubool bool
+
+			func main() {
+				var mu sync.Mutex
+				go func() {
+					mu.Lock()
+					if ubool {
+						panic("crash")
+					}
+					mu.Unlock()
+				}()
+				mu.Lock()
+			}`,
+			func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+				leaks := LockLeakAnalysis(C, G, result, BlockAnalysis(C, G, result))
+				if len(leaks) != 1 || !leaks[0].Crashed {
+					t.Errorf("Expected the goroutine to crash while holding the lock, found:\n%v", leaks)
+				}
+			},
+		},
+		{
+			"unlocked-before-crash",
+			`import "sync"
+
+			var ubool bool
+
+			func main() {
+				var mu sync.Mutex
+				go func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if ubool {
+						panic("crash")
+					}
+				}()
+				mu.Lock()
+			}`,
+			expectLeaks(0, false),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
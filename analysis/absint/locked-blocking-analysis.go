@@ -0,0 +1,231 @@
+package absint
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/absint/ops"
+	"github.com/cs-au-dk/goat/analysis/cfg"
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+
+	"github.com/fatih/color"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// LockedBlockingOp describes a potentially blocking operation that is
+// performed by a goroutine while it holds a lock.
+type LockedBlockingOp struct {
+	Goro defs.Goro
+	// Control location of the blocking operation.
+	Op defs.CtrLoc
+	// Locks held by the goroutine at the blocking operation.
+	Locks Lockset
+}
+
+type LockedBlockingOps []LockedBlockingOp
+
+func (o LockedBlockingOp) String() string {
+	fset := o.Op.Node().Function().Prog.Fset
+
+	str := fmt.Sprintf("Potentially blocking operation while holding %s\nGoroutine: %s\nOperation: %s\nSource: %s\n",
+		o.Locks, o.Goro, o.Op, fset.Position(o.Op.Node().Pos()))
+
+	sites := []string{}
+	for mu, acq := range o.Locks {
+		for site := range acq.Sites {
+			sites = append(sites, fmt.Sprintf("%s acquired at %s", mu, fset.Position(site.Node().Pos())))
+		}
+	}
+	sort.Strings(sites)
+
+	for _, site := range sites {
+		str += "  " + site + "\n"
+	}
+
+	return str
+}
+
+func (bs LockedBlockingOps) String() string {
+	str := "\n"
+	for _, o := range bs {
+		str += o.String() + "\n"
+	}
+	return str
+}
+
+func (bs LockedBlockingOps) Log() {
+	fmt.Println(color.YellowString("Advisory:"), bs.String())
+}
+
+// LockedBlockingAnalysis is an advisory checker that finds potentially
+// blocking operations performed while holding a Mutex or RWMutex. Holding a
+// lock while blocking is a common root cause of deadlocks, even when no
+// deadlock is found in the explored state space.
+//
+// Channel operations are considered potentially blocking if the abstract
+// memory at the configuration does not guarantee that they can proceed without
+// a communication partner. Cond.Wait is reported if the goroutine holds a lock
+// other than the locker of the condition variable. WaitGroup waits are found
+// by replaying the silent steps of goroutines that hold a lock.
+func LockedBlockingAnalysis(C AnalysisCtxt, G SuperlocGraph, result L.Analysis) (res LockedBlockingOps) {
+	// Inspecting the abstract memory should not affect metrics or logging.
+	C.Metrics = nil
+	C.Log.Enabled = false
+
+	locksets := LocksetAnalysis(G)
+
+	// Only report each goroutine/control location combination once.
+	seen := make(map[defs.Goro]map[defs.CtrLoc]bool)
+	register := func(g defs.Goro, cl defs.CtrLoc, locks Lockset) {
+		if _, found := seen[g]; !found {
+			seen[g] = make(map[defs.CtrLoc]bool)
+		}
+		if !seen[g][cl] {
+			seen[g][cl] = true
+			res = append(res, LockedBlockingOp{g, cl, locks})
+		}
+	}
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		state := result.GetUnsafe(conf.Superlocation())
+		mem := state.Memory()
+
+		conf.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+			locks := locksets.Get(conf, g)
+			if len(locks) == 0 {
+				return
+			}
+
+			switch n := cl.Node().(type) {
+			case *cfg.Waiting:
+				locks = C.withoutCondLockers(g, mem, n.Cond(), locks)
+				if len(locks) > 0 {
+					register(g, cl, locks)
+				}
+			case *cfg.Select:
+				for _, op := range n.Ops() {
+					if _, isDefault := op.(*cfg.SelectDefault); isDefault {
+						return
+					}
+				}
+
+				// A select without a default case blocks if none of its cases may proceed.
+				for _, op := range n.Ops() {
+					_, isSend := op.(*cfg.SelectSend)
+					if C.mayProceedOnChannel(g, mem, op.Channel(), isSend) {
+						return
+					}
+				}
+				register(g, cl, locks)
+			case *cfg.SSANode:
+				switch i := n.Instruction().(type) {
+				case *ssa.Send:
+					if !C.mayProceedOnChannel(g, mem, i.Chan, true) {
+						register(g, cl, locks)
+					}
+				case *ssa.UnOp:
+					if i.Op == token.ARROW && !C.mayProceedOnChannel(g, mem, i.X, false) {
+						register(g, cl, locks)
+					}
+				}
+			}
+		})
+
+		// Calls to WaitGroup.Wait are not synchronizing operations, and are
+		// therefore only visible when replaying silent steps.
+		if g := conf.nextSilentProgress(C, state); g != nil {
+			locks := locksets.Get(conf, g)
+			if len(locks) == 0 {
+				return
+			}
+
+			for cl := range conf.silentFixpoint(C, g, state) {
+				if isWaitGroupWait(cl.Node()) {
+					register(g, cl, locks)
+				}
+			}
+		}
+	})
+
+	// Ensure consistent ordering
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+
+	return
+}
+
+// mayProceedOnChannel returns true if, according to the abstract memory, a send
+// or receive on the given channel may proceed without a communication partner.
+func (C AnalysisCtxt) mayProceedOnChannel(g defs.Goro, mem L.Memory, ch ssa.Value, send bool) bool {
+	av, mem := C.swapWildcard(g, mem, ch)
+	if av.PointerValue().Contains(loc.NilLocation{}) {
+		return false
+	}
+
+	chV := L.Consts().BotValue()
+	ops.ToDeref(av).OnSucceed(func(av L.AbstractValue) {
+		chV = ops.Load(av, mem)
+	})
+
+	if chV.IsBot() {
+		return false
+	}
+
+	var outcome L.OpOutcomes
+	if send {
+		outcome = ops.FlatSend(chV.ChanValue().Payload())(chV)
+	} else {
+		outcome = ops.FlatReceive(chV.ChanValue().Payload(), false)(chV)
+	}
+
+	return outcome.MaySucceed() || outcome.MayPanic()
+}
+
+// withoutCondLockers removes the lockers of the condition variable from the
+// lockset, since Cond.Wait releases them while waiting.
+func (C AnalysisCtxt) withoutCondLockers(g defs.Goro, mem L.Memory, cond ssa.Value, locks Lockset) Lockset {
+	av, mem := C.swapWildcard(g, mem, cond)
+
+	res := locks.copy()
+	for _, ptr := range av.PointerValue().NonNilEntries() {
+		condV := ops.Load(Elements().AbstractPointerV(ptr), mem)
+		if !condV.IsCond() {
+			continue
+		}
+
+		for _, locker := range condV.CondValue().KnownLockers().Entries() {
+			delete(res, locker)
+		}
+	}
+
+	return res
+}
+
+func isWaitGroupWait(n cfg.Node) bool {
+	var call ssa.CallInstruction
+	switch n := n.(type) {
+	case *cfg.SSANode:
+		call, _ = n.Instruction().(ssa.CallInstruction)
+	case *cfg.DeferCall:
+		call, _ = n.DeferLink().SSANode().Instruction().(ssa.CallInstruction)
+	}
+
+	if call == nil {
+		return false
+	}
+
+	if sc := call.Common().StaticCallee(); sc != nil {
+		return sc.String() == "(*sync.WaitGroup).Wait"
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package absint
+
+import (
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestLockedBlockingAnalysis(t *testing.T) {
+	expectReports := func(expected int) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			if res := LockedBlockingAnalysis(C, G, result); len(res) != expected {
+				t.Errorf("Expected %d reports, found %d:\n%v", expected, len(res), res)
+			}
+		}
+	}
+
+	tests := []absIntCommTest{
+		{
+			"send-while-locked",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ch := make(chan int)
+				go func() {
+					mu.Lock()
+					<-ch
+					mu.Unlock()
+				}()
+				mu.Lock()
+				ch <- 10
+				mu.Unlock()
+			}`,
+			expectReports(2),
+		},
+		{
+			"buffered-send-while-locked",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ch := make(chan int, 1)
+				mu.Lock()
+				ch <- 10
+				mu.Unlock()
+			}`,
+			expectReports(0),
+		},
+		{
+			"select-with-default",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				ch := make(chan int)
+				mu.Lock()
+				select {
+				case ch <- 10:
+				default:
+				}
+				mu.Unlock()
+			}`,
+			expectReports(0),
+		},
+		{
+			"cond-wait-own-locker",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					cond.Signal()
+					mu.Unlock()
+				}()
+				mu.Lock()
+				cond.Wait()
+				mu.Unlock()
+			}`,
+			expectReports(0),
+		},
+		{
+			"cond-wait-other-lock",
+			`import "sync"
+
+			func main() {
+				var mu, other sync.Mutex
+				cond := sync.NewCond(&mu)
+				go func() {
+					mu.Lock()
+					cond.Signal()
+					mu.Unlock()
+				}()
+				other.Lock()
+				mu.Lock()
+				cond.Wait()
+				mu.Unlock()
+				other.Unlock()
+			}`,
+			expectReports(1),
+		},
+		{
+			"waitgroup-wait-while-locked",
+			`import "sync"
+
+			func main() {
+				var mu sync.Mutex
+				var wg sync.WaitGroup
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+				}()
+				mu.Lock()
+				wg.Wait()
+				mu.Unlock()
+			}`,
+			expectReports(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
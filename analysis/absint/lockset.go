@@ -0,0 +1,199 @@
+package absint
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+	T "github.com/cs-au-dk/goat/analysis/transition"
+	"github.com/cs-au-dk/goat/utils/worklist"
+)
+
+// LockAcquisition describes how a goroutine holds a lock.
+type LockAcquisition struct {
+	// Read is true if the lock may only be held as a reader lock.
+	Read bool
+	// Sites contains the control locations at which the lock may have been acquired.
+	Sites map[defs.CtrLoc]struct{}
+}
+
+// Lockset maps the locations of the locks that a goroutine must hold
+// to the way they were acquired.
+type Lockset map[loc.Location]LockAcquisition
+
+// Locksets contains the lockset of every goroutine in every configuration
+// of a superlocation graph.
+type Locksets map[*AbsConfiguration]map[defs.Goro]Lockset
+
+// Get returns the lockset of goroutine g at configuration conf.
+// The result is nil if the configuration was not reached by the analysis.
+func (l Locksets) Get(conf *AbsConfiguration, g defs.Goro) Lockset {
+	return l[conf][g]
+}
+
+func (ls Lockset) copy() Lockset {
+	res := make(Lockset, len(ls))
+	for mu, acq := range ls {
+		res[mu] = acq
+	}
+	return res
+}
+
+// join computes the locks that are held on all paths. Acquisition sites are
+// merged, and a lock is considered held as a reader lock if it may be on
+// either path.
+func (ls Lockset) join(other Lockset) Lockset {
+	res := make(Lockset)
+	for mu, acq1 := range ls {
+		acq2, found := other[mu]
+		if !found {
+			continue
+		}
+
+		sites := make(map[defs.CtrLoc]struct{}, len(acq1.Sites)+len(acq2.Sites))
+		for cl := range acq1.Sites {
+			sites[cl] = struct{}{}
+		}
+		for cl := range acq2.Sites {
+			sites[cl] = struct{}{}
+		}
+
+		res[mu] = LockAcquisition{
+			Read:  acq1.Read || acq2.Read,
+			Sites: sites,
+		}
+	}
+	return res
+}
+
+func (ls Lockset) eq(other Lockset) bool {
+	if len(ls) != len(other) {
+		return false
+	}
+
+	for mu, acq1 := range ls {
+		acq2, found := other[mu]
+		if !found || acq1.Read != acq2.Read || len(acq1.Sites) != len(acq2.Sites) {
+			return false
+		}
+		for cl := range acq1.Sites {
+			if _, found := acq2.Sites[cl]; !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Protects returns true if ls and other share a lock which at least one
+// of them holds as a writer lock.
+func (ls Lockset) Protects(other Lockset) bool {
+	for mu, acq1 := range ls {
+		if acq2, found := other[mu]; found && !(acq1.Read && acq2.Read) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ls Lockset) String() string {
+	strs := make([]string, 0, len(ls))
+	for mu, acq := range ls {
+		str := mu.String()
+		if acq.Read {
+			str += " (read)"
+		}
+		strs = append(strs, str)
+	}
+	sort.Strings(strs)
+	return "{" + strings.Join(strs, ", ") + "}"
+}
+
+// LocksetAnalysis computes, for every goroutine in every configuration of the
+// superlocation graph, the set of locks the goroutine must hold. The analysis
+// is a forward must-analysis over the transitions of the graph, where locksets
+// are joined by intersection. Crashed configurations are not considered.
+//
+// NOTE (unsound): Lock locations are abstract, so a single location may
+// represent multiple concrete mutexes. Such locks are treated as if they
+// were the same mutex.
+func LocksetAnalysis(G SuperlocGraph) Locksets {
+	entry := make(map[defs.Goro]Lockset)
+	G.Entry().ForEach(func(g defs.Goro, _ defs.CtrLoc) {
+		entry[g] = Lockset{}
+	})
+	res := Locksets{G.Entry(): entry}
+
+	W := worklist.Empty[*AbsConfiguration]()
+	W.Add(G.Entry())
+
+	for !W.IsEmpty() {
+		conf := W.GetNext()
+		locksets := res[conf]
+
+		for _, succ := range conf.GetSuccessorMap() {
+			next := succ.Configuration()
+			if next.IsCrashed() {
+				continue
+			}
+
+			updated := make(map[defs.Goro]Lockset)
+			next.ForEach(func(g defs.Goro, _ defs.CtrLoc) {
+				if ls, found := locksets[g]; found {
+					updated[g] = ls
+				} else {
+					// Newly spawned goroutines hold no locks.
+					updated[g] = Lockset{}
+				}
+			})
+
+			switch t := succ.Transition().(type) {
+			case T.Lock:
+				g := t.Progressed()
+				ls := updated[g].copy()
+				ls[t.Mu] = LockAcquisition{
+					Sites: map[defs.CtrLoc]struct{}{conf.GetUnsafe(g): {}},
+				}
+				updated[g] = ls
+			case T.RLock:
+				g := t.Progressed()
+				ls := updated[g].copy()
+				ls[t.Mu] = LockAcquisition{
+					Read:  true,
+					Sites: map[defs.CtrLoc]struct{}{conf.GetUnsafe(g): {}},
+				}
+				updated[g] = ls
+			case T.Unlock:
+				ls := updated[t.Progressed()].copy()
+				delete(ls, t.Mu)
+				updated[t.Progressed()] = ls
+			case T.RUnlock:
+				ls := updated[t.Progressed()].copy()
+				delete(ls, t.Mu)
+				updated[t.Progressed()] = ls
+			}
+
+			prev, visited := res[next]
+			if visited {
+				changed := false
+				for g, ls := range updated {
+					if prevLs, found := prev[g]; found {
+						joined := prevLs.join(ls)
+						changed = changed || !joined.eq(prevLs)
+						updated[g] = joined
+					}
+				}
+
+				if !changed {
+					continue
+				}
+			}
+
+			res[next] = updated
+			W.Add(next)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,379 @@
+package absint
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	tu "github.com/cs-au-dk/goat/testutil"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Reasons for aborted analysis runs.
+const (
+	ABORT_FOCUSED_PRIMITIVE_SWAPPED = "focused-primitive-swapped"
+	ABORT_UNBOUNDED_GOROUTINE_SPAWN = "unbounded-goroutine-spawn"
+	ABORT_OTHER                     = "other"
+)
+
+// AbortReason classifies the error that aborted the analysis.
+// Returns an empty string if the analysis was not aborted.
+func (m *Metrics) AbortReason() string {
+	if m == nil || m.Outcome != OUTCOME_PANIC {
+		return ""
+	}
+
+	err, _ := m.errorMsg.(error)
+	switch {
+	case errors.Is(err, ErrFocusedPrimitiveSwapped):
+		return ABORT_FOCUSED_PRIMITIVE_SWAPPED
+	case errors.Is(err, ErrUnboundedGoroutineSpawn):
+		return ABORT_UNBOUNDED_GOROUTINE_SPAWN
+	default:
+		return ABORT_OTHER
+	}
+}
+
+// ExpandedFunction is a function expanded by the analysis, and the number of times it was expanded.
+type ExpandedFunction struct {
+	Function string `json:"function"`
+	Count    int    `json:"count"`
+}
+
+// BlockMetrics is a goroutine reported to be blocked.
+type BlockMetrics struct {
+	Goroutine string `json:"goroutine"`
+	Operation string `json:"operation"`
+	Position  string `json:"position"`
+}
+
+// Coverage is the number of operations of some kind that were
+// encountered by the analysis, out of all such operations in the program.
+type Coverage struct {
+	Covered int `json:"covered"`
+	Total   int `json:"total"`
+}
+
+// EntryMetrics are the metrics of the analysis of a single entry function.
+type EntryMetrics struct {
+	Function string `json:"function"`
+	Outcome  string `json:"outcome"`
+	// Reason for aborted runs, see ABORT_*, and the error that caused it.
+	AbortReason string  `json:"abortReason,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	Seconds     float64 `json:"seconds"`
+
+	ExpandedFunctions []ExpandedFunction `json:"expandedFunctions"`
+	Blocks            []BlockMetrics     `json:"blocks"`
+	// Goroutines that are blocked when the program exits.
+	Leaks []BlockMetrics `json:"leaks"`
+
+	ConcurrencyOps Coverage `json:"concurrencyOps"`
+	Chans          Coverage `json:"chans"`
+	Gos            Coverage `json:"gos"`
+
+	// Lines in the files of the expanded functions, and the lines
+	// among them that contain code (not only comments or white space).
+	Lines     int `json:"lines"`
+	CodeLines int `json:"codeLines"`
+}
+
+// MetricsReport contains the metrics of every analysed entry function,
+// sorted by function name, and the operations that no analysis run covered.
+type MetricsReport struct {
+	Entries []EntryMetrics `json:"entries"`
+
+	ConcurrencyOps Coverage `json:"concurrencyOps"`
+	Chans          Coverage `json:"chans"`
+	Gos            Coverage `json:"gos"`
+
+	// Positions of operations that were not covered, sorted by position.
+	UncoveredConcurrencyOps []string `json:"uncoveredConcurrencyOps"`
+	UncoveredChans          []string `json:"uncoveredChans"`
+	UncoveredGos            []string `json:"uncoveredGos"`
+}
+
+// NewMetricsReport gathers the metrics of the analysis of every entry function.
+func NewMetricsReport(loadRes tu.LoadResult, results map[*ssa.Function]*Metrics) MetricsReport {
+	fset := loadRes.Prog.Fset
+	allConcOps := loadRes.Cfg.GetAllConcurrencyOps()
+	allChans := loadRes.Cfg.GetAllChans()
+	allGos := loadRes.Cfg.GetAllGos()
+
+	coveredConcOps := make(map[ssa.Instruction]struct{})
+	coveredChans := make(map[ssa.Instruction]struct{})
+	coveredGos := make(map[ssa.Instruction]struct{})
+
+	report := MetricsReport{Entries: make([]EntryMetrics, 0, len(results))}
+	for f, r := range results {
+		entry := EntryMetrics{
+			Function:          f.String(),
+			Outcome:           r.Outcome,
+			AbortReason:       r.AbortReason(),
+			Seconds:           r.time.Seconds(),
+			ExpandedFunctions: []ExpandedFunction{},
+			Blocks:            []BlockMetrics{},
+			Leaks:             []BlockMetrics{},
+			ConcurrencyOps:    Coverage{len(r.ConcurrencyOps()), len(allConcOps)},
+			Chans:             Coverage{len(r.Chans()), len(allChans)},
+			Gos:               Coverage{len(r.Gos()), len(allGos)},
+		}
+		if entry.AbortReason != "" {
+			entry.Error = r.Error()
+		}
+
+		files := make(map[string]struct{})
+		for fun, times := range r.Functions() {
+			entry.ExpandedFunctions = append(entry.ExpandedFunctions, ExpandedFunction{fun.String(), times})
+			if pos := fset.Position(fun.Pos()); pos.IsValid() {
+				files[pos.Filename] = struct{}{}
+			}
+		}
+		sort.Slice(entry.ExpandedFunctions, func(i, j int) bool {
+			return entry.ExpandedFunctions[i].Function < entry.ExpandedFunctions[j].Function
+		})
+		entry.Lines, entry.CodeLines = countLines(fset, files)
+
+		blockMetrics := func(blocks Blocks) (res []BlockMetrics) {
+			res = []BlockMetrics{}
+			for sl, gs := range blocks {
+				for g := range gs {
+					cl := sl.GetUnsafe(g)
+					res = append(res, BlockMetrics{
+						Goroutine: ansiEscape.ReplaceAllString(g.String(), ""),
+						Operation: cl.Node().String(),
+						Position:  fset.Position(cl.Node().Pos()).String(),
+					})
+				}
+			}
+			sort.Slice(res, func(i, j int) bool {
+				bi, bj := res[i], res[j]
+				if bi.Position != bj.Position {
+					return bi.Position < bj.Position
+				}
+				return bi.Goroutine < bj.Goroutine
+			})
+			return
+		}
+		entry.Blocks = blockMetrics(r.Blocks().Deadlocks())
+		entry.Leaks = blockMetrics(r.Blocks().Leaks())
+
+		// Operations are only covered by runs that were not skipped or aborted.
+		if r.Outcome != OUTCOME_SKIP && r.Outcome != OUTCOME_PANIC {
+			for i := range r.ConcurrencyOps() {
+				coveredConcOps[i] = struct{}{}
+			}
+			for i := range r.Chans() {
+				coveredChans[i] = struct{}{}
+			}
+			for i := range r.Gos() {
+				coveredGos[i] = struct{}{}
+			}
+		}
+
+		report.Entries = append(report.Entries, entry)
+	}
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Function < report.Entries[j].Function
+	})
+
+	uncovered := func(all, covered map[ssa.Instruction]struct{}) (Coverage, []string) {
+		res := []string{}
+		for insn := range all {
+			if _, ok := covered[insn]; !ok {
+				res = append(res, fset.Position(insn.Pos()).String()+": "+insn.String())
+			}
+		}
+		sort.Strings(res)
+		return Coverage{len(all) - len(res), len(all)}, res
+	}
+
+	report.ConcurrencyOps, report.UncoveredConcurrencyOps = uncovered(allConcOps, coveredConcOps)
+	report.Chans, report.UncoveredChans = uncovered(allChans, coveredChans)
+	report.Gos, report.UncoveredGos = uncovered(allGos, coveredGos)
+
+	return report
+}
+
+// countLines counts the lines of the given files, and the lines that contain
+// at least one token other than a comment, like cloc does.
+func countLines(fset *token.FileSet, files map[string]struct{}) (lines, code int) {
+	for filename := range files {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+
+		// Use a fresh file set to not grow the file set of the program.
+		file := token.NewFileSet().AddFile(filename, -1, len(src))
+		var s scanner.Scanner
+		s.Init(file, src, nil, 0)
+
+		codeLines := make(map[int]struct{})
+		for {
+			pos, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			// The scanner inserts semicolons at line ends.
+			if tok == token.SEMICOLON && lit == "\n" {
+				continue
+			}
+
+			start := file.Line(pos)
+			end := start
+			if tok == token.STRING && strings.HasPrefix(lit, "`") {
+				// Raw strings may span several lines.
+				end += strings.Count(lit, "\n")
+			}
+			for line := start; line <= end; line++ {
+				codeLines[line] = struct{}{}
+			}
+		}
+
+		lines += file.LineCount()
+		code += len(codeLines)
+	}
+	return
+}
+
+// Write writes the report to the given file. The format is determined by
+// the file extension, which must be .json or .csv. In CSV format, every
+// entry function is a row, where expanded functions, blocks and leaks are counted.
+func (r MetricsReport) Write(path string) error {
+	var write func(io.Writer) error
+	switch filepath.Ext(path) {
+	case ".json":
+		write = r.WriteJSON
+	case ".csv":
+		write = r.WriteCSV
+	default:
+		return fmt.Errorf("unsupported metrics file format: %s", path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r MetricsReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r MetricsReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"function", "outcome", "abort_reason", "seconds",
+		"expanded_functions", "expansions", "blocks", "leaks",
+		"concurrency_ops_covered", "concurrency_ops_total",
+		"chans_covered", "chans_total",
+		"gos_covered", "gos_total",
+		"lines", "code_lines", "error",
+	})
+
+	for _, e := range r.Entries {
+		expansions := 0
+		for _, f := range e.ExpandedFunctions {
+			expansions += f.Count
+		}
+
+		itoa := strconv.Itoa
+		out.Write([]string{
+			e.Function, e.Outcome, e.AbortReason, strconv.FormatFloat(e.Seconds, 'f', 3, 64),
+			itoa(len(e.ExpandedFunctions)), itoa(expansions), itoa(len(e.Blocks)), itoa(len(e.Leaks)),
+			itoa(e.ConcurrencyOps.Covered), itoa(e.ConcurrencyOps.Total),
+			itoa(e.Chans.Covered), itoa(e.Chans.Total),
+			itoa(e.Gos.Covered), itoa(e.Gos.Total),
+			itoa(e.Lines), itoa(e.CodeLines), e.Error,
+		})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// String formats the report for human readers.
+func (r MetricsReport) String() string {
+	var b strings.Builder
+	b.WriteString("================ Results =====================\n\n")
+
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "Function: %s\nOutcome: %s\n", e.Function, e.Outcome)
+
+		switch e.Outcome {
+		case OUTCOME_SKIP:
+			b.WriteString("Function finished\n\n")
+			continue
+		case OUTCOME_PANIC:
+			fmt.Fprintf(&b, "Aborted (%s): %s\nFunction finished\n\n", e.AbortReason, e.Error)
+			continue
+		}
+
+		fmt.Fprintf(&b, "Time: %.3fs\n\n", e.Seconds)
+
+		if len(e.ExpandedFunctions) > 0 {
+			fmt.Fprintf(&b, "Expanded functions: %d {\n", len(e.ExpandedFunctions))
+			for _, f := range e.ExpandedFunctions {
+				fmt.Fprintf(&b, "  %s -- %d\n", f.Function, f.Count)
+			}
+			b.WriteString("}\n")
+		}
+
+		if len(e.Blocks) > 0 {
+			b.WriteString("Blocks: {\n")
+			for _, block := range e.Blocks {
+				fmt.Fprintf(&b, "  %s at %s (%s)\n", block.Goroutine, block.Operation, block.Position)
+			}
+			b.WriteString("}\n")
+		}
+
+		if len(e.Leaks) > 0 {
+			b.WriteString("Leaked at program exit: {\n")
+			for _, block := range e.Leaks {
+				fmt.Fprintf(&b, "  %s at %s (%s)\n", block.Goroutine, block.Operation, block.Position)
+			}
+			b.WriteString("}\n")
+		}
+
+		if e.Lines > 0 {
+			fmt.Fprintf(&b, "Lines: %d (%d code)\n", e.Lines, e.CodeLines)
+		}
+		b.WriteString("Function finished\n\n")
+	}
+
+	coverage := func(name string, c Coverage, uncovered []string) {
+		fmt.Fprintf(&b, "%s covered: %d/%d\n", name, c.Covered, c.Total)
+		if len(uncovered) > 0 {
+			b.WriteString("Not covered: {\n")
+			for _, op := range uncovered {
+				b.WriteString("  " + op + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	coverage("Concurrency operations", r.ConcurrencyOps, r.UncoveredConcurrencyOps)
+	coverage("Channel sites", r.Chans, r.UncoveredChans)
+	coverage("Goroutine sites", r.Gos, r.UncoveredGos)
+
+	b.WriteString("================ Results =====================")
+	return b.String()
+}
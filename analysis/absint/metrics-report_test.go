@@ -0,0 +1,129 @@
+package absint
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAbortReason(t *testing.T) {
+	for err, expected := range map[error]string{
+		fmt.Errorf("%w: at l", ErrFocusedPrimitiveSwapped):      ABORT_FOCUSED_PRIMITIVE_SWAPPED,
+		fmt.Errorf("%w: recursion", ErrUnboundedGoroutineSpawn): ABORT_UNBOUNDED_GOROUTINE_SPAWN,
+		errors.New("runtime error: invalid memory address"):     ABORT_OTHER,
+	} {
+		m := initMetrics(nil)
+		m.Panic(err)
+		if reason := m.AbortReason(); reason != expected {
+			t.Errorf("Expected %q for %v, got %q", expected, err, reason)
+		}
+	}
+
+	m := initMetrics(nil)
+	m.Skip()
+	if reason := m.AbortReason(); reason != "" {
+		t.Errorf("Expected no abort reason for a skipped run, got %q", reason)
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	src := "package main\n" +
+		"\n" +
+		"// A comment\n" +
+		"/* A multi-line\n" +
+		"   comment */\n" +
+		"var s = `raw\n" +
+		"string`\n" +
+		"\n" +
+		"func main() {} // Trailing comment\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, code := countLines(token.NewFileSet(), map[string]struct{}{path: {}})
+	if lines != 9 || code != 4 {
+		t.Errorf("Expected 9 lines of which 4 are code, got %d and %d", lines, code)
+	}
+}
+
+func TestMetricsReportWrite(t *testing.T) {
+	report := MetricsReport{
+		Entries: []EntryMetrics{{
+			Function:          "main.main",
+			Outcome:           OUTCOME_BUGS_FOUND,
+			Seconds:           1.5,
+			ExpandedFunctions: []ExpandedFunction{{"main.main", 1}, {"main.worker", 3}},
+			Blocks:            []BlockMetrics{{"main", "recv", "main.go:10:3"}},
+			Leaks:             []BlockMetrics{{"main ↝ go worker()", "send", "main.go:5:3"}, {"main ↝ go worker()", "send", "main.go:6:3"}},
+			ConcurrencyOps:    Coverage{2, 4},
+			Chans:             Coverage{1, 1},
+			Gos:               Coverage{1, 2},
+			Lines:             20,
+			CodeLines:         15,
+		}, {
+			Function:    "main.TestX",
+			Outcome:     OUTCOME_PANIC,
+			AbortReason: ABORT_UNBOUNDED_GOROUTINE_SPAWN,
+			Error:       "Unbounded goroutine spawns detected, with a comma",
+		}},
+		UncoveredGos: []string{"main.go:20:2: go f()"},
+	}
+
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "metrics.json")
+	if err := report.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read MetricsReport
+	if err := json.Unmarshal(contents, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Entries) != 2 || read.Entries[0].ExpandedFunctions[1].Count != 3 || len(read.Entries[0].Leaks) != 2 ||
+		read.Entries[1].AbortReason != ABORT_UNBOUNDED_GOROUTINE_SPAWN {
+		t.Errorf("Unexpected JSON report: %s", contents)
+	}
+
+	path = filepath.Join(dir, "metrics.csv")
+	if err := report.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and two rows, got %v", rows)
+	}
+	expected := "main.main,Bugs found,,1.500,2,4,1,2,2,4,1,1,1,2,20,15,"
+	if row := strings.Join(rows[1], ","); row != expected {
+		t.Errorf("Expected row %q, got %q", expected, row)
+	}
+	if row := rows[2]; row[2] != ABORT_UNBOUNDED_GOROUTINE_SPAWN || row[len(row)-1] != report.Entries[1].Error {
+		t.Errorf("Unexpected row for aborted run: %v", row)
+	}
+
+	unsupported := filepath.Join(dir, "metrics.txt")
+	if err := report.Write(unsupported); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+	if _, err := os.Stat(unsupported); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created", unsupported)
+	}
+}
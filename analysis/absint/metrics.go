@@ -219,7 +219,8 @@ func (m *Metrics) Done() {
 	}
 
 	m.timerStop()
-	if len(m.blocks) > 0 {
+	// Goroutines leaked at program exit are not deadlocks.
+	if len(m.blocks.Deadlocks()) > 0 {
 		m.Outcome = OUTCOME_BUGS_FOUND
 	} else {
 		m.Outcome = OUTCOME_NO_BUGS_FOUND
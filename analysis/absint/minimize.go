@@ -0,0 +1,192 @@
+package absint
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+
+	"github.com/fatih/color"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Minimization is a PSet and a set of expanded functions, which are
+// (1-)minimal such that the analysis still reports a goroutine blocked
+// at the control location.
+type Minimization struct {
+	CtrLoc     defs.CtrLoc
+	Primitives []ssa.Value
+	Functions  []*ssa.Function
+	// Number of times the analysis was run during minimization.
+	Runs int
+}
+
+func (m Minimization) String() string {
+	str := color.CyanString("Minimal fragment for goroutine blocked at ") + m.CtrLoc.String()
+	if pos := m.CtrLoc.PosString(); pos != "" {
+		str += " (" + pos + ")"
+	}
+	str += fmt.Sprintf(" after %d runs\n", m.Runs)
+
+	str += color.CyanString("Primitives:") + "\n"
+	for _, prim := range m.Primitives {
+		str += "  " + prim.Name() + " = " + prim.String() + "\n"
+	}
+
+	str += color.CyanString("Expanded functions:") + "\n"
+	for _, fun := range m.Functions {
+		str += "  " + fun.String() + "\n"
+	}
+
+	return str
+}
+
+func (m Minimization) Log() {
+	fmt.Println(m.String())
+}
+
+// MinimizeFragment shrinks the PSet and the fragment in which a goroutine is
+// reported to be blocked at the given control location, using delta debugging.
+// First the PSet is minimized, with fragments computed from the primitives as in
+// FragmentPredicateFromPrimitives, after which the functions that are expanded
+// in the fragment of the minimal PSet are minimized.
+//
+// prepare must return a fresh analysis context for the entry of the fragment,
+// with metrics enabled. Runs of the analysis that do not complete within the
+// timeout are treated as not reporting the blocked control location.
+func MinimizeFragment(
+	prepare func() AnalysisCtxt,
+	pset []ssa.Value,
+	primitiveToUses map[ssa.Value]map[*ssa.Function]struct{},
+	blocked defs.CtrLoc,
+	timeout time.Duration,
+) Minimization {
+	res := Minimization{CtrLoc: blocked}
+
+	reproduces := func(C AnalysisCtxt) bool {
+		res.Runs++
+
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-time.After(timeout):
+				C.Metrics.Skip()
+			case <-done:
+			}
+		}()
+
+		C.Metrics.TimerStart()
+		ts, analysis := StaticAnalysis(C)
+		close(done)
+
+		if outcome := C.Metrics.Outcome; outcome == OUTCOME_SKIP || outcome == OUTCOME_PANIC {
+			return false
+		}
+
+		return BlockAnalysisFiltered(C, ts, analysis, true).Exists(
+			func(sl defs.Superloc, gs map[defs.Goro]struct{}) bool {
+				for g := range gs {
+					if sl.GetUnsafe(g).Equal(blocked) {
+						return true
+					}
+				}
+				return false
+			})
+	}
+
+	prims := append([]ssa.Value(nil), pset...)
+	sort.Slice(prims, func(i, j int) bool {
+		return prims[i].String() < prims[j].String()
+	})
+
+	res.Primitives = ddmin(prims, func(prims []ssa.Value) bool {
+		C := prepare()
+		C.FragmentPredicateFromPrimitives(prims, primitiveToUses)
+		return reproduces(C)
+	})
+
+	C := prepare()
+	C.FragmentPredicateFromPrimitives(res.Primitives, primitiveToUses)
+
+	funs := make([]*ssa.Function, 0, len(C.fragmentFunctions))
+	for fun := range C.fragmentFunctions {
+		funs = append(funs, fun)
+	}
+	sort.Slice(funs, func(i, j int) bool {
+		return funs[i].String() < funs[j].String()
+	})
+
+	expanding := func(funs []*ssa.Function) bool {
+		C := prepare()
+		C.FocusedPrimitives = res.Primitives
+
+		set := make(map[*ssa.Function]bool, len(funs))
+		for _, fun := range funs {
+			set[fun] = true
+		}
+		C.FragmentPredicateFromFunctions(set)
+		return reproduces(C)
+	}
+
+	if len(funs) > 0 && expanding(nil) {
+		funs = nil
+	} else {
+		funs = ddmin(funs, expanding)
+	}
+	res.Functions = funs
+
+	return res
+}
+
+// ddmin finds a 1-minimal subset of items that satisfies test with the delta
+// debugging algorithm of Zeller and Hildebrandt, i.e. removing any single
+// item from the result makes the test fail. Assumes that items satisfy test.
+// The order of the items is preserved.
+func ddmin[T any](items []T, test func([]T) bool) []T {
+	n := 2
+	for len(items) >= 2 {
+		chunks := make([][]T, 0, n)
+		for i := 0; i < n; i++ {
+			chunks = append(chunks, items[i*len(items)/n:(i+1)*len(items)/n])
+		}
+
+		reduced := false
+		for _, chunk := range chunks {
+			if test(chunk) {
+				items, n, reduced = chunk, 2, true
+				break
+			}
+		}
+
+		// With two chunks the complements are the chunks themselves.
+		if !reduced && n > 2 {
+			for i := range chunks {
+				complement := make([]T, 0, len(items)-len(chunks[i]))
+				for j, chunk := range chunks {
+					if j != i {
+						complement = append(complement, chunk...)
+					}
+				}
+
+				if test(complement) {
+					items, n, reduced = complement, n-1, true
+					break
+				}
+			}
+		}
+
+		if !reduced {
+			if n >= len(items) {
+				break
+			}
+			n *= 2
+			if n > len(items) {
+				n = len(items)
+			}
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,46 @@
+package absint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDdmin(t *testing.T) {
+	items := []int{}
+	for i := 0; i < 20; i++ {
+		items = append(items, i)
+	}
+
+	for _, test := range []struct {
+		name     string
+		needed   []int
+		expected []int
+	}{
+		{"single", []int{13}, []int{13}},
+		{"pair", []int{3, 17}, []int{3, 17}},
+		{"adjacent", []int{8, 9, 10}, []int{8, 9, 10}},
+		{"all", items, items},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			runs := 0
+			res := ddmin(items, func(subset []int) bool {
+				runs++
+				has := map[int]bool{}
+				for _, i := range subset {
+					has[i] = true
+				}
+				for _, i := range test.needed {
+					if !has[i] {
+						return false
+					}
+				}
+				return true
+			})
+
+			if !reflect.DeepEqual(res, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, res)
+			}
+			t.Logf("%d runs", runs)
+		})
+	}
+}
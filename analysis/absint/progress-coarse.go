@@ -22,7 +22,7 @@ func CoarseProgress(C AnalysisCtxt) (
 	for {
 		// Find a thread to progress that is not at a communication node and isn't done
 		tid, cl, found := newConf.superloc.Find(func(tid defs.Goro, cl defs.CtrLoc) bool {
-			return !cl.Node().IsCommunicationNode() && !done[tid.Hash()] && !cl.Panicked()
+			return !cl.Node().IsCommunicationNode() && !done[tid.Hash()]
 		})
 
 		if !found {
@@ -31,9 +31,9 @@ func CoarseProgress(C AnalysisCtxt) (
 			log.Printf("Choosing goroutine %s at control location %s", tid, cl)
 		}
 
-		// Keep progressing until hitting a communication node, done, or panic
+		// Keep progressing until hitting a communication node, done, or a crash
 		for !newConf.Threads().GetUnsafe(tid).Node().IsCommunicationNode() &&
-			!done[tid.Hash()] && !newConf.IsPanicked() {
+			!done[tid.Hash()] && !newConf.IsCrashed() {
 
 			succs := newConf.GetSilentSuccessors(C, tid, state)
 			if len(succs) == 0 {
@@ -0,0 +1,289 @@
+package absint
+
+import (
+	"fmt"
+	"go/token"
+	T "go/types"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/cfg"
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	loc "github.com/cs-au-dk/goat/analysis/location"
+	"github.com/cs-au-dk/goat/pkgutil"
+
+	"github.com/fatih/color"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Access is a read or write of a memory location performed by a goroutine.
+type Access struct {
+	Goro  defs.Goro
+	Instr ssa.Instruction
+	Write bool
+	// Locks held by the goroutine when performing the access.
+	Locks Lockset
+}
+
+func (a Access) kind() string {
+	if a.Write {
+		return "Write"
+	}
+	return "Read"
+}
+
+func (a Access) String() string {
+	return fmt.Sprintf("%s by goroutine %s\nInstruction: %s\nSource: %s\nHolding: %s\n",
+		a.kind(), a.Goro, a.Instr,
+		a.Instr.Parent().Prog.Fset.Position(a.Instr.Pos()),
+		a.Locks,
+	)
+}
+
+// Race is a pair of conflicting accesses to the same memory location that
+// may happen in parallel without a common lock protecting them.
+type Race struct {
+	Loc           loc.Location
+	First, Second Access
+}
+
+type Races []Race
+
+func (r Races) String() string {
+	str := "\n"
+	for _, race := range r {
+		str += "Potential data race on: " + race.Loc.String() + "\n"
+		str += race.First.String()
+		str += race.Second.String()
+		str += "\n"
+	}
+	return str
+}
+
+func (r Races) Log() {
+	if len(r) == 0 {
+		fmt.Println(color.GreenString("No data races detected"))
+		return
+	}
+	fmt.Println(r.String())
+}
+
+// A silent region is the sequence of non-synchronizing steps taken by a
+// goroutine from a given control location.
+type silentRegion struct {
+	g  defs.Goro
+	cl defs.CtrLoc
+}
+
+type regionAccesses struct {
+	accesses map[loc.Location]map[ssa.Instruction]bool
+	locks    Lockset
+}
+
+// RaceAnalysis reports pairs of conflicting memory accesses in local packages
+// that may happen in parallel while the accessing goroutines share no lock.
+//
+// The silent steps of each goroutine are replayed using the abstract memory
+// recorded at every configuration of the superlocation graph, such that
+// addresses are resolved through the abstract memory. Two silent regions
+// starting at control locations cl1 and cl2 of goroutines g1 and g2 may
+// happen in parallel if a configuration exists where g1 is at cl1 and g2 is at cl2.
+// Locks are tracked with a lockset analysis over the superlocation graph.
+func RaceAnalysis(C AnalysisCtxt, G SuperlocGraph, result L.Analysis) (res Races) {
+	// Replaying silent steps should not affect metrics or logging.
+	C.Metrics = nil
+	C.Log.Enabled = false
+
+	locksets := LocksetAnalysis(G)
+	regions := make(map[silentRegion]*regionAccesses)
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		state := result.GetUnsafe(conf.Superlocation())
+		g := conf.nextSilentProgress(C, state)
+		if g == nil {
+			return
+		}
+
+		key := silentRegion{g, conf.GetUnsafe(g)}
+		locks := locksets.Get(conf, g)
+		if locks == nil {
+			return
+		}
+
+		region, found := regions[key]
+		if !found {
+			region = &regionAccesses{
+				accesses: make(map[loc.Location]map[ssa.Instruction]bool),
+				locks:    locks,
+			}
+			regions[key] = region
+		} else {
+			region.locks = region.locks.join(locks)
+		}
+
+		for cl, st := range conf.silentFixpoint(C, g, state) {
+			if cl.Panicked() {
+				continue
+			}
+
+			for l, isWrite := range C.memoryAccesses(g, cl, st.Memory()) {
+				if _, found := region.accesses[l]; !found {
+					region.accesses[l] = make(map[ssa.Instruction]bool)
+				}
+				region.accesses[l][cl.Node().(*cfg.SSANode).Instruction()] = isWrite
+			}
+		}
+	})
+
+	checked := make(map[[2]silentRegion]bool)
+	reported := make(map[loc.Location]map[[2]ssa.Instruction]bool)
+
+	G.ForEach(func(conf *AbsConfiguration) {
+		if conf.IsCrashed() {
+			return
+		}
+
+		goros := []defs.Goro{}
+		conf.ForEach(func(g defs.Goro, _ defs.CtrLoc) {
+			goros = append(goros, g)
+		})
+
+		for i, g1 := range goros {
+			r1key := silentRegion{g1, conf.GetUnsafe(g1)}
+			r1, found := regions[r1key]
+			if !found {
+				continue
+			}
+
+			for _, g2 := range goros[i+1:] {
+				r2key := silentRegion{g2, conf.GetUnsafe(g2)}
+				r2, found := regions[r2key]
+				if !found || checked[[2]silentRegion{r1key, r2key}] {
+					continue
+				}
+				checked[[2]silentRegion{r1key, r2key}] = true
+				checked[[2]silentRegion{r2key, r1key}] = true
+
+				if r1.locks.Protects(r2.locks) {
+					continue
+				}
+
+				for l, accs1 := range r1.accesses {
+					accs2, found := r2.accesses[l]
+					if !found {
+						continue
+					}
+
+					for i1, w1 := range accs1 {
+						for i2, w2 := range accs2 {
+							if !w1 && !w2 {
+								continue
+							}
+
+							// Only report each pair of instructions once for each location.
+							if _, found := reported[l]; !found {
+								reported[l] = make(map[[2]ssa.Instruction]bool)
+							}
+							if reported[l][[2]ssa.Instruction{i1, i2}] {
+								continue
+							}
+							reported[l][[2]ssa.Instruction{i1, i2}] = true
+							reported[l][[2]ssa.Instruction{i2, i1}] = true
+
+							res = append(res, Race{
+								Loc:    l,
+								First:  Access{g1, i1, w1, r1.locks},
+								Second: Access{g2, i2, w2, r2.locks},
+							})
+						}
+					}
+				}
+			}
+		}
+	})
+
+	// Ensure consistent ordering
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].String() < res[j].String()
+	})
+
+	return
+}
+
+// Races are printed one at a time, so String is also used for sorting.
+func (r Race) String() string {
+	return Races{r}.String()
+}
+
+// memoryAccesses computes the locations read or written by the instruction at
+// the given control location, if the instruction belongs to a local package.
+// The result maps each location to whether it is written.
+func (C AnalysisCtxt) memoryAccesses(g defs.Goro, cl defs.CtrLoc, mem L.Memory) map[loc.Location]bool {
+	n, ok := cl.Node().(*cfg.SSANode)
+	if !ok || !pkgutil.IsLocal(n.Function()) {
+		return nil
+	}
+
+	var (
+		addr  ssa.Value
+		write bool
+	)
+
+	switch insn := n.Instruction().(type) {
+	case *ssa.Store:
+		addr, write = insn.Addr, true
+	case *ssa.UnOp:
+		if insn.Op != token.MUL {
+			return nil
+		}
+		addr = insn.X
+	case *ssa.MapUpdate:
+		addr, write = insn.Map, true
+	case *ssa.Lookup:
+		if _, isMap := insn.X.Type().Underlying().(*T.Map); !isMap {
+			return nil
+		}
+		addr = insn.X
+	default:
+		return nil
+	}
+
+	av, _ := C.swapWildcard(g, mem, addr)
+	if !av.IsPointer() {
+		return nil
+	}
+
+	res := make(map[loc.Location]bool)
+	for _, l := range av.PointerValue().NonNilEntries() {
+		if isSharedLocation(l) {
+			res[l] = write
+		}
+	}
+
+	return res
+}
+
+// isSharedLocation returns true if the location may be accessed by several
+// goroutines, i.e. it is a global or a heap allocated location.
+func isSharedLocation(l loc.Location) bool {
+	switch l := l.(type) {
+	case loc.GlobalLocation:
+		return true
+	case loc.AllocationSiteLocation:
+		if alloc, ok := l.Site.(*ssa.Alloc); ok {
+			return alloc.Heap
+		}
+		return true
+	case loc.FieldLocation:
+		return isSharedLocation(l.Base)
+	case loc.IndexLocation:
+		return isSharedLocation(l.Base)
+	default:
+		return false
+	}
+}
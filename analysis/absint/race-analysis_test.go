@@ -0,0 +1,215 @@
+package absint
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func expectRaces(expected bool) absIntCommTestFunc {
+	return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+		races := RaceAnalysis(C, G, result)
+		if expected && len(races) == 0 {
+			t.Error("Expected a data race to be reported")
+		} else if !expected && len(races) > 0 {
+			t.Error("Unexpected data races:", races)
+		}
+	}
+}
+
+func TestRaceAnalysis(t *testing.T) {
+	tests := []absIntCommTest{
+		{
+			"unprotected-global",
+			`var x int
+
+			func main() {
+				done := make(chan bool)
+				go func() {
+					x = 1
+					done <- true
+				}()
+				x = 2
+				<-done
+			}`,
+			expectRaces(true),
+		},
+		{
+			"ordered-by-channel",
+			`var x int
+
+			func main() {
+				done := make(chan bool)
+				go func() {
+					x = 1
+					done <- true
+				}()
+				<-done
+				x = 2
+			}`,
+			expectRaces(false),
+		},
+		{
+			"protected-by-mutex",
+			`import "sync"
+
+			var x int
+
+			func main() {
+				var mu sync.Mutex
+				done := make(chan bool)
+				go func() {
+					mu.Lock()
+					x = 1
+					mu.Unlock()
+					done <- true
+				}()
+				mu.Lock()
+				x = 2
+				mu.Unlock()
+				<-done
+			}`,
+			expectRaces(false),
+		},
+		{
+			"different-mutexes",
+			`import "sync"
+
+			var x int
+
+			func main() {
+				var mu1, mu2 sync.Mutex
+				done := make(chan bool)
+				go func() {
+					mu1.Lock()
+					x = 1
+					mu1.Unlock()
+					done <- true
+				}()
+				mu2.Lock()
+				x = 2
+				mu2.Unlock()
+				<-done
+			}`,
+			expectRaces(true),
+		},
+		{
+			"concurrent-readers",
+			`import "sync"
+
+			type S struct {
+				mu sync.RWMutex
+				v  int
+			}
+
+			func main() {
+				s := &S{}
+				done := make(chan bool)
+				go func() {
+					s.mu.RLock()
+					_ = s.v
+					s.mu.RUnlock()
+					done <- true
+				}()
+				s.mu.RLock()
+				_ = s.v
+				s.mu.RUnlock()
+				<-done
+			}`,
+			expectRaces(false),
+		},
+		{
+			"field-write-read-lock",
+			`import "sync"
+
+			type S struct {
+				mu sync.RWMutex
+				v  int
+			}
+
+			func main() {
+				s := &S{}
+				done := make(chan bool)
+				go func() {
+					s.mu.RLock()
+					s.v = 1
+					s.mu.RUnlock()
+					done <- true
+				}()
+				s.mu.RLock()
+				_ = s.v
+				s.mu.RUnlock()
+				<-done
+			}`,
+			expectRaces(true),
+		},
+		{
+			"map-update",
+			`func main() {
+				m := make(map[int]int)
+				done := make(chan bool)
+				go func() {
+					m[0] = 1
+					done <- true
+				}()
+				_ = m[0]
+				<-done
+			}`,
+			expectRaces(true),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
+
+// The nonblocking GoKer programs contain data races (and other non-blocking bugs).
+// Each program is reported as detected if at least one race is found, which is
+// used as a rough measure of recall.
+func TestGoKerNonblockingRaces(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		detected []string
+		missed   []string
+	)
+
+	t.Run("nonblocking", func(t *testing.T) {
+		for _, test := range tu.ListGoKerPackages(t, "../..") {
+			if !strings.Contains(test, "/nonblocking/") {
+				continue
+			}
+
+			test := test
+			t.Run(tu.GoKerTestName(test), func(t *testing.T) {
+				t.Parallel()
+				tu.ParallelHelper(t,
+					tu.LoadExampleAsPackages(t, "../..", test, true),
+					func(loadRes tu.LoadResult) {
+						runTest(t, loadRes, func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+							races := RaceAnalysis(C, G, result)
+							t.Log(races)
+
+							mu.Lock()
+							defer mu.Unlock()
+							if len(races) > 0 {
+								detected = append(detected, test)
+							} else {
+								missed = append(missed, test)
+							}
+						}, PrepareAI().WholeProgram)
+					})
+			})
+		}
+	})
+
+	t.Logf("Races reported in %d of %d nonblocking programs", len(detected), len(detected)+len(missed))
+	if len(missed) > 0 {
+		t.Log("No races reported in:\n", strings.Join(missed, "\n"))
+	}
+}
@@ -116,7 +116,7 @@ FIXPOINT:
 
 			updState := succ.State
 
-			if !s1.IsPanicked() {
+			if !s1.IsCrashed() {
 				// If the memory was updated as a result of the LUB operation we put s1 in the worklist.
 				if lub := updState.MonoJoin(prevState); !lub.Eq(prevState) {
 					analysis = analysis.Update(s1Loc, lub)
@@ -124,7 +124,7 @@ FIXPOINT:
 					worklist.Add(s1)
 				}
 			} else {
-				// Skip processing of panicked configurations
+				// Skip processing of configurations where the program has crashed
 				analysis = analysis.Update(s1Loc, updState)
 			}
 		}
@@ -0,0 +1,356 @@
+package absint
+
+import (
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+)
+
+// Matches the escape sequences used to colorize terminal output.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type htmlGoro struct {
+	Goro     string `json:"goro"`
+	CtrLoc   string `json:"ctrloc"`
+	Function string `json:"function"`
+	Pos      string `json:"pos"`
+	Blocked  bool   `json:"blocked"`
+}
+
+type htmlConfiguration struct {
+	ID    int        `json:"id"`
+	Rank  int        `json:"rank"`
+	Goros []htmlGoro `json:"goros"`
+	// Index of the abstract memory of the configuration in htmlReport.Memories.
+	Memory  int  `json:"memory"`
+	Blocked bool `json:"blocked"`
+	Crashed bool `json:"crashed"`
+	Exited  bool `json:"exited"`
+}
+
+type htmlEdge struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Label string `json:"label"`
+}
+
+type htmlReport struct {
+	Title          string              `json:"title"`
+	Configurations []htmlConfiguration `json:"configurations"`
+	Edges          []htmlEdge          `json:"edges"`
+	// Configurations often share the same abstract memory, so every
+	// distinct memory is only included once.
+	Memories []string `json:"memories"`
+	// Maps blocked configurations to the shortest path from the entry.
+	Paths map[int][]int `json:"paths"`
+}
+
+// WriteHTML writes a self-contained, interactive HTML report of the
+// superlocation graph to w. The abstract memory of every configuration
+// is taken from A, and configurations with blocked goroutines in blocks
+// are highlighted, together with their shortest path from the entry.
+func (SG SuperlocGraph) WriteHTML(w io.Writer, title string, A L.Analysis, blocks Blocks) error {
+	report := htmlReport{
+		Title: title,
+		Paths: make(map[int][]int),
+	}
+
+	ids := make(map[*AbsConfiguration]int)
+	confs := []*AbsConfiguration{}
+	memories := make(map[string]int)
+
+	// Number the configurations in breadth-first order, such that the rank
+	// of a configuration is its distance from the entry.
+	add := func(s *AbsConfiguration, rank int) int {
+		if id, found := ids[s]; found {
+			return id
+		}
+
+		id := len(confs)
+		ids[s] = id
+		confs = append(confs, s)
+
+		mem := ansiEscape.ReplaceAllString(A.GetUnsafe(s.superloc).Memory().String(), "")
+		memIdx, found := memories[mem]
+		if !found {
+			memIdx = len(report.Memories)
+			memories[mem] = memIdx
+			report.Memories = append(report.Memories, mem)
+		}
+
+		blocked := blocks[s.superloc]
+		goros := []htmlGoro{}
+		s.ForEach(func(g defs.Goro, cl defs.CtrLoc) {
+			fun := ""
+			if f := cl.Node().Function(); f != nil {
+				fun = f.String()
+			}
+			_, isBlocked := blocked[g]
+			goros = append(goros, htmlGoro{
+				Goro:     ansiEscape.ReplaceAllString(g.String(), ""),
+				CtrLoc:   ansiEscape.ReplaceAllString(cl.String(), ""),
+				Function: fun,
+				Pos:      cl.PosString(),
+				Blocked:  isBlocked,
+			})
+		})
+
+		report.Configurations = append(report.Configurations, htmlConfiguration{
+			ID:      id,
+			Rank:    rank,
+			Goros:   goros,
+			Memory:  memIdx,
+			Blocked: len(blocked) > 0,
+			Crashed: s.IsCrashed(),
+			Exited:  s.IsExited(),
+		})
+		return id
+	}
+
+	preds := map[int]int{}
+	add(SG.Entry(), 0)
+	for i := 0; i < len(confs); i++ {
+		// Visit successors in a fixed order, such that configurations are
+		// numbered and laid out the same way in every run.
+		succs := make([]Successor, 0, len(confs[i].Successors))
+		for _, succ := range confs[i].Successors {
+			succs = append(succs, succ)
+		}
+		sort.Slice(succs, func(a, b int) bool {
+			ta, tb := succs[a].Transition().String(), succs[b].Transition().String()
+			if ta != tb {
+				return ta < tb
+			}
+			return succs[a].Configuration().Superlocation().Hash() < succs[b].Configuration().Superlocation().Hash()
+		})
+
+		for _, succ := range succs {
+			next := succ.Configuration()
+			_, seen := ids[next]
+			to := add(next, report.Configurations[i].Rank+1)
+			if !seen {
+				preds[to] = i
+			}
+
+			report.Edges = append(report.Edges, htmlEdge{
+				From:  i,
+				To:    to,
+				Label: ansiEscape.ReplaceAllString(succ.Transition().String(), ""),
+			})
+		}
+	}
+
+	for _, conf := range report.Configurations {
+		if !conf.Blocked {
+			continue
+		}
+
+		path := []int{conf.ID}
+		for pred, ok := preds[conf.ID]; ok; pred, ok = preds[pred] {
+			path = append(path, pred)
+		}
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+		report.Paths[conf.ID] = path
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; display: flex; height: 100vh; font-family: sans-serif; font-size: 13px; }
+#graph { flex: 1; background: #fafafa; cursor: grab; }
+#graph.panning { cursor: grabbing; }
+#side { width: 420px; overflow: auto; border-left: 1px solid #ccc; padding: 8px; box-sizing: border-box; }
+#side h2 { font-size: 15px; margin: 8px 0; }
+pre { white-space: pre-wrap; word-break: break-all; font-size: 12px; background: #f0f0f0; padding: 4px; }
+.conf rect { fill: #ffd581; stroke: #996; }
+.conf.blocked rect { fill: #cc0000; }
+.conf.blocked text { fill: white; }
+.conf.crashed rect { fill: #dd493b; }
+.conf.exited rect { fill: #b0c4de; }
+.conf.selected rect { stroke: #0050ff; stroke-width: 3; }
+.conf.path rect { stroke: #0050ff; stroke-width: 3; stroke-dasharray: 4 2; }
+.conf text { font-size: 12px; pointer-events: none; }
+.edge { fill: none; stroke: #888; }
+.edge.path { stroke: #0050ff; stroke-width: 3; }
+.goro.blocked summary { color: #cc0000; font-weight: bold; }
+a { color: #0050ff; cursor: pointer; }
+</style>
+</head>
+<body>
+<svg id="graph"><defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0L10,5L0,10z" fill="#888"/></marker></defs><g id="view"></g></svg>
+<div id="side"></div>
+<script>
+const report = {{.}};
+const W = 120, H = 36, GAP_X = 30, GAP_Y = 60;
+const NS = "http://www.w3.org/2000/svg";
+const svg = document.getElementById("graph");
+const view = document.getElementById("view");
+const side = document.getElementById("side");
+
+function el(tag, attrs, parent) {
+	const e = document.createElementNS(NS, tag);
+	for (const k in attrs) e.setAttribute(k, attrs[k]);
+	if (parent) parent.appendChild(e);
+	return e;
+}
+
+// Lay out configurations in rows according to their distance from the entry.
+const rows = [];
+const pos = [];
+for (const c of report.configurations) {
+	(rows[c.rank] = rows[c.rank] || []).push(c.id);
+}
+const width = Math.max(...rows.map(r => r.length)) * (W + GAP_X);
+rows.forEach((row, rank) => row.forEach((id, i) => {
+	pos[id] = {x: (width - row.length * (W + GAP_X)) / 2 + i * (W + GAP_X), y: rank * (H + GAP_Y)};
+}));
+
+const edgeEls = report.edges.map(e => {
+	const a = pos[e.from], b = pos[e.to];
+	const x1 = a.x + W / 2, y1 = a.y + H, x2 = b.x + W / 2, y2 = b.y;
+	let d;
+	if (y2 > y1) {
+		d = "M" + x1 + "," + y1 + " C" + x1 + "," + (y1 + GAP_Y / 2) + " " + x2 + "," + (y2 - GAP_Y / 2) + " " + x2 + "," + y2;
+	} else {
+		// Back edges loop around the right side of the configurations.
+		const bend = Math.max(x1, x2) + W;
+		d = "M" + (a.x + W) + "," + (a.y + H / 2) + " C" + bend + "," + a.y + " " + bend + "," + b.y + " " + (b.x + W) + "," + (b.y + H / 2);
+	}
+	const p = el("path", {class: "edge", d: d, "marker-end": "url(#arrow)"}, view);
+	el("title", {}, p).textContent = e.label;
+	return p;
+});
+
+const confEls = report.configurations.map(c => {
+	const g = el("g", {class: "conf" + (c.blocked ? " blocked" : "") + (c.crashed ? " crashed" : "") + (c.exited ? " exited" : ""),
+		transform: "translate(" + pos[c.id].x + "," + pos[c.id].y + ")"}, view);
+	el("rect", {width: W, height: H, rx: 4}, g);
+	const t = el("text", {x: 6, y: 15}, g);
+	t.textContent = "#" + c.id;
+	const t2 = el("text", {x: 6, y: 30}, g);
+	t2.textContent = c.goros.length + " goroutine" + (c.goros.length == 1 ? "" : "s");
+	el("title", {}, g).textContent = c.goros.map(g => g.goro + ": " + g.ctrloc).join("\n");
+	g.addEventListener("click", ev => { ev.stopPropagation(); select(c.id); });
+	return g;
+});
+
+function clearPath() {
+	for (const e of confEls) e.classList.remove("path", "selected");
+	for (const e of edgeEls) e.classList.remove("path");
+}
+
+function showPath(id) {
+	clearPath();
+	const path = report.paths[id] || [];
+	for (const c of path) confEls[c].classList.add("path");
+	for (let i = 0; i + 1 < path.length; i++) {
+		report.edges.forEach((e, j) => {
+			if (e.from == path[i] && e.to == path[i + 1]) edgeEls[j].classList.add("path");
+		});
+	}
+	select(id, true);
+}
+
+function text(tag, str, parent) {
+	const e = document.createElement(tag);
+	e.textContent = str;
+	parent.appendChild(e);
+	return e;
+}
+
+function overview() {
+	clearPath();
+	side.innerHTML = "";
+	text("h2", report.title, side);
+	text("p", report.configurations.length + " configurations, " + report.edges.length + " transitions.", side);
+	const blocked = report.configurations.filter(c => c.blocked);
+	text("h2", blocked.length + " blocked configurations", side);
+	for (const c of blocked) {
+		const p = document.createElement("p");
+		const a = text("a", "#" + c.id, p);
+		a.onclick = () => showPath(c.id);
+		p.appendChild(document.createTextNode(": " + c.goros.filter(g => g.blocked).map(g => g.ctrloc).join(", ")));
+		side.appendChild(p);
+	}
+}
+
+function select(id, keepPath) {
+	if (!keepPath) clearPath();
+	confEls[id].classList.add("selected");
+	const c = report.configurations[id];
+	side.innerHTML = "";
+	text("a", "← Overview", side).onclick = overview;
+	text("h2", "Configuration #" + id + (c.blocked ? " (blocked)" : "") + (c.crashed ? " (crashed)" : "") + (c.exited ? " (exited)" : ""), side);
+	if (c.blocked) {
+		text("a", "Show blocking path", side).onclick = () => showPath(id);
+	}
+
+	text("h2", "Goroutines", side);
+	for (const g of c.goros) {
+		const d = document.createElement("details");
+		d.className = "goro" + (g.blocked ? " blocked" : "");
+		d.open = g.blocked;
+		text("summary", g.goro, d);
+		text("pre", g.ctrloc + "\n" + g.function + (g.pos ? "\n" + g.pos : ""), d);
+		side.appendChild(d);
+	}
+
+	const succs = report.edges.filter(e => e.from == id);
+	text("h2", "Successors", side);
+	for (const e of succs) {
+		const p = document.createElement("p");
+		text("a", "#" + e.to, p).onclick = () => select(e.to);
+		p.appendChild(document.createTextNode(": " + e.label));
+		side.appendChild(p);
+	}
+
+	const m = document.createElement("details");
+	text("summary", "Abstract memory", m);
+	text("pre", report.memories[c.memory], m);
+	side.appendChild(m);
+}
+
+// Zooming with the mouse wheel and panning by dragging.
+let scale = 1, tx = 20, ty = 20;
+function update() { view.setAttribute("transform", "translate(" + tx + "," + ty + ") scale(" + scale + ")"); }
+svg.addEventListener("wheel", ev => {
+	ev.preventDefault();
+	const f = Math.exp(-ev.deltaY / 500);
+	const r = svg.getBoundingClientRect();
+	const mx = ev.clientX - r.left, my = ev.clientY - r.top;
+	tx = mx - (mx - tx) * f;
+	ty = my - (my - ty) * f;
+	scale *= f;
+	update();
+}, {passive: false});
+let drag = null;
+svg.addEventListener("mousedown", ev => { drag = {x: ev.clientX - tx, y: ev.clientY - ty}; svg.classList.add("panning"); });
+window.addEventListener("mousemove", ev => {
+	if (!drag) return;
+	tx = ev.clientX - drag.x;
+	ty = ev.clientY - drag.y;
+	update();
+});
+window.addEventListener("mouseup", () => { drag = null; svg.classList.remove("panning"); });
+
+// Fit the graph to the window initially.
+const r = svg.getBoundingClientRect();
+scale = Math.min(1, r.width / (width + W), r.height / (rows.length * (H + GAP_Y)));
+update();
+overview();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,78 @@
+package absint
+
+import (
+	"strings"
+	"testing"
+
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	tu "github.com/cs-au-dk/goat/testutil"
+)
+
+func TestWriteHTML(t *testing.T) {
+	expectHTML := func(expected ...string) absIntCommTestFunc {
+		return func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			blocks := BlockAnalysis(C, G, result)
+			if len(blocks) == 0 {
+				t.Fatal("Expected a blocked goroutine")
+			}
+
+			write := func() string {
+				var sb strings.Builder
+				if err := G.WriteHTML(&sb, "report-title", result, blocks); err != nil {
+					t.Fatal(err)
+				}
+				return sb.String()
+			}
+
+			html := write()
+			for _, str := range expected {
+				if !strings.Contains(html, str) {
+					t.Errorf("Expected report to contain %q:\n%s", str, html)
+				}
+			}
+			if strings.Contains(html, "\x1b[") {
+				t.Errorf("Expected report without color codes:\n%s", html)
+			}
+
+			// Configurations must be numbered the same way every time.
+			for i := 0; i < 5; i++ {
+				if again := write(); again != html {
+					t.Fatal("Expected the same report when writing it again")
+				}
+			}
+		}
+	}
+
+	tests := []absIntCommTest{
+		{
+			"select",
+			`func main() {
+				ch1 := make(chan int)
+				ch2 := make(chan int)
+				go func() {
+					ch1 <- 10
+				}()
+				go func() {
+					ch2 <- 20
+				}()
+				select {
+				case <-ch1:
+				case <-ch2:
+				}
+				<-ch1
+			}`,
+			expectHTML(
+				"<title>report-title</title>",
+				`"blocked":true`,
+				`"crashed":false`,
+				`"paths":{"`,
+			),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runEmbeddedTest(t, test)
+		})
+	}
+}
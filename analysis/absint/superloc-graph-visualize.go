@@ -21,6 +21,7 @@ func (SG SuperlocGraph) Visualize(blocks Blocks) {
 	}
 
 	G := &dot.DotGraph{
+		Name: "superlocation-graph",
 		Options: map[string]string{
 			"minlen":  fmt.Sprint(opts.Minlen()),
 			"nodesep": fmt.Sprint(opts.Nodesep()),
@@ -165,9 +166,9 @@ func (SG SuperlocGraph) Visualize(blocks Blocks) {
 		for _, succ := range conf.Successors {
 			conf1 := succ.Configuration()
 
-			if condensePanickedConfigurations && conf1.IsPanicked() {
+			if condenseCrashedConfigurations && conf1.IsCrashed() {
 				cluster := configurationToCluster[conf]
-				cluster.Cluster.Attrs["label"] = "Panics"
+				cluster.Cluster.Attrs["label"] = "Crashes"
 				continue
 			}
 
@@ -131,7 +131,7 @@ func (G SuperlocGraph) Terminals() map[*AbsConfiguration]struct{} {
 func (G SuperlocGraph) ToGraph() graph.Graph[*AbsConfiguration] {
 	return graph.OfHashable(func(conf *AbsConfiguration) (res []*AbsConfiguration) {
 		for _, succ := range conf.GetSuccessorMap() {
-			if next := succ.Configuration(); !next.IsPanicked() {
+			if next := succ.Configuration(); !next.IsCrashed() {
 				res = append(res, next)
 			}
 		}
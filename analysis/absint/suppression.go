@@ -0,0 +1,358 @@
+package absint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cs-au-dk/goat/analysis/cfg"
+	"github.com/cs-au-dk/goat/analysis/defs"
+	"github.com/cs-au-dk/goat/pkgutil"
+	"github.com/cs-au-dk/goat/utils"
+
+	"github.com/fatih/color"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Finding is a description of a reported blocked goroutine that is stable
+// across runs, and independent of line numbers.
+type Finding struct {
+	Fingerprint string `json:"fingerprint"`
+	// Blocked operation and its position at the time of the report.
+	Operation string `json:"operation"`
+	Position  string `json:"position"`
+	// Spawn sites of the blocked goroutine, from the root goroutine.
+	Spawns []string `json:"spawns"`
+	// The goroutine is blocked when the program exits, and is not deadlocked.
+	Leaked bool `json:"leaked,omitempty"`
+}
+
+// Report is the format of files written with -report and read with -baseline.
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+// BlockedFinding computes the finding for goroutine g blocked at superlocation sl.
+// The fingerprint is derived from the blocked operation and the spawn sites
+// of the goroutine (see describeOperation), and whether the goroutine is
+// leaked at program exit, such that a baselined leak does not suppress a
+// deadlock at the same operation.
+func BlockedFinding(sl defs.Superloc, g defs.Goro) Finding {
+	cl := sl.GetUnsafe(g)
+	fset := cl.Node().Function().Prog.Fset
+
+	f := Finding{
+		Operation: cl.Node().String(),
+		Position:  fset.Position(cl.Node().Pos()).String(),
+		Leaked:    hasExited(sl),
+	}
+
+	kind := "blocked"
+	if f.Leaked {
+		kind = "leaked"
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", kind, describeOperation(cl.Node()))
+	for g := g; g != nil; g = g.Parent() {
+		spawn := g.CtrLoc().Node()
+		fmt.Fprintf(h, "%s\n", describeOperation(spawn))
+		f.Spawns = append([]string{fset.Position(spawn.Pos()).String()}, f.Spawns...)
+	}
+	f.Fingerprint = hex.EncodeToString(h.Sum(nil))[:16]
+
+	return f
+}
+
+// describeOperation describes the operation at a node by its enclosing
+// function, the kind of operation, its line relative to the start of the
+// function, and the source names of the primitives it uses. The description
+// does not include SSA register names or absolute positions, which change
+// when unrelated code is edited.
+func describeOperation(n cfg.Node) string {
+	fun := n.Function()
+	fset := fun.Prog.Fset
+	line := fset.Position(n.Pos()).Line - fset.Position(fun.Pos()).Line
+
+	names := []string{}
+	for _, prim := range cfg.CommunicationPrimitivesOf(n) {
+		names = append(names, sourceName(prim))
+	}
+
+	return fmt.Sprintf("%s: %s +%d (%s)", fun, operationKind(n), line, strings.Join(names, ", "))
+}
+
+// operationKind names the kind of operation at a node, e.g. "send", or the
+// name of the called function.
+func operationKind(n cfg.Node) string {
+	switch n := n.(type) {
+	case *cfg.Select:
+		return "select"
+	case *cfg.BuiltinCall:
+		return n.Builtin().Name()
+	case *cfg.Waiting:
+		return "wait"
+	case *cfg.Waking:
+		return "wake"
+	case interface{ Instruction() ssa.Instruction }:
+		switch insn := n.Instruction().(type) {
+		case *ssa.Send:
+			return "send"
+		case *ssa.UnOp:
+			if insn.Op == token.ARROW {
+				return "receive"
+			}
+		case *ssa.Go:
+			return "go"
+		case ssa.CallInstruction:
+			if callee := insn.Common().StaticCallee(); callee != nil {
+				return callee.Name()
+			} else if insn.Common().IsInvoke() {
+				return insn.Common().Method.Name()
+			}
+			return "call"
+		}
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+// sourceName describes a value by the names in the source code it is
+// derived from, e.g. "s.mu" for the address of field mu of variable s, or
+// by its type if it has no name.
+func sourceName(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Parameter, *ssa.FreeVar, *ssa.Global:
+		return v.Name()
+	case *ssa.Alloc:
+		if v.Comment != "" {
+			return v.Comment
+		}
+	case *ssa.Phi:
+		if v.Comment != "" {
+			return v.Comment
+		}
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			return sourceName(v.X)
+		}
+	case *ssa.ChangeType:
+		return sourceName(v.X)
+	case *ssa.MakeInterface:
+		return sourceName(v.X)
+	case *ssa.FieldAddr:
+		if ptr, ok := v.X.Type().Underlying().(*types.Pointer); ok {
+			if st, ok := ptr.Elem().Underlying().(*types.Struct); ok {
+				return sourceName(v.X) + "." + st.Field(v.Field).Name()
+			}
+		}
+	case *ssa.Field:
+		if st, ok := v.X.Type().Underlying().(*types.Struct); ok {
+			return sourceName(v.X) + "." + st.Field(v.Field).Name()
+		}
+	}
+	return v.Type().String()
+}
+
+// LoadReport reads a report written with WriteReport.
+func LoadReport(path string) (r Report, err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(contents, &r); err != nil {
+		return r, fmt.Errorf("unable to parse report %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Suppressor hides known findings. A blocked goroutine is suppressed if:
+//
+// 1. its blocked operation, or one of the go statements that spawned it, is
+// annotated with a `//goat:ignore <reason>` comment,
+//
+// 2. its blocked operation is covered by a suppression in the project
+// configuration file, or
+//
+// 3. its fingerprint is in the baseline report.
+//
+// Suppressors are used across all analysis runs of a single invocation, and
+// keep track of which suppressions are used. Analysis runs that time out may
+// still filter their findings, so the suppressor is guarded by a mutex.
+type Suppressor struct {
+	mu sync.Mutex
+
+	directives pkgutil.IgnoreDirectives
+	config     []utils.Suppression
+	baseline   map[string]Finding
+
+	// Fingerprints of suppressed and reported findings
+	suppressed map[string]Finding
+	reported   map[string]Finding
+
+	usedDirectives map[*pkgutil.IgnoreDirective]bool
+	usedConfig     map[int]bool
+	usedBaseline   map[string]bool
+}
+
+func NewSuppressor(directives pkgutil.IgnoreDirectives, config []utils.Suppression, baseline Report) *Suppressor {
+	s := &Suppressor{
+		directives:     directives,
+		config:         config,
+		baseline:       make(map[string]Finding),
+		suppressed:     make(map[string]Finding),
+		reported:       make(map[string]Finding),
+		usedDirectives: make(map[*pkgutil.IgnoreDirective]bool),
+		usedConfig:     make(map[int]bool),
+		usedBaseline:   make(map[string]bool),
+	}
+	for _, f := range baseline.Findings {
+		s.baseline[f.Fingerprint] = f
+	}
+	return s
+}
+
+// Filter removes the suppressed blocked goroutines.
+func (s *Suppressor) Filter(blocks Blocks) Blocks {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return blocks.Filter(func(sl defs.Superloc, g defs.Goro) bool {
+		f := BlockedFinding(sl, g)
+		if s.isSuppressed(sl, g, f) {
+			s.suppressed[f.Fingerprint] = f
+			return false
+		}
+
+		s.reported[f.Fingerprint] = f
+		return true
+	})
+}
+
+// isSuppressed returns true if any suppression matches the blocked
+// goroutine. Every matching suppression is marked as used, such that
+// suppressions that overlap with others are not reported as stale.
+func (s *Suppressor) isSuppressed(sl defs.Superloc, g defs.Goro, f Finding) bool {
+	cl := sl.GetUnsafe(g)
+	fset := cl.Node().Function().Prog.Fset
+	pos := fset.Position(cl.Node().Pos())
+	suppressed := false
+
+	// Check the blocked operation, and the go statements spawning the goroutine.
+	for _, n := range append([]defs.CtrLoc{cl}, spawnSites(g)...) {
+		if d, found := s.directives.Lookup(fset.Position(n.Node().Pos())); found {
+			s.usedDirectives[d] = true
+			suppressed = true
+		}
+	}
+
+	for i, sup := range s.config {
+		if sup.Matches(pos) {
+			s.usedConfig[i] = true
+			suppressed = true
+		}
+	}
+
+	if _, found := s.baseline[f.Fingerprint]; found {
+		s.usedBaseline[f.Fingerprint] = true
+		suppressed = true
+	}
+
+	return suppressed
+}
+
+// spawnSites returns the control locations of the go statements that spawned g.
+func spawnSites(g defs.Goro) (res []defs.CtrLoc) {
+	for ; g != nil && !g.IsRoot(); g = g.Parent() {
+		res = append(res, g.CtrLoc())
+	}
+	return
+}
+
+// WriteReport writes the reported and baselined findings, to be used as a
+// baseline in later runs.
+func (s *Suppressor) WriteReport(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := Report{Findings: []Finding{}}
+	for _, f := range s.reported {
+		r.Findings = append(r.Findings, f)
+	}
+	for fp := range s.usedBaseline {
+		r.Findings = append(r.Findings, s.baseline[fp])
+	}
+	sort.Slice(r.Findings, func(i, j int) bool {
+		return r.Findings[i].Fingerprint < r.Findings[j].Fingerprint
+	})
+
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0o644)
+}
+
+// Stale returns descriptions of the suppressions that did not match any finding.
+func (s *Suppressor) Stale() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stale()
+}
+
+func (s *Suppressor) stale() (res []string) {
+	s.directives.ForEach(func(d *pkgutil.IgnoreDirective) {
+		if !s.usedDirectives[d] {
+			res = append(res, fmt.Sprintf("//goat:ignore %s at %s", d.Reason, d.Pos))
+		}
+	})
+	for i, sup := range s.config {
+		if !s.usedConfig[i] {
+			res = append(res, fmt.Sprintf("Configured suppression for %s:%d (%s)", sup.File, sup.Line, sup.Reason))
+		}
+	}
+	for fp, f := range s.baseline {
+		if !s.usedBaseline[fp] {
+			res = append(res, fmt.Sprintf("Baseline finding %s: %s at %s", fp, f.Operation, f.Position))
+		}
+	}
+	sort.Strings(res)
+	return
+}
+
+func (s *Suppressor) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	leaks := 0
+	for _, f := range s.suppressed {
+		if f.Leaked {
+			leaks++
+		}
+	}
+
+	str := fmt.Sprintf("Suppressed findings: %d\n", len(s.suppressed)-leaks)
+	if leaks > 0 {
+		str += fmt.Sprintf("Suppressed goroutines leaked at program exit: %d\n", leaks)
+	}
+	if stale := s.stale(); len(stale) > 0 {
+		str += "Suppressions that no longer match any finding:\n  " + strings.Join(stale, "\n  ") + "\n"
+	}
+	return str
+}
+
+func (s *Suppressor) Log() {
+	if len(s.Stale()) > 0 {
+		fmt.Println(color.YellowString("Warning:"), s.String())
+	} else {
+		fmt.Println(s.String())
+	}
+}
@@ -0,0 +1,118 @@
+package absint
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cs-au-dk/goat/analysis/defs"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
+	"github.com/cs-au-dk/goat/pkgutil"
+	tu "github.com/cs-au-dk/goat/testutil"
+	"github.com/cs-au-dk/goat/utils"
+)
+
+func TestSuppressorBaseline(t *testing.T) {
+	runEmbeddedTest(t, absIntCommTest{
+		"baseline",
+		`func main() {
+			ch := make(chan int)
+			go func() {
+				ch <- 1
+			}()
+			go func() {
+				ch <- 2
+			}()
+			<-ch
+		}`,
+		func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+			blocks := BlockAnalysis(C, G, result)
+			if len(blocks) == 0 {
+				t.Fatal("Expected blocked goroutines")
+			}
+
+			// Fingerprints are deterministic
+			baseline := Report{}
+			seen := map[string]bool{}
+			config := []utils.Suppression{}
+			blocks.ForEach(func(sl defs.Superloc, gs map[defs.Goro]struct{}) {
+				for g := range gs {
+					// Configured suppressions overlap with the baseline.
+					pos := C.LoadRes.Prog.Fset.Position(sl.GetUnsafe(g).Node().Pos())
+					config = append(config, utils.Suppression{File: pos.Filename, Line: pos.Line})
+
+					f := BlockedFinding(sl, g)
+					if f2 := BlockedFinding(sl, g); f.Fingerprint != f2.Fingerprint {
+						t.Errorf("Fingerprints differ: %v %v", f, f2)
+					}
+					// main returns after receiving from one of the goroutines.
+					if !f.Leaked {
+						t.Errorf("Expected %v to be leaked at program exit", f)
+					}
+					if !seen[f.Fingerprint] {
+						seen[f.Fingerprint] = true
+						baseline.Findings = append(baseline.Findings, f)
+					}
+				}
+			})
+
+			if len(baseline.Findings) != 2 {
+				t.Errorf("Expected 2 distinct findings, got %v", baseline.Findings)
+			}
+
+			stale := Finding{Fingerprint: "0000000000000000", Operation: "stale"}
+			baseline.Findings = append(baseline.Findings, stale)
+
+			s := NewSuppressor(pkgutil.IgnoreDirectives{}, config, baseline)
+			if remaining := s.Filter(blocks); len(remaining) > 0 {
+				t.Errorf("Expected all findings to be suppressed by the baseline:%v", remaining)
+			}
+
+			if stale := s.Stale(); len(stale) != 1 {
+				t.Errorf("Expected one stale baseline finding, got %v", stale)
+			}
+		},
+	})
+}
+
+func TestFindingFingerprintStable(t *testing.T) {
+	fingerprints := func(content string) (res []string) {
+		runEmbeddedTest(t, absIntCommTest{
+			"fingerprints",
+			content,
+			func(t *testing.T, C AnalysisCtxt, result L.Analysis, G SuperlocGraph, _ tu.NotesManager) {
+				BlockAnalysis(C, G, result).ForEach(func(sl defs.Superloc, gs map[defs.Goro]struct{}) {
+					for g := range gs {
+						res = append(res, BlockedFinding(sl, g).Fingerprint)
+					}
+				})
+			},
+		})
+		sort.Strings(res)
+		return
+	}
+
+	original := fingerprints(`func main() {
+			ready := true
+			ch := make(chan int)
+			go func() {
+				ch <- 1
+			}()
+			_ = ready
+		}`)
+
+	// The edited program moves main, and renames the SSA registers of main.
+	edited := fingerprints(`var unrelated int
+
+		func main() {
+			x := make(chan int, 1); x <- 1
+			ch := make(chan int)
+			go func() {
+				ch <- 1
+			}()
+		}`)
+
+	if len(original) == 0 || !reflect.DeepEqual(original, edited) {
+		t.Errorf("Expected the same fingerprints, got %v and %v", original, edited)
+	}
+}
@@ -110,10 +110,16 @@ var GoroTermination = struct {
 	EXIT_ROOT           _TERMINATION_CAUSE
 	PERMANENTLY_BLOCKED _TERMINATION_CAUSE
 	INFINITE_LOOP       _TERMINATION_CAUSE
+	// A panic escaped the root function of the goroutine, crashing the program.
+	CRASHED _TERMINATION_CAUSE
+	// The goroutine ended the program by returning from main or calling os.Exit.
+	EXIT_PROGRAM _TERMINATION_CAUSE
 }{
 	EXIT_ROOT:           0,
 	PERMANENTLY_BLOCKED: 1,
 	INFINITE_LOOP:       2,
+	CRASHED:             3,
+	EXIT_PROGRAM:        4,
 }
 
 // Interface for synthetic nodes involving channel makechan SSA values.
@@ -351,8 +357,13 @@ func syntheticId(config SynthConfig) string {
 		config.IdSuffixes = append([]string{"block-exit-defer"}, config.IdSuffixes...)
 	case SynthTypes.TERMINATE_GORO:
 		suffix := ""
-		if config.TerminationCause == GoroTermination.PERMANENTLY_BLOCKED {
+		switch config.TerminationCause {
+		case GoroTermination.PERMANENTLY_BLOCKED:
 			suffix = "-blocked"
+		case GoroTermination.CRASHED:
+			suffix = "-crashed"
+		case GoroTermination.EXIT_PROGRAM:
+			suffix = "-exit-program"
 		}
 		config.IdSuffixes = append([]string{"terminate-goro" + suffix}, config.IdSuffixes...)
 	case SynthTypes.FUNCTION_ENTRY:
@@ -959,6 +970,10 @@ func (n *TerminateGoro) String() string {
 		return "[ ⛔ ]"
 	case GoroTermination.INFINITE_LOOP:
 		return "[ \u21B7 ]"
+	case GoroTermination.CRASHED:
+		return "[ \u2620 ]"
+	case GoroTermination.EXIT_PROGRAM:
+		return "[ \u23FB ]"
 	default:
 		log.Fatal("Unrecognized goroutine exit cause")
 		os.Exit(1)
@@ -16,6 +16,7 @@ import (
 /* Creates a Dot Graph representing the program CFG */
 func (cfg *Cfg) Visualize(result *pointer.Result) {
 	G := &dot.DotGraph{
+		Name: "cfg",
 		Options: map[string]string{
 			"minlen":  fmt.Sprint(opts.Minlen()),
 			"nodesep": fmt.Sprint(opts.Nodesep()),
@@ -106,6 +107,7 @@ func (cfg *Cfg) VisualizeFunc(fun string) {
 }
 func (cfg *Cfg) VisualizeFunction(fun *ssa.Function) {
 	G := &dot.DotGraph{
+		Name: "cfg-" + fun.String(),
 		Options: map[string]string{
 			"minlen":  fmt.Sprint(opts.Minlen()),
 			"nodesep": fmt.Sprint(opts.Nodesep()),
@@ -15,6 +15,9 @@ type ctrlocContext struct {
 	panicked bool
 	// True if the goroutine is unwinding its defer stack due to runtime.Goexit
 	exiting bool
+	// True if the function is a deferred call made while unwinding the
+	// defer stack due to a panic, such that calls to recover stop the panic.
+	recovering bool
 }
 
 // NOTE: This struct is used as a map key in abs-config. It is therefore
@@ -31,6 +34,7 @@ func (factory) CtrLoc(n cfg.Node, root *ssa.Function, panicked bool) CtrLoc {
 			root,
 			panicked,
 			false,
+			false,
 		},
 	}
 }
@@ -60,6 +64,20 @@ func (cl CtrLoc) WithExiting(exiting bool) CtrLoc {
 	return cl
 }
 
+func (cl CtrLoc) WithPanicked(panicked bool) CtrLoc {
+	cl.context.panicked = panicked
+	return cl
+}
+
+func (cl CtrLoc) Recovering() bool {
+	return cl.context.recovering
+}
+
+func (cl CtrLoc) WithRecovering(recovering bool) CtrLoc {
+	cl.context.recovering = recovering
+	return cl
+}
+
 func (cl CtrLoc) PosString() string {
 	var nearestPos func(cfg.Node) cfg.Node
 	nearestPos = func(n cfg.Node) cfg.Node {
@@ -105,6 +123,7 @@ func (cl CtrLoc) Hash() uint32 {
 		phasher.Hash(cl.context.root),
 		bhash(cl.context.panicked),
 		bhash(cl.context.exiting),
+		bhash(cl.context.recovering),
 	)
 }
 
@@ -125,6 +144,9 @@ func (cl CtrLoc) String() string {
 	if cl.Exiting() {
 		str += "[⇓]"
 	}
+	if cl.Recovering() {
+		str += "[↺]"
+	}
 	return str
 }
 
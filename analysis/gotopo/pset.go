@@ -40,34 +40,96 @@ func blacklistPrimitive(p ssa.Value) bool {
 	// return false
 }
 
+// isSyncPrimitive returns true if the type is a (pointer to a) sync.Mutex,
+// sync.RWMutex, sync.Cond or a sync.Locker.
+func isSyncPrimitive(t T.Type) bool {
+	return utils.IsNamedType(t, "sync", "Mutex") ||
+		utils.IsNamedType(t, "sync", "RWMutex") ||
+		utils.IsNamedType(t, "sync", "Cond") ||
+		utils.IsNamedType(t, "sync", "Locker")
+}
+
+// isSyncValue returns true if the type is a sync.Mutex, sync.RWMutex
+// or sync.Cond, and not a pointer to one.
+func isSyncValue(t T.Type) bool {
+	if _, isPtr := t.(*T.Pointer); isPtr {
+		return false
+	}
+	return utils.IsNamedType(t, "sync", "Mutex") ||
+		utils.IsNamedType(t, "sync", "RWMutex") ||
+		utils.IsNamedType(t, "sync", "Cond")
+}
+
+// hasSyncPrimitive returns true if a value of the given type contains
+// a mutex or a condition variable, e. g. as a (nested) struct field.
+func hasSyncPrimitive(t T.Type) bool {
+	if isSyncValue(t) {
+		return true
+	}
+
+	switch t := t.Underlying().(type) {
+	case *T.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasSyncPrimitive(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *T.Array:
+		return hasSyncPrimitive(t.Elem())
+	}
+	return false
+}
+
+// isNewCond returns true if the call is a call to sync.NewCond.
+func isNewCond(call ssa.CallInstruction) bool {
+	callee := call.Common().StaticCallee()
+	return callee != nil && callee.Pkg != nil &&
+		callee.Pkg.Pkg.Path() == "sync" && callee.Name() == "NewCond"
+}
+
+// Get the allocation sites of the channels, mutexes and condition variables
+// that v may point to. Mutexes are identified by the allocation site of the
+// enclosing struct, like in the abstract interpreter.
+// Primitives allocated in GOROOT are discarded.
 func getPrimitives(v ssa.Value, pt *pointer.Result) (res map[ssa.Value]struct{}) {
 	res = make(map[ssa.Value]struct{})
 
 	var rec func(v ssa.Value)
 	rec = func(v ssa.Value) {
-		for _, l := range pt.Queries[v].PointsTo().Labels() {
+		ptr, ok := pt.Queries[v]
+		if !ok {
+			return
+		}
+		isSync := isSyncPrimitive(v.Type())
+
+		for _, l := range ptr.PointsTo().Labels() {
 			p := l.Value()
-			if p == nil || blacklistPrimitive(p) {
+			if p == nil {
+				continue
+			}
+
+			if blacklistPrimitive(p) {
+				if isSync {
+					// Condition variables made with sync.NewCond are allocated in GOROOT.
+					// They are instead identified by the call to sync.NewCond.
+					for site := range condSites(p, ptr, pt) {
+						res[site] = struct{}{}
+					}
+				}
 				continue
 			}
 
-			// switch pi := p.(type) {
-			// // case *ssa.MakeInterface:
-			// // 	rec(pi.X)
-			// // case *ssa.ChangeType:
-			// // 	rec(pi.X)
-			// default:
-			if _, ok := p.Type().Underlying().(*T.Chan); ok {
-				// ||
-				// 	utils.IsNamedType(p.Type(), "sync", "Mutex") ||
-				// 	utils.IsNamedType(p.Type(), "sync", "RWMutex") ||
-				// 	utils.IsNamedType(p.Type(), "sync", "Cond") ||
-				/* NOTE: Why was this restriction necessary?
-				Most mutexes come from struct fields. */
-				// true {
-				res[p] = struct{}{}
+			switch pi := p.(type) {
+			case *ssa.MakeInterface:
+				// sync.Locker values point to the lock through an interface.
+				if isSync {
+					rec(pi.X)
+				}
+			default:
+				if _, ok := p.Type().Underlying().(*T.Chan); ok || isSync {
+					res[p] = struct{}{}
+				}
 			}
-			// }
 		}
 	}
 
@@ -75,6 +137,43 @@ func getPrimitives(v ssa.Value, pt *pointer.Result) (res map[ssa.Value]struct{})
 	return
 }
 
+// Get the calls to sync.NewCond that may have allocated the label value p
+// and whose result may alias ptr. The pointer analysis analyzes sync.NewCond
+// context-sensitively, so the results of different calls do not alias.
+func condSites(p ssa.Value, ptr pointer.Pointer, pt *pointer.Result) (res map[ssa.Value]struct{}) {
+	res = make(map[ssa.Value]struct{})
+
+	fun := p.Parent()
+	if fun == nil || fun.Pkg == nil || fun.Pkg.Pkg.Path() != "sync" ||
+		fun.Name() != "NewCond" || pt.CallGraph == nil {
+		return
+	}
+
+	node := pt.CallGraph.Nodes[fun]
+	if node == nil {
+		return
+	}
+
+	for _, edge := range node.In {
+		call, ok := edge.Site.(*ssa.Call)
+		if !ok || blacklistPrimitive(call) {
+			continue
+		}
+
+		if q, ok := pt.Queries[call]; ok && q.MayAlias(ptr) {
+			res[call] = struct{}{}
+		}
+	}
+
+	return
+}
+
+// PrimitivesOf returns the channels and synchronization primitives that v
+// may point to, identified like the primitives in PSets.
+func PrimitivesOf(v ssa.Value, pt *pointer.Result) map[ssa.Value]struct{} {
+	return getPrimitives(v, pt)
+}
+
 func (C psetCtxt) makeDependencyMapFromRootNode(
 	CFG *cfg.Cfg,
 	entry cfg.Node,
@@ -193,14 +292,14 @@ func (C psetCtxt) makeDependencyMapFromRootNode(
 						}
 					}
 				case *ssa.Call:
-					// rcvr, cc := isConcurrentCall(i.Call)
-					// switch cc {
-					// case _BLOCKING_SYNC_CALL:
-					// 	addBlocking(n.CallRelationNode(), rcvr)
-					// 	fallthrough
-					// case _SYNC_CALL:
-					// 	addDependency(rcvr)
-					// }
+					rcvr, cc := isConcurrentCall(i.Call)
+					switch cc {
+					case _BLOCKING_SYNC_CALL:
+						addBlocking(n.CallRelationNode(), rcvr)
+						fallthrough
+					case _SYNC_CALL:
+						addDependency(rcvr)
+					}
 					joinSucc(n.CallRelationNode())
 					return
 				case *ssa.Panic:
@@ -216,14 +315,16 @@ func (C psetCtxt) makeDependencyMapFromRootNode(
 					addChanChanDependency(i.Chan, i.X)
 				}
 			case *cfg.DeferCall:
-				// rcvr, cc := isConcurrentCall(n.Instruction().(*ssa.Defer).Call)
-				// switch cc {
-				// case _BLOCKING_SYNC_CALL:
-				// 	addBlocking(n.CallRelationNode(), rcvr)
-				// 	fallthrough
-				// case _SYNC_CALL:
-				// 	addDependency(rcvr)
-				// }
+				if dfr, ok := n.Instruction().(*ssa.Defer); ok {
+					rcvr, cc := isConcurrentCall(dfr.Call)
+					switch cc {
+					case _BLOCKING_SYNC_CALL:
+						addBlocking(n.CallRelationNode(), rcvr)
+						fallthrough
+					case _SYNC_CALL:
+						addDependency(rcvr)
+					}
+				}
 				joinSucc(n.CallRelationNode())
 				return
 			case *cfg.BuiltinCall:
@@ -277,7 +378,7 @@ type psetConfig struct {
 	CFG          *cfg.Cfg
 	G            graph.Graph[*ssa.Function]
 	entry        cfg.Node
-	chansInScope *utils.SSAValueSet
+	primsInScope *utils.SSAValueSet
 }
 
 func makePSetCtxt(C psetConfig) (ctxt psetCtxt) {
@@ -327,7 +428,14 @@ func makePSetCtxt(C psetConfig) (ctxt psetCtxt) {
 			switch i := n.Instruction().(type) {
 			case *ssa.MakeChan:
 				set = set.Add(i)
+			case *ssa.Alloc:
+				if hasSyncPrimitive(i.Type().Underlying().(*T.Pointer).Elem()) {
+					set = set.Add(i)
+				}
 			case *ssa.Call:
+				if isNewCond(i) {
+					set = set.Add(i)
+				}
 				addCallees()
 				return
 			case *ssa.Go:
@@ -345,8 +453,8 @@ func makePSetCtxt(C psetConfig) (ctxt psetCtxt) {
 
 	ctxt.chanChanDeps = make(map[ssa.Value]utils.SSAValueSet)
 	ctxt.valid = set
-	if C.chansInScope != nil {
-		ctxt.valid = ctxt.valid.Meet(*C.chansInScope)
+	if C.primsInScope != nil {
+		ctxt.valid = ctxt.valid.Meet(*C.primsInScope)
 	}
 
 	return
@@ -366,17 +474,10 @@ func GetInterprocPsets(CFG *cfg.Cfg, pt *pointer.Result, G graph.Graph[*ssa.Func
 	return psetsFromDMap(D)
 }
 
-// Compute a single, whole program P-set that includes all channels.
+// Compute a single, whole program P-set that includes all channels
+// and synchronization primitives.
 func GetTotalPset(ps Primitives) (psets PSets) {
-	set := utils.MakeSSASet()
-
-	for _, usage := range ps {
-		for ch := range usage.Chans() {
-			set = set.Add(ch)
-		}
-	}
-
-	psets = append(psets, set)
+	psets = append(psets, ps.Chans().Join(ps.Sync()))
 
 	return
 }
@@ -394,10 +495,10 @@ func smallerScope(p1, p2 ssa.Value,
 			continue
 		}
 
-		if usageInfo.HasChan(p1) {
+		if usageInfo.HasChan(p1) || usageInfo.IsActive(p1) {
 			p1Funs = append(p1Funs, fun)
 		}
-		if usageInfo.HasChan(p2) {
+		if usageInfo.HasChan(p2) || usageInfo.IsActive(p2) {
 			p2Funs = append(p2Funs, fun)
 		}
 	}
@@ -423,10 +524,10 @@ func GetGCatchPSets(CFG *cfg.Cfg, f *ssa.Function, pt *pointer.Result,
 
 	entry, _ := CFG.FunIO(f)
 
-	chansInScope := new(utils.SSAValueSet)
-	*chansInScope = ps.Chans()
+	primsInScope := new(utils.SSAValueSet)
+	*primsInScope = ps.Chans().Join(ps.Sync())
 
-	C := makePSetCtxt(psetConfig{CFG, G, entry, chansInScope})
+	C := makePSetCtxt(psetConfig{CFG, G, entry, primsInScope})
 	C.makeDependencyMapFromRootNode(CFG, entry, D, G, pt)
 
 	PMap := make(map[ssa.Value]*uf.Element)
@@ -488,6 +589,68 @@ func GetGCatchPSets(CFG *cfg.Cfg, f *ssa.Function, pt *pointer.Result,
 	return psets
 }
 
+// Intra-dependent PSets group primitives if an operation on one of them may
+// follow a blocking operation on the other in the same function. Unlike
+// GCatch PSets, a dependency in one direction is enough to group two primitives,
+// and groups are not split according to the scope of the primitives.
+// Channels are also grouped with the channels that carry them.
+func GetIntraDependentPSets(CFG *cfg.Cfg, f *ssa.Function, pt *pointer.Result,
+	G graph.Graph[*ssa.Function],
+	ps Primitives,
+) (psets PSets) {
+	D := make(map[ssa.Value]map[ssa.Value]struct{})
+
+	entry, _ := CFG.FunIO(f)
+
+	primsInScope := new(utils.SSAValueSet)
+	*primsInScope = ps.Chans().Join(ps.Sync())
+
+	C := makePSetCtxt(psetConfig{CFG, G, entry, primsInScope})
+	C.makeDependencyMapFromRootNode(CFG, entry, D, G, pt)
+
+	PMap := make(map[ssa.Value]*uf.Element)
+	element := func(p ssa.Value) *uf.Element {
+		el, ok := PMap[p]
+		if !ok {
+			el = uf.NewElement()
+			el.Data = p
+			PMap[p] = el
+		}
+		return el
+	}
+
+	for p1, ps := range D {
+		el := element(p1)
+		for p2 := range ps {
+			uf.Union(el, element(p2))
+		}
+	}
+
+	for x, carriers := range C.chanChanDeps {
+		el := element(x)
+		carriers.ForEach(func(ch ssa.Value) {
+			uf.Union(el, element(ch))
+		})
+	}
+
+	sets := make(map[*uf.Element]utils.SSAValueSet)
+
+	for v, rep := range PMap {
+		set, ok := sets[rep.Find()]
+		if !ok {
+			set = utils.MakeSSASet()
+		}
+
+		sets[rep.Find()] = set.Add(v)
+	}
+
+	for _, set := range sets {
+		psets = append(psets, set)
+	}
+
+	return psets
+}
+
 func psetsFromDMap(D map[ssa.Value]map[ssa.Value]struct{}) (psets PSets) {
 	psets = make(PSets, 0)
 
@@ -551,10 +714,15 @@ func (psets PSets) String() string {
 func GetSingletonPsets(ps Primitives) (psets PSets) {
 	seen := map[ssa.Value]bool{}
 	for _, usage := range ps {
-		for ch := range usage.Chans() {
-			if !seen[ch] {
-				seen[ch] = true
-				psets = append(psets, utils.MakeSSASet(ch))
+		for _, used := range []map[ssa.Value]struct{}{
+			usage.Chans(),
+			usage.Sync(),
+		} {
+			for p := range used {
+				if !seen[p] {
+					seen[p] = true
+					psets = append(psets, utils.MakeSSASet(p))
+				}
 			}
 		}
 	}
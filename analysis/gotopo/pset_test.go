@@ -0,0 +1,194 @@
+package gotopo
+
+import (
+	"flag"
+	T "go/types"
+	"testing"
+
+	tu "github.com/cs-au-dk/goat/testutil"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+const syncPSetsProgram = `package main
+
+import "sync"
+
+type S struct {
+	mu sync.Mutex
+}
+
+func locked() {
+	s := &S{}
+	ch := make(chan int)
+	go func() {
+		s.mu.Lock()
+		ch <- 1
+		s.mu.Unlock()
+	}()
+	s.mu.Lock()
+	<-ch
+	s.mu.Unlock()
+}
+
+func cond() {
+	var mu sync.Mutex
+	c := sync.NewCond(&mu)
+	done := make(chan struct{})
+	go func() {
+		mu.Lock()
+		c.Wait()
+		mu.Unlock()
+		done <- struct{}{}
+	}()
+	mu.Lock()
+	c.Signal()
+	mu.Unlock()
+	<-done
+}
+
+func main() {
+	locked()
+	cond()
+}`
+
+// syncSites finds the channel, mutex and condition variable allocation
+// sites in the function, and the receiver of its first call to method.
+func syncSites(t *testing.T, f *ssa.Function, method string) (ch, mu, cond, rcvr ssa.Value) {
+	for _, block := range f.Blocks {
+		for _, insn := range block.Instrs {
+			switch i := insn.(type) {
+			case *ssa.MakeChan:
+				ch = i
+			case *ssa.Alloc:
+				if hasSyncPrimitive(i.Type().Underlying().(*T.Pointer).Elem()) {
+					mu = i
+				}
+			case *ssa.Call:
+				if isNewCond(i) {
+					cond = i
+				} else if callee := i.Call.StaticCallee(); rcvr == nil && callee != nil &&
+					callee.Name() == method && len(i.Call.Args) == 1 {
+					rcvr = i.Call.Args[0]
+				}
+			}
+		}
+	}
+
+	if ch == nil || mu == nil || rcvr == nil {
+		t.Fatalf("Expected a channel, a mutex and a %s call in %s", method, f.Name())
+	}
+	return
+}
+
+func TestSyncPrimitivesInPSets(t *testing.T) {
+	loadRes := tu.LoadResultFromPackages(t,
+		tu.LoadSourceAsPackages(t, "syncpsets", syncPSetsProgram))
+
+	G := loadRes.PrunedCallDAG.Original
+	pt := loadRes.Pointer
+
+	t.Run("MutexAndChannel", func(t *testing.T) {
+		entry := loadRes.Mains[0].Func("locked")
+		ch, mu, _, rcvr := syncSites(t, entry, "Lock")
+
+		if _, found := PrimitivesOf(rcvr, pt)[mu]; !found {
+			t.Errorf("Expected the primitives of %v to contain the allocation of the struct %v", rcvr, mu)
+		}
+
+		ps, primsToUses := GetPrimitives(entry, pt, G)
+		if !ps.Sync().Contains(mu) {
+			t.Fatalf("Expected %v among the sync primitives, got %v", mu, ps.Sync())
+		}
+		if len(primsToUses[mu]) != 2 {
+			t.Errorf("Expected %v to be used in both goroutines, got %v", mu, primsToUses[mu])
+		}
+
+		for name, psets := range map[string]PSets{
+			"GCatch": GetGCatchPSets(loadRes.Cfg, entry, pt, G,
+				G.DominatorTree(entry), loadRes.PrunedCallDAG, ps),
+			"intra-dependent": GetIntraDependentPSets(loadRes.Cfg, entry, pt, G, ps),
+		} {
+			if pset := psets.Get(ch); !pset.Contains(mu) {
+				t.Errorf("Expected the %s PSet of %v to contain %v, got %v", name, ch, mu, psets)
+			}
+		}
+	})
+
+	t.Run("NewCond", func(t *testing.T) {
+		entry := loadRes.Mains[0].Func("cond")
+		done, mu, cond, rcvr := syncSites(t, entry, "Wait")
+		if cond == nil {
+			t.Fatal("Expected a call to sync.NewCond")
+		}
+
+		// The condition variable is identified by the call to sync.NewCond,
+		// since it is allocated in GOROOT.
+		if prims := PrimitivesOf(rcvr, pt); len(prims) != 1 {
+			t.Errorf("Expected %v as the only primitive of %v, got %v", cond, rcvr, prims)
+		} else if _, found := prims[cond]; !found {
+			t.Errorf("Expected %v as the primitive of %v, got %v", cond, rcvr, prims)
+		}
+
+		ps, _ := GetPrimitives(entry, pt, G)
+		psets := GetIntraDependentPSets(loadRes.Cfg, entry, pt, G, ps)
+		pset := psets.Get(cond)
+		for _, v := range []ssa.Value{mu, done} {
+			if !pset.Contains(v) {
+				t.Errorf("Expected the PSet of %v to contain %v, got %v", cond, v, psets)
+			}
+		}
+	})
+
+	t.Run("ChansOnly", func(t *testing.T) {
+		if err := flag.Set("pset-chans-only", "true"); err != nil {
+			t.Fatal(err)
+		}
+		defer flag.Set("pset-chans-only", "false")
+
+		entry := loadRes.Mains[0].Func("locked")
+		ch, mu, _, _ := syncSites(t, entry, "Lock")
+
+		ps, _ := GetPrimitives(entry, pt, G)
+		if !ps.Sync().Empty() {
+			t.Errorf("Expected no sync primitives, got %v", ps.Sync())
+		}
+
+		psets := GetIntraDependentPSets(loadRes.Cfg, entry, pt, G, ps)
+		if pset := psets.Get(ch); !pset.Contains(ch) || pset.Contains(mu) {
+			t.Errorf("Expected a PSet with %v and without %v, got %v", ch, mu, psets)
+		}
+	})
+}
+
+func TestHasSyncPrimitive(t *testing.T) {
+	sync := T.NewPackage("sync", "sync")
+	named := func(name string) *T.Named {
+		return T.NewNamed(T.NewTypeName(0, sync, name, nil), T.NewStruct(nil, nil), nil)
+	}
+	mutex, cond := named("Mutex"), named("Cond")
+	field := func(name string, typ T.Type) *T.Var {
+		return T.NewField(0, nil, name, typ, false)
+	}
+	inner := T.NewStruct([]*T.Var{field("mu", mutex)}, nil)
+
+	for _, test := range []struct {
+		typ      T.Type
+		expected bool
+	}{
+		{mutex, true},
+		{cond, true},
+		{T.NewPointer(mutex), false},
+		{named("WaitGroup"), false},
+		{T.Typ[T.Int], false},
+		{inner, true},
+		{T.NewStruct([]*T.Var{field("x", T.Typ[T.Int]), field("s", inner)}, nil), true},
+		{T.NewStruct([]*T.Var{field("mu", T.NewPointer(mutex))}, nil), false},
+		{T.NewArray(cond, 2), true},
+		{T.NewSlice(mutex), false},
+	} {
+		if res := hasSyncPrimitive(test.typ); res != test.expected {
+			t.Errorf("Expected hasSyncPrimitive(%v) to be %v", test.typ, test.expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"go/types"
 	"strings"
 
+	"github.com/cs-au-dk/goat/analysis/upfront/models"
 	"github.com/cs-au-dk/goat/pkgutil"
 	"github.com/cs-au-dk/goat/utils"
 	"github.com/cs-au-dk/goat/utils/graph"
@@ -33,9 +34,9 @@ func GetPrimitives(
 	 * we would not (currently) be able to distinguish uses of mu1 and mu2.
 	 * Requires identification of primitives both by allocation site and Path.
 	 */
-	// NOTE: Collection of non-channel primitives is currently completely
-	// disabled in the process function and in the mapping of primitives to
-	// functions in which they are used (below).
+	// NOTE: Mutexes are identified by the allocation site of the enclosing
+	// struct, and condition variables made with sync.NewCond by the call.
+	// Mutexes in global variables are not local, and are not included.
 	p = make(Primitives)
 
 	// Compute reachable functions first, so we can check that primitives'
@@ -56,9 +57,7 @@ func GetPrimitives(
 		for _, usedPrimitives := range []map[ssa.Value]struct{}{
 			usageInfo.Chans(),
 			usageInfo.OutChans(),
-			// TODO: Temporarily disabled because analysis of locks
-			// seem to time out more often than analysis of channels.
-			//usageInfo.Sync(),
+			usageInfo.Sync(),
 		} {
 			for prim := range usedPrimitives {
 				if _, seen := primsToUses[prim]; !seen {
@@ -86,6 +85,18 @@ func (p Primitives) Chans() utils.SSAValueSet {
 	return set
 }
 
+func (p Primitives) Sync() utils.SSAValueSet {
+	set := utils.MakeSSASet()
+
+	for _, info := range p {
+		for s := range info.Sync() {
+			set = set.Add(s)
+		}
+	}
+
+	return set
+}
+
 type _CONCURRENT_CALL = int
 
 const (
@@ -209,12 +220,24 @@ func (p Primitives) process(f *ssa.Function, pt *pointer.Result, reachableFuns m
 			case *ssa.MakeChan:
 				addPrimitive(i, fu.AddCreatedChan)
 			case ssa.CallInstruction:
+				// Channels returned by functions with user-supplied models are
+				// allocated at the call site.
+				if m, found := models.Lookup(i.Common().StaticCallee()); found && m.Returns != nil {
+					if v := i.Value(); v != nil && pkgutil.IsLocal(v) && reachableFuns[f] {
+						fu.AddCreatedChan(v)
+					}
+				}
+
 				p, call := isConcurrentCall(*i.Common())
 				switch {
 				case call == _CHAN_CALL:
 					addPrimitive(p, fu.AddUseChan)
-					// case call == _SYNC_CALL || call == _BLOCKING_SYNC_CALL:
-					// 	addPrimitive(p, fu.AddUseSync)
+				case call == _SYNC_CALL || call == _BLOCKING_SYNC_CALL:
+					// Sync primitives are not grouped in primitive sets when
+					// the 'sync' library is not modelled.
+					if !utils.Opts().PSetChansOnly() {
+						addPrimitive(p, fu.AddUseSync)
+					}
 				}
 
 				// if val := i.Value(); val != nil {
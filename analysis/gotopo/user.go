@@ -0,0 +1,134 @@
+package gotopo
+
+import (
+	"fmt"
+	"go/token"
+	T "go/types"
+	"sort"
+	"strings"
+
+	"github.com/cs-au-dk/goat/pkgutil"
+	"github.com/cs-au-dk/goat/utils"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// GetUserPSets constructs the PSets given by the user. Every site is
+// resolved to the primitives allocated at it, like they are identified in
+// other PSets: channels by their make instruction, mutexes by the allocation
+// of the enclosing variable or struct, and condition variables by the call
+// to sync.NewCond. A site on the declaration of a sync.Mutex, sync.RWMutex
+// or sync.Cond struct field covers every allocation of a struct with that field.
+// Sites are grouped in PSets by name, and it is an error if a site does not
+// cover the allocation of any primitive.
+func GetUserPSets(prog *ssa.Program, sites []pkgutil.PSetSite) (PSets, error) {
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("no user-defined PSets were found, add %s comments or use -pset-file",
+			"//goat:pset <name>")
+	}
+
+	fset := prog.Fset
+	sets := make(map[string]utils.SSAValueSet)
+	resolved := make([]bool, len(sites))
+
+	// Add prim to the PSets of the sites covering the given position.
+	visit := func(prim ssa.Value, pos token.Pos) {
+		if !pos.IsValid() {
+			return
+		}
+
+		position := fset.Position(pos)
+		for i, site := range sites {
+			if site.Covers(position) {
+				resolved[i] = true
+				set, ok := sets[site.Name]
+				if !ok {
+					set = utils.MakeSSASet()
+				}
+				sets[site.Name] = set.Add(prim)
+			}
+		}
+	}
+
+	// Find sync primitive fields of the allocated type, including fields of
+	// nested structs and arrays.
+	var visitFields func(prim ssa.Value, t T.Type)
+	visitFields = func(prim ssa.Value, t T.Type) {
+		switch t := t.Underlying().(type) {
+		case *T.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				field := t.Field(i)
+				if isSyncValue(field.Type()) {
+					visit(prim, field.Pos())
+				}
+				visitFields(prim, field.Type())
+			}
+		case *T.Array:
+			visitFields(prim, t.Elem())
+		}
+	}
+
+	for fun := range ssautil.AllFunctions(prog) {
+		if pkgutil.CheckInGoroot(fun) {
+			continue
+		}
+
+		for _, block := range fun.Blocks {
+			for _, insn := range block.Instrs {
+				switch i := insn.(type) {
+				case *ssa.MakeChan:
+					visit(i, i.Pos())
+				case *ssa.Alloc:
+					if elem := i.Type().Underlying().(*T.Pointer).Elem(); hasSyncPrimitive(elem) {
+						visit(i, i.Pos())
+						visitFields(i, elem)
+					}
+				case *ssa.Call:
+					if isNewCond(i) {
+						visit(i, i.Pos())
+					}
+				}
+			}
+		}
+	}
+
+	unresolved := []string{}
+	for i, site := range sites {
+		if !resolved[i] {
+			unresolved = append(unresolved, site.String())
+		}
+	}
+	if len(unresolved) > 0 {
+		return nil, fmt.Errorf("no channel or sync primitive is allocated at the following PSet sites:\n\t%s",
+			strings.Join(unresolved, "\n\t"))
+	}
+
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	psets := make(PSets, 0, len(names))
+	for _, name := range names {
+		psets = append(psets, sets[name])
+	}
+
+	return psets, nil
+}
+
+// RestrictPSets restricts the user-defined PSets to the primitives that are
+// reachable from an entry, as summarized by GetPrimitives for the entry.
+// PSets without reachable primitives are dropped.
+func RestrictPSets(psets PSets, ps Primitives) PSets {
+	reachable := ps.Chans().Join(ps.Sync())
+
+	res := make(PSets, 0, len(psets))
+	for _, pset := range psets {
+		if pset = pset.Meet(reachable); !pset.Empty() {
+			res = append(res, pset)
+		}
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+package gotopo
+
+import (
+	"testing"
+
+	"github.com/cs-au-dk/goat/pkgutil"
+	tu "github.com/cs-au-dk/goat/testutil"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestUserPSetsForEntries(t *testing.T) {
+	pkgs := tu.LoadSourceAsPackages(t, "userpsets", `package main
+
+import "sync"
+
+func a() {
+	ch := make(chan int) //goat:pset a
+	go func() {
+		ch <- 1
+	}()
+	<-ch
+}
+
+func b() {
+	var mu sync.Mutex //goat:pset b
+	go func() {
+		mu.Lock()
+		mu.Unlock()
+	}()
+	mu.Lock()
+	mu.Unlock()
+}
+
+func main() {
+	a()
+	b()
+}`)
+	loadRes := tu.LoadResultFromPackages(t, pkgs)
+
+	sites, err := pkgutil.CollectPSetDirectives(pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPsets, err := GetUserPSets(loadRes.Prog, sites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userPsets) != 2 {
+		t.Fatalf("Expected two user PSets, got %v", userPsets)
+	}
+
+	G := loadRes.PrunedCallDAG.Original
+	for _, name := range []string{"a", "b"} {
+		entry := loadRes.Mains[0].Func(name)
+		ps, primsToUses := GetPrimitives(entry, loadRes.Pointer, G)
+
+		psets := RestrictPSets(userPsets, ps)
+		if len(psets) != 1 {
+			t.Errorf("Expected a single PSet for entry %s, got %v", name, psets)
+			continue
+		}
+
+		// Every primitive in the PSet must be reachable from the entry,
+		// such that a lowered entry can be computed for the PSet.
+		funs := []*ssa.Function{}
+		psets[0].ForEach(func(v ssa.Value) {
+			funs = append(funs, v.Parent())
+			for fun := range primsToUses[v] {
+				funs = append(funs, fun)
+			}
+		})
+		if loweredEntry := G.DominatorTree(entry)(funs...); loweredEntry != entry {
+			t.Errorf("Expected %s as the lowered entry, got %v", name, loweredEntry)
+		}
+	}
+}
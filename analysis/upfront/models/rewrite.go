@@ -0,0 +1,349 @@
+package models
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/cs-au-dk/goat/pkgutil"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Rewrite replaces the bodies of modelled functions with the concurrency
+// operations described by their models. This must happen before loop
+// inlining, which recomputes the type information of the rewritten packages.
+// Returns the names of rewrite models for which no source code was found.
+// Models that return channels are checked against the signatures of the
+// functions they model, but are otherwise not applied here.
+func Rewrite(pkgs []*packages.Package) (unresolved []string, err error) {
+	applied := make(map[string]bool)
+
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if err != nil || pkg.Types == nil {
+			return
+		}
+		if err = checkReturns(pkg.Types); err != nil || pkgutil.CheckPkgInGoroot(pkg.Types) {
+			return
+		}
+
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				decl, ok := decl.(*ast.FuncDecl)
+				if !ok || decl.Body == nil {
+					continue
+				}
+
+				fun, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+
+				m, found := registry[fun.FullName()]
+				if !found || !m.IsRewrite() {
+					continue
+				}
+
+				if err = rewrite(pkg, decl, fun, m); err != nil {
+					return
+				}
+				applied[m.Function] = true
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, m := range registry {
+		if m.IsRewrite() && !applied[name] {
+			unresolved = append(unresolved, name)
+		}
+	}
+	sort.Strings(unresolved)
+	return
+}
+
+// checkReturns checks that the functions and methods of the package that
+// are modelled with returns have a single channel result.
+func checkReturns(pkg *types.Package) error {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		var funs []*types.Func
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			funs = append(funs, obj)
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+				for i := 0; i < named.NumMethods(); i++ {
+					funs = append(funs, named.Method(i))
+				}
+			}
+		}
+
+		for _, fun := range funs {
+			m, found := registry[fun.FullName()]
+			if !found || m.Returns == nil {
+				continue
+			}
+
+			res := fun.Type().(*types.Signature).Results()
+			if res.Len() != 1 {
+				return fmt.Errorf("%s: returns a channel, but the function returns %s", m.Function, res)
+			}
+			if _, ok := res.At(0).Type().Underlying().(*types.Chan); !ok {
+				return fmt.Errorf("%s: returns a channel, but the function returns %s", m.Function, res.At(0).Type())
+			}
+		}
+	}
+	return nil
+}
+
+// rewriter constructs the body of a single modelled function.
+type rewriter struct {
+	pkg   *types.Package
+	model Model
+	// Position of all generated operations.
+	pos token.Pos
+	// Names and types of the arguments, including the receiver.
+	args     []string
+	argTypes []types.Type
+	// Names and types of the results.
+	results     []string
+	resultTypes []types.Type
+}
+
+// nameFields gives a name to every unnamed or blank parameter in the list.
+func nameFields(fields *ast.FieldList, prefix string, names []string) []string {
+	if fields == nil {
+		return names
+	}
+
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			field.Names = []*ast.Ident{{Name: "_"}}
+		}
+		for i, name := range field.Names {
+			if name.Name == "_" {
+				field.Names[i] = &ast.Ident{
+					NamePos: name.NamePos,
+					Name:    fmt.Sprintf("%s_%d", prefix, len(names)),
+				}
+			}
+			names = append(names, field.Names[i].Name)
+		}
+	}
+
+	return names
+}
+
+func rewrite(pkg *packages.Package, decl *ast.FuncDecl, fun *types.Func, m Model) error {
+	sig := fun.Type().(*types.Signature)
+	if sig.Recv() == nil && decl.Recv != nil {
+		return fmt.Errorf("%s: unexpected receiver", m.Function)
+	}
+
+	rw := &rewriter{
+		pkg:   pkg.Types,
+		model: m,
+		pos:   decl.Body.Lbrace,
+	}
+
+	rw.args = nameFields(decl.Recv, "_GOAT_MODEL_ARG", rw.args)
+	rw.args = nameFields(decl.Type.Params, "_GOAT_MODEL_ARG", rw.args)
+	if recv := sig.Recv(); recv != nil {
+		rw.argTypes = append(rw.argTypes, recv.Type())
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		rw.argTypes = append(rw.argTypes, sig.Params().At(i).Type())
+	}
+
+	rw.results = nameFields(decl.Type.Results, "_GOAT_MODEL_RES", rw.results)
+	for i := 0; i < sig.Results().Len(); i++ {
+		rw.resultTypes = append(rw.resultTypes, sig.Results().At(i).Type())
+	}
+
+	stmts, err := rw.body()
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.Function, err)
+	}
+
+	// The original body is kept after the return, such that the imports and
+	// declarations it uses remain used. It is unreachable, and is therefore
+	// not part of the SSA representation of the function.
+	stmts = append(stmts, &ast.ReturnStmt{Return: rw.pos}, decl.Body)
+	decl.Body = &ast.BlockStmt{
+		Lbrace: decl.Body.Lbrace,
+		List:   stmts,
+		Rbrace: decl.Body.Rbrace,
+	}
+
+	return nil
+}
+
+func (rw *rewriter) arg(i int) (ast.Expr, types.Type, error) {
+	if i < 0 || i >= len(rw.args) {
+		return nil, nil, fmt.Errorf("argument %d is out of range (the function has %d)", i, len(rw.args))
+	}
+	return &ast.Ident{Name: rw.args[i]}, rw.argTypes[i], nil
+}
+
+// channel returns an expression for the channel of the operation.
+func (rw *rewriter) channel(op *ChanOp) (ast.Expr, *types.Chan, error) {
+	x, t, err := rw.arg(op.Arg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if op.Field != "" {
+		for _, name := range strings.Split(op.Field, ".") {
+			obj, _, _ := types.LookupFieldOrMethod(t, true, rw.pkg, name)
+			field, ok := obj.(*types.Var)
+			if !ok || !field.IsField() {
+				return nil, nil, fmt.Errorf("%s has no field %s", t, name)
+			}
+
+			x = &ast.SelectorExpr{X: x, Sel: &ast.Ident{Name: name}}
+			t = field.Type()
+		}
+	}
+
+	ch, ok := t.Underlying().(*types.Chan)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a channel", t)
+	}
+	return x, ch, nil
+}
+
+func (rw *rewriter) body() (stmts []ast.Stmt, err error) {
+	m := rw.model
+
+	if s := m.Spawn; s != nil {
+		stmt, err := rw.spawn(s)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	if op := m.Send; op != nil {
+		ch, typ, err := rw.channel(op)
+		if err != nil {
+			return nil, err
+		}
+		if typ.Dir() == types.RecvOnly {
+			return nil, fmt.Errorf("cannot send on receive-only channel")
+		}
+
+		v, vt, err := rw.arg(*op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !types.AssignableTo(vt, typ.Elem()) {
+			return nil, fmt.Errorf("cannot send %s on %s", vt, typ)
+		}
+
+		stmts = append(stmts, &ast.SendStmt{Chan: ch, Arrow: rw.pos, Value: v})
+	}
+
+	if op := m.Receive; op != nil {
+		stmt, err := rw.receive(op)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	if op := m.Close; op != nil {
+		ch, typ, err := rw.channel(op)
+		if err != nil {
+			return nil, err
+		}
+		if typ.Dir() == types.RecvOnly {
+			return nil, fmt.Errorf("cannot close receive-only channel")
+		}
+
+		stmts = append(stmts, &ast.ExprStmt{X: &ast.CallExpr{
+			Fun:    &ast.Ident{Name: "close"},
+			Lparen: rw.pos,
+			Args:   []ast.Expr{ch},
+			Rparen: rw.pos,
+		}})
+	}
+
+	return
+}
+
+func (rw *rewriter) spawn(s *Spawn) (ast.Stmt, error) {
+	f, ft, err := rw.arg(s.Arg)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, ok := ft.Underlying().(*types.Signature)
+	if !ok {
+		return nil, fmt.Errorf("cannot spawn %s", ft)
+	}
+	if sig.Params().Len() != len(s.Args) {
+		return nil, fmt.Errorf("spawned function expects %d arguments, got %d", sig.Params().Len(), len(s.Args))
+	}
+
+	call := &ast.CallExpr{Fun: f, Lparen: rw.pos, Rparen: rw.pos}
+	for i, idx := range s.Args {
+		a, at, err := rw.arg(idx)
+		if err != nil {
+			return nil, err
+		}
+		if !types.AssignableTo(at, sig.Params().At(i).Type()) {
+			return nil, fmt.Errorf("cannot pass %s as argument %d of the spawned function", at, i)
+		}
+		call.Args = append(call.Args, a)
+	}
+	if sig.Variadic() {
+		call.Ellipsis = rw.pos
+	}
+
+	return &ast.GoStmt{Go: rw.pos, Call: call}, nil
+}
+
+func (rw *rewriter) receive(op *ChanOp) (ast.Stmt, error) {
+	ch, typ, err := rw.channel(op)
+	if err != nil {
+		return nil, err
+	}
+	if typ.Dir() == types.SendOnly {
+		return nil, fmt.Errorf("cannot receive from send-only channel")
+	}
+
+	rcv := &ast.UnaryExpr{OpPos: rw.pos, Op: token.ARROW, X: ch}
+
+	switch len(rw.results) {
+	case 0:
+		return &ast.ExprStmt{X: rcv}, nil
+	case 1, 2:
+		if !types.AssignableTo(typ.Elem(), rw.resultTypes[0]) {
+			return nil, fmt.Errorf("cannot return %s received from %s", typ.Elem(), typ)
+		}
+
+		lhs := []ast.Expr{&ast.Ident{Name: rw.results[0]}}
+		if len(rw.results) == 2 {
+			if b, ok := rw.resultTypes[1].Underlying().(*types.Basic); !ok || b.Kind() != types.Bool {
+				return nil, fmt.Errorf("the second result of a receive must be a bool")
+			}
+			lhs = append(lhs, &ast.Ident{Name: rw.results[1]})
+		}
+
+		return &ast.AssignStmt{
+			Lhs:    lhs,
+			TokPos: rw.pos,
+			Tok:    token.ASSIGN,
+			Rhs:    []ast.Expr{rcv},
+		}, nil
+	default:
+		return nil, fmt.Errorf("a receive returns at most 2 results")
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const queueSrc = `
+package queue
+
+type Queue struct {
+	inner struct{ ch chan int }
+}
+
+func (q *Queue) Pop() (int, bool) {
+	panic("opaque")
+}
+
+func (q *Queue) Push(v int) {
+	panic("opaque")
+}
+
+func (*Queue) Close(q chan int) {
+	panic("opaque")
+}
+
+func Go(f func(int), _ int) error {
+	panic("opaque")
+}
+
+func Ready() <-chan struct{}
+`
+
+// check parses and type checks a single file package without dependencies.
+func check(t *testing.T, src string) *packages.Package {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "queue.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	tpkg, err := (&types.Config{}).Check("example.com/queue", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &packages.Package{
+		PkgPath:   "example.com/queue",
+		Fset:      fset,
+		Syntax:    []*ast.File{file},
+		Types:     tpkg,
+		TypesInfo: info,
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	pkg := check(t, queueSrc)
+
+	one, zero := 1, 0
+	Use(Spec{Models: []Model{
+		{Function: "(*example.com/queue.Queue).Pop", Receive: &ChanOp{Arg: 0, Field: "inner.ch"}},
+		{Function: "(*example.com/queue.Queue).Push", Send: &ChanOp{Arg: 0, Field: "inner.ch", Value: &one}},
+		{Function: "(*example.com/queue.Queue).Close", Close: &ChanOp{Arg: 1}},
+		{Function: "example.com/queue.Go", Spawn: &Spawn{Arg: 0, Args: []int{1}}},
+		{Function: "example.com/queue.Missing", Send: &ChanOp{Arg: 0, Value: &zero}},
+		{Function: "example.com/queue.Timeout", Returns: &Returns{Closed: true}},
+		{Function: "example.com/queue.Ready", Returns: &Returns{Closed: true}},
+	}})
+	defer Use(Spec{})
+
+	unresolved, err := Rewrite([]*packages.Package{pkg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 1 || unresolved[0] != "example.com/queue.Missing" {
+		t.Errorf("Expected only example.com/queue.Missing to be unresolved, got %v", unresolved)
+	}
+
+	var buf strings.Builder
+	printer.Fprint(&buf, pkg.Fset, pkg.Syntax[0])
+	out := buf.String()
+	t.Log(out)
+
+	for _, expected := range []string{
+		"_GOAT_MODEL_RES_0, _GOAT_MODEL_RES_1 = <-q.inner.ch",
+		"q.inner.ch <- v",
+		"close(q)",
+		"go f(_GOAT_MODEL_ARG_1)",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected rewritten package to contain %q", expected)
+		}
+	}
+
+	// The rewritten package must still type check.
+	if _, err := (&types.Config{}).Check("example.com/queue", pkg.Fset, pkg.Syntax, nil); err != nil {
+		t.Error("Rewritten package does not type check:", err)
+	}
+}
+
+func TestRewriteInvalid(t *testing.T) {
+	zero := 0
+	for name, m := range map[string]Model{
+		"Missing field": {Receive: &ChanOp{Arg: 0, Field: "ch"}},
+		"Not a channel": {Receive: &ChanOp{Arg: 0, Field: "inner"}},
+		"Out of range":  {Receive: &ChanOp{Arg: 2, Field: "inner.ch"}},
+		"Wrong value":   {Send: &ChanOp{Arg: 0, Field: "inner.ch", Value: &zero}},
+		// Pop returns (int, bool), and not a channel.
+		"Returns a tuple": {Returns: &Returns{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m.Function = "(*example.com/queue.Queue).Pop"
+			Use(Spec{Models: []Model{m}})
+			defer Use(Spec{})
+
+			if _, err := Rewrite([]*packages.Package{check(t, queueSrc)}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "models.yaml")
+	os.WriteFile(valid, []byte(`
+models:
+  - function: (*example.com/queue.Queue).Pop
+    receive: {arg: 0, field: inner.ch}
+  - function: example.com/clock.Expired
+    returns: {closed: true}
+`), 0o644)
+
+	spec, err := Load(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Models) != 2 || spec.Models[0].Receive.Field != "inner.ch" || !spec.Models[1].Returns.Closed {
+		t.Errorf("Unexpected models: %+v", spec.Models)
+	}
+
+	invalid := filepath.Join(dir, "models.json")
+	os.WriteFile(invalid, []byte(`{"models": [
+		{"function": "example.com/clock.Expired", "returns": {}, "close": {"arg": 0}}
+	]}`), 0o644)
+
+	if _, err := Load(invalid); err == nil {
+		t.Error("Expected combining returns with other behaviours to be rejected")
+	}
+}
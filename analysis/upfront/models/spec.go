@@ -0,0 +1,159 @@
+// Package models implements user-supplied concurrency models for functions
+// that the analysis cannot (or should not) analyze directly, e.g. internal
+// libraries wrapping channels behind an API.
+//
+// Models are given in a specification file (JSON or YAML), such as:
+//
+//	models:
+//	  # Pop behaves like a receive on the channel stored in the ch field of its receiver.
+//	  - function: (*example.com/queue.Queue).Pop
+//	    receive: {arg: 0, field: ch}
+//	  # Go spawns its first argument as a goroutine.
+//	  - function: example.com/pool.Go
+//	    spawn: {arg: 0}
+//	  # Expired returns a fresh closed channel.
+//	  - function: example.com/clock.Expired
+//	    returns: {closed: true}
+//
+// Functions are identified by their fully qualified names. Arguments are
+// numbered from 0, and the receiver of a method is argument 0.
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Spec is the contents of a model specification file.
+type Spec struct {
+	Models []Model `json:"models" yaml:"models"`
+}
+
+// Model describes the concurrent behaviour of a function. The behaviours
+// are performed in the order: spawn, send, receive, close.
+type Model struct {
+	// Fully qualified name of the function, e.g. (*example.com/queue.Queue).Pop
+	Function string `json:"function" yaml:"function"`
+
+	// The function receives from a channel. The received value is returned
+	// in the first result, and the second result (if any) reports whether
+	// the channel is open, like a comma-ok receive.
+	Receive *ChanOp `json:"receive" yaml:"receive"`
+	// The function sends an argument on a channel.
+	Send *ChanOp `json:"send" yaml:"send"`
+	// The function closes a channel.
+	Close *ChanOp `json:"close" yaml:"close"`
+	// The function spawns one of its arguments as a goroutine.
+	Spawn *Spawn `json:"spawn" yaml:"spawn"`
+	// The function returns a fresh channel. Cannot be combined with other behaviours.
+	Returns *Returns `json:"returns" yaml:"returns"`
+}
+
+// ChanOp identifies the channel of an operation.
+type ChanOp struct {
+	// The argument holding the channel.
+	Arg int `json:"arg" yaml:"arg"`
+	// Dot-separated path of (possibly embedded) fields leading from the
+	// argument to the channel. If empty, the argument is the channel.
+	Field string `json:"field" yaml:"field"`
+	// The argument holding the value to send. Only used for sends.
+	Value *int `json:"value" yaml:"value"`
+}
+
+// Spawn describes a goroutine spawned by a function.
+type Spawn struct {
+	// The argument holding the function to spawn.
+	Arg int `json:"arg" yaml:"arg"`
+	// The arguments passed to the spawned function.
+	Args []int `json:"args" yaml:"args"`
+}
+
+// Returns describes a channel created by a function.
+type Returns struct {
+	Closed   bool `json:"closed" yaml:"closed"`
+	Capacity int  `json:"capacity" yaml:"capacity"`
+}
+
+// IsRewrite returns true if the model is implemented by rewriting the body
+// of the function, which requires its source code.
+func (m Model) IsRewrite() bool {
+	return m.Returns == nil
+}
+
+func (m Model) validate() error {
+	switch {
+	case m.Function == "":
+		return errors.New("a model must specify a function")
+	case m.Returns != nil && (m.Receive != nil || m.Send != nil || m.Close != nil || m.Spawn != nil):
+		return fmt.Errorf("%s: returns cannot be combined with other behaviours", m.Function)
+	case m.Returns == nil && m.Receive == nil && m.Send == nil && m.Close == nil && m.Spawn == nil:
+		return fmt.Errorf("%s: a model must specify at least one behaviour", m.Function)
+	case m.Send != nil && m.Send.Value == nil:
+		return fmt.Errorf("%s: send must specify the argument holding the value", m.Function)
+	case m.Returns != nil && m.Returns.Capacity < 0:
+		return fmt.Errorf("%s: negative channel capacity", m.Function)
+	}
+	return nil
+}
+
+// Load reads a model specification file. The format is determined by the
+// file extension.
+func Load(path string) (spec Spec, err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(contents, &spec)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &spec)
+	default:
+		return spec, fmt.Errorf("unsupported model file format: %s", path)
+	}
+
+	if err != nil {
+		return spec, fmt.Errorf("unable to parse model file %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range spec.Models {
+		if err := m.validate(); err != nil {
+			return spec, fmt.Errorf("invalid model in %s: %w", path, err)
+		}
+		if seen[m.Function] {
+			return spec, fmt.Errorf("invalid model in %s: %s is modelled more than once", path, m.Function)
+		}
+		seen[m.Function] = true
+	}
+
+	return spec, nil
+}
+
+// Models in use, by function name.
+var registry = map[string]Model{}
+
+// Use makes the models of the specification available to the analyses.
+func Use(spec Spec) {
+	registry = make(map[string]Model, len(spec.Models))
+	for _, m := range spec.Models {
+		registry[m.Function] = m
+	}
+}
+
+// Lookup returns the user-supplied model of the given function, if any.
+func Lookup(fun *ssa.Function) (Model, bool) {
+	if len(registry) == 0 || fun == nil {
+		return Model{}, false
+	}
+	m, found := registry[fun.String()]
+	return m, found
+}
@@ -0,0 +1,369 @@
+// Package subtests models the scheduling of subtests by rewriting calls to
+// methods on *testing.T, since the analysis does not expand the testing package.
+//
+// Within a function with a *testing.T parameter t:
+//
+//   - t.Run(name, f) spawns f on a new goroutine and waits for it to finish.
+//
+//   - If f is a function literal calling Parallel on its parameter, Run
+//     returns immediately instead. The subtest waits until the enclosing
+//     function returns, and runs in parallel with the other parallel subtests.
+//
+//   - t.Cleanup(fn) registers fn to run after the enclosing function has
+//     returned and all of its parallel subtests have finished. Cleanup
+//     functions run in last-added, first-called order.
+//
+// The model is approximate: the body of a parallel subtest runs entirely
+// after the enclosing function returns, including the part before its call
+// to Parallel, and the subtest receives the *testing.T of its parent.
+// Calls through other expressions than the parameter are not rewritten.
+package subtests
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/cs-au-dk/goat/pkgutil"
+	"github.com/cs-au-dk/goat/utils"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Rewrite models the calls to Run and Cleanup in every package that is not
+// in GOROOT. This must happen before loop inlining, which recomputes the
+// type information of the rewritten packages.
+func Rewrite(pkgs []*packages.Package) {
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkgutil.CheckPkgInGoroot(pkg.Types) {
+			return
+		}
+
+		r := &rewriter{info: pkg.TypesInfo}
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					if n.Body != nil {
+						r.rewriteFunc(n.Type, n.Body)
+					}
+				case *ast.FuncLit:
+					r.rewriteFunc(n.Type, n.Body)
+				}
+				return true
+			})
+		}
+	})
+}
+
+type rewriter struct {
+	info *types.Info
+	// Used to generate unique names for the state of each rewritten function.
+	cntr int
+}
+
+// testingParam returns the *testing.T parameter of a function, if any.
+func (r *rewriter) testingParam(typ *ast.FuncType) types.Object {
+	for _, field := range typ.Params.List {
+		for _, name := range field.Names {
+			obj := r.info.Defs[name]
+			if obj == nil {
+				continue
+			}
+			if ptr, ok := obj.Type().(*types.Pointer); ok && utils.IsNamedType(ptr.Elem(), "testing", "T") {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// testingCall returns the name of the method on *testing.T called on the
+// parameter t, or an empty string if the call is not such a method call.
+func (r *rewriter) testingCall(call *ast.CallExpr, t types.Object) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || r.info.Uses[x] != t {
+		return ""
+	}
+
+	fun, ok := r.info.Uses[sel.Sel].(*types.Func)
+	if !ok || fun.Pkg() == nil || fun.Pkg().Path() != "testing" {
+		return ""
+	}
+	return fun.Name()
+}
+
+// isParallel returns true if the subtest is a function literal that calls
+// Parallel on its parameter.
+func (r *rewriter) isParallel(f ast.Expr) bool {
+	lit, ok := f.(*ast.FuncLit)
+	if !ok {
+		return false
+	}
+
+	t := r.testingParam(lit.Type)
+	if t == nil {
+		return false
+	}
+
+	for _, stmt := range lit.Body.List {
+		if stmt, ok := stmt.(*ast.ExprStmt); ok {
+			if call, ok := stmt.X.(*ast.CallExpr); ok && r.testingCall(call, t) == "Parallel" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// state names the variables holding the subtest state of a function:
+//   - release is closed when the function returns, starting parallel subtests.
+//   - done is closed when all parallel subtests have finished.
+//   - cleanup runs the registered cleanup functions.
+type state struct {
+	t                      types.Object
+	release, done, cleanup string
+}
+
+func (r *rewriter) rewriteFunc(typ *ast.FuncType, body *ast.BlockStmt) {
+	t := r.testingParam(typ)
+	if t == nil {
+		return
+	}
+
+	s := state{
+		t:       t,
+		release: fmt.Sprintf("_GOAT_SUBTEST_RELEASE_%d", r.cntr),
+		done:    fmt.Sprintf("_GOAT_SUBTEST_DONE_%d", r.cntr),
+		cleanup: fmt.Sprintf("_GOAT_SUBTEST_CLEANUP_%d", r.cntr),
+	}
+
+	rewritten := false
+	astutil.Apply(body, nil, func(c *astutil.Cursor) bool {
+		call, ok := c.Node().(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		switch r.testingCall(call, t) {
+		case "Run":
+			if len(call.Args) == 2 {
+				c.Replace(s.run(call, r.isParallel(call.Args[1])))
+				rewritten = true
+			}
+		case "Cleanup":
+			if len(call.Args) == 1 {
+				c.Replace(s.registerCleanup(call))
+				rewritten = true
+			}
+		}
+		return true
+	})
+
+	if rewritten {
+		r.cntr++
+		body.List = append(s.preamble(body.Lbrace), body.List...)
+	}
+}
+
+func ident(name string) *ast.Ident {
+	return &ast.Ident{Name: name}
+}
+
+func makeChan(pos token.Pos) ast.Expr {
+	return &ast.CallExpr{
+		Fun:    ident("make"),
+		Lparen: pos,
+		Args: []ast.Expr{&ast.ChanType{
+			Begin: pos,
+			Dir:   ast.SEND | ast.RECV,
+			Value: &ast.StructType{Struct: pos, Fields: &ast.FieldList{}},
+		}},
+		Rparen: pos,
+	}
+}
+
+func callStmt(pos token.Pos, fun ast.Expr, args ...ast.Expr) ast.Stmt {
+	return &ast.ExprStmt{X: &ast.CallExpr{Fun: fun, Lparen: pos, Args: args, Rparen: pos}}
+}
+
+func recvStmt(pos token.Pos, ch string) ast.Stmt {
+	return &ast.ExprStmt{X: &ast.UnaryExpr{OpPos: pos, Op: token.ARROW, X: ident(ch)}}
+}
+
+func define(pos token.Pos, lhs []string, rhs ...ast.Expr) ast.Stmt {
+	stmt := &ast.AssignStmt{TokPos: pos, Tok: token.DEFINE, Rhs: rhs}
+	for _, name := range lhs {
+		stmt.Lhs = append(stmt.Lhs, ident(name))
+	}
+	return stmt
+}
+
+func funcLit(pos token.Pos, results *ast.FieldList, stmts ...ast.Stmt) *ast.FuncLit {
+	return &ast.FuncLit{
+		Type: &ast.FuncType{Func: pos, Params: &ast.FieldList{}, Results: results},
+		Body: &ast.BlockStmt{Lbrace: pos, List: stmts, Rbrace: pos},
+	}
+}
+
+// preamble declares the subtest state of a function:
+//
+//	release, done, cleanup := make(chan struct{}), make(chan struct{}), func() {}
+//	close(done)
+//	defer func() {
+//		close(release)
+//		<-done
+//		cleanup()
+//	}()
+func (s state) preamble(pos token.Pos) []ast.Stmt {
+	return []ast.Stmt{
+		define(pos, []string{s.release, s.done, s.cleanup},
+			makeChan(pos), makeChan(pos), funcLit(pos, nil)),
+		callStmt(pos, ident("close"), ident(s.done)),
+		&ast.DeferStmt{Defer: pos, Call: &ast.CallExpr{
+			Fun: funcLit(pos, nil,
+				callStmt(pos, ident("close"), ident(s.release)),
+				recvStmt(pos, s.done),
+				callStmt(pos, ident(s.cleanup)),
+			),
+			Lparen: pos,
+			Rparen: pos,
+		}},
+	}
+}
+
+// run models a call to t.Run(name, f). Sequential subtests become:
+//
+//	func() bool {
+//		_ = name
+//		f := f
+//		done := make(chan struct{})
+//		go func() {
+//			defer close(done)
+//			f(t)
+//		}()
+//		<-done
+//		return true
+//	}()
+//
+// Parallel subtests become:
+//
+//	func() bool {
+//		_ = name
+//		f := f
+//		prev, next := done, make(chan struct{})
+//		done = next
+//		go func() {
+//			defer func() {
+//				<-prev
+//				close(next)
+//			}()
+//			<-release
+//			f(t)
+//		}()
+//		return true
+//	}()
+func (s state) run(call *ast.CallExpr, parallel bool) ast.Expr {
+	pos := call.Pos()
+	const (
+		f    = "_GOAT_SUBTEST_F"
+		done = "_GOAT_SUBTEST_RUN_DONE"
+		prev = "_GOAT_SUBTEST_PREV"
+		next = "_GOAT_SUBTEST_NEXT"
+	)
+
+	stmts := []ast.Stmt{
+		&ast.AssignStmt{Lhs: []ast.Expr{ident("_")}, TokPos: pos, Tok: token.ASSIGN, Rhs: []ast.Expr{call.Args[0]}},
+		define(pos, []string{f}, call.Args[1]),
+	}
+
+	runSubtest := callStmt(pos, ident(f), ident(s.t.Name()))
+
+	if parallel {
+		stmts = append(stmts,
+			define(pos, []string{prev, next}, ident(s.done), makeChan(pos)),
+			&ast.AssignStmt{Lhs: []ast.Expr{ident(s.done)}, TokPos: pos, Tok: token.ASSIGN, Rhs: []ast.Expr{ident(next)}},
+			&ast.GoStmt{Go: pos, Call: &ast.CallExpr{
+				Fun: funcLit(pos, nil,
+					&ast.DeferStmt{Defer: pos, Call: &ast.CallExpr{
+						Fun: funcLit(pos, nil,
+							recvStmt(pos, prev),
+							callStmt(pos, ident("close"), ident(next)),
+						),
+						Lparen: pos,
+						Rparen: pos,
+					}},
+					recvStmt(pos, s.release),
+					runSubtest,
+				),
+				Lparen: pos,
+				Rparen: pos,
+			}},
+		)
+	} else {
+		stmts = append(stmts,
+			define(pos, []string{done}, makeChan(pos)),
+			&ast.GoStmt{Go: pos, Call: &ast.CallExpr{
+				Fun: funcLit(pos, nil,
+					&ast.DeferStmt{Defer: pos, Call: &ast.CallExpr{
+						Fun:    ident("close"),
+						Lparen: pos,
+						Args:   []ast.Expr{ident(done)},
+						Rparen: pos,
+					}},
+					runSubtest,
+				),
+				Lparen: pos,
+				Rparen: pos,
+			}},
+			recvStmt(pos, done),
+		)
+	}
+
+	stmts = append(stmts, &ast.ReturnStmt{Return: pos, Results: []ast.Expr{ident("true")}})
+
+	return &ast.CallExpr{
+		Fun:    funcLit(pos, &ast.FieldList{List: []*ast.Field{{Type: ident("bool")}}}, stmts...),
+		Lparen: pos,
+		Rparen: pos,
+	}
+}
+
+// registerCleanup models a call to t.Cleanup(fn) as:
+//
+//	func() {
+//		fn, prev := fn, cleanup
+//		cleanup = func() {
+//			fn()
+//			prev()
+//		}
+//	}()
+func (s state) registerCleanup(call *ast.CallExpr) ast.Expr {
+	pos := call.Pos()
+	const (
+		fn   = "_GOAT_SUBTEST_FN"
+		prev = "_GOAT_SUBTEST_PREV"
+	)
+
+	return &ast.CallExpr{
+		Fun: funcLit(pos, nil,
+			define(pos, []string{fn, prev}, call.Args[0], ident(s.cleanup)),
+			&ast.AssignStmt{
+				Lhs:    []ast.Expr{ident(s.cleanup)},
+				TokPos: pos,
+				Tok:    token.ASSIGN,
+				Rhs: []ast.Expr{funcLit(pos, nil,
+					callStmt(pos, ident(fn)),
+					callStmt(pos, ident(prev)),
+				)},
+			},
+		),
+		Lparen: pos,
+		Rparen: pos,
+	}
+}
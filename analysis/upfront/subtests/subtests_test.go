@@ -0,0 +1,98 @@
+package subtests
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const src = `
+package example
+
+import "testing"
+
+func TestSubtests(t *testing.T) {
+	t.Cleanup(func() {})
+
+	ch := make(chan int)
+	t.Run("sequential", func(t *testing.T) {
+		ch <- 1
+	})
+
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if !t.Run("nested", func(*testing.T) {}) {
+				t.Fail()
+			}
+		})
+	}
+
+	helper(t)
+}
+
+func helper(tb testing.TB) {
+	tb.Cleanup(func() {})
+}
+`
+
+func TestRewrite(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	tpkg, err := conf.Check("example.com/example", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Rewrite([]*packages.Package{{
+		PkgPath:   "example.com/example",
+		Fset:      fset,
+		Syntax:    []*ast.File{file},
+		Types:     tpkg,
+		TypesInfo: info,
+	}})
+
+	var buf strings.Builder
+	printer.Fprint(&buf, fset, file)
+	out := buf.String()
+	t.Log(out)
+
+	for expected, count := range map[string]int{
+		// Preambles of TestSubtests and the parallel subtest, and the parallel Run
+		"defer func() {":               3,
+		"close(_GOAT_SUBTEST_RELEASE_": 2,
+		"<-_GOAT_SUBTEST_RELEASE_0":    1,
+		"_GOAT_SUBTEST_CLEANUP_0 = ":   1,
+		"<-_GOAT_SUBTEST_RUN_DONE":     2,
+		"_GOAT_SUBTEST_DONE_0 = ":      1,
+		"t.Run(":                       0,
+		"t.Cleanup(":                   0,
+		"tb.Cleanup(func() {})":        1,
+		"t.Parallel()":                 1,
+		"_GOAT_SUBTEST_F(t)":           3,
+	} {
+		if actual := strings.Count(out, expected); actual != count {
+			t.Errorf("Expected %q to occur %d times, found %d", expected, count, actual)
+		}
+	}
+
+	if _, err := conf.Check("example.com/example", fset, []*ast.File{file}, nil); err != nil {
+		t.Error("Rewritten package does not type check:", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package timers models the goroutine spawned by time.AfterFunc by rewriting
+// calls to it, since the analysis does not expand the runtime timers.
+//
+// A call time.AfterFunc(d, f) becomes:
+//
+//	func() *time.Timer {
+//		d, f := time.Duration(d), f
+//		t := time.AfterFunc(d, f)
+//		go func() {
+//			if _, fired := <-t.C; fired {
+//				f()
+//			}
+//		}()
+//		return t
+//	}()
+//
+// The abstract interpreter models the channel of the returned timer as a
+// fired timer, and closes it if the timer is stopped before it fires, in
+// which case the goroutine exits without calling f. The channel only exists
+// in the model; AfterFunc timers have a nil channel at run time.
+//
+// The model is approximate: after a timer is stopped, resetting it does not
+// call f again. Calls in packages in GOROOT are not rewritten.
+package timers
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/cs-au-dk/goat/pkgutil"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	duration = "_GOAT_AFTER_D"
+	f        = "_GOAT_AFTER_F"
+	timer    = "_GOAT_TIMER"
+	fired    = "_GOAT_TIMER_FIRED"
+)
+
+// Rewrite models the calls to time.AfterFunc in every package that is not in
+// GOROOT. This must happen before loop inlining, which recomputes the type
+// information of the rewritten packages.
+func Rewrite(pkgs []*packages.Package) {
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkgutil.CheckPkgInGoroot(pkg.Types) {
+			return
+		}
+
+		for _, file := range pkg.Syntax {
+			astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+				if call, ok := c.Node().(*ast.CallExpr); ok && isAfterFunc(pkg.TypesInfo, call) {
+					c.Replace(afterFunc(call))
+				}
+				return true
+			})
+		}
+	})
+}
+
+// isAfterFunc returns true if the call is a call to time.AfterFunc.
+func isAfterFunc(info *types.Info, call *ast.CallExpr) bool {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+
+	fun, ok := info.Uses[id].(*types.Func)
+	return ok && len(call.Args) == 2 && fun.FullName() == "time.AfterFunc"
+}
+
+func ident(name string) *ast.Ident {
+	return &ast.Ident{Name: name}
+}
+
+// timeType returns the named type in the time package, qualified like the
+// call to AfterFunc.
+func timeType(call *ast.CallExpr, name string) ast.Expr {
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if x, ok := sel.X.(*ast.Ident); ok {
+			return &ast.SelectorExpr{X: ident(x.Name), Sel: ident(name)}
+		}
+	}
+	return ident(name)
+}
+
+func afterFunc(call *ast.CallExpr) ast.Expr {
+	pos := call.Pos()
+
+	define := func(lhs []ast.Expr, rhs ...ast.Expr) ast.Stmt {
+		return &ast.AssignStmt{Lhs: lhs, TokPos: pos, Tok: token.DEFINE, Rhs: rhs}
+	}
+
+	// if _, fired := <-t.C; fired { f() }
+	wait := &ast.IfStmt{
+		If: pos,
+		Init: define(
+			[]ast.Expr{ident("_"), ident(fired)},
+			&ast.UnaryExpr{OpPos: pos, Op: token.ARROW, X: &ast.SelectorExpr{X: ident(timer), Sel: ident("C")}},
+		),
+		Cond: ident(fired),
+		Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{Fun: ident(f), Lparen: pos, Rparen: pos}},
+		}, Rbrace: pos},
+	}
+
+	body := []ast.Stmt{
+		// The duration is converted, since it may be an untyped constant.
+		define([]ast.Expr{ident(duration), ident(f)},
+			&ast.CallExpr{Fun: timeType(call, "Duration"), Lparen: pos, Args: call.Args[:1], Rparen: pos},
+			call.Args[1],
+		),
+		define([]ast.Expr{ident(timer)}, &ast.CallExpr{
+			Fun:    call.Fun,
+			Lparen: pos,
+			Args:   []ast.Expr{ident(duration), ident(f)},
+			Rparen: pos,
+		}),
+		&ast.GoStmt{Go: pos, Call: &ast.CallExpr{
+			Fun: &ast.FuncLit{
+				Type: &ast.FuncType{Func: pos, Params: &ast.FieldList{}},
+				Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{wait}, Rbrace: pos},
+			},
+			Lparen: pos,
+			Rparen: pos,
+		}},
+		&ast.ReturnStmt{Return: pos, Results: []ast.Expr{ident(timer)}},
+	}
+
+	return &ast.CallExpr{
+		Fun: &ast.FuncLit{
+			Type: &ast.FuncType{
+				Func:    pos,
+				Params:  &ast.FieldList{},
+				Results: &ast.FieldList{List: []*ast.Field{{Type: &ast.StarExpr{X: timeType(call, "Timer")}}}},
+			},
+			Body: &ast.BlockStmt{Lbrace: pos, List: body, Rbrace: pos},
+		},
+		Lparen: pos,
+		Rparen: pos,
+	}
+}
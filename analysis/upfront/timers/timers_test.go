@@ -0,0 +1,80 @@
+package timers
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const src = `
+package example
+
+import (
+	"time"
+	clock "time"
+)
+
+func Example(ch chan int) {
+	t := time.AfterFunc(time.Second, func() {
+		clock.AfterFunc(0, func() { ch <- 1 })
+	})
+	if !t.Stop() {
+		<-ch
+	}
+}
+`
+
+func TestRewrite(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	tpkg, err := conf.Check("example.com/example", fset, []*ast.File{file}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Rewrite([]*packages.Package{{
+		PkgPath:   "example.com/example",
+		Fset:      fset,
+		Syntax:    []*ast.File{file},
+		Types:     tpkg,
+		TypesInfo: info,
+	}})
+
+	var buf strings.Builder
+	printer.Fprint(&buf, fset, file)
+	out := buf.String()
+	t.Log(out)
+
+	for expected, count := range map[string]int{
+		"func() *time.Timer {":                                    1,
+		"func() *clock.Timer {":                                   1,
+		"time.AfterFunc(_GOAT_AFTER_D, _GOAT_AFTER_F)":            1,
+		"clock.AfterFunc(_GOAT_AFTER_D, _GOAT_AFTER_F)":           1,
+		"_GOAT_TIMER_FIRED := <-_GOAT_TIMER.C; _GOAT_TIMER_FIRED": 2,
+		"t.Stop()": 1,
+	} {
+		if actual := strings.Count(out, expected); actual != count {
+			t.Errorf("Expected %q to occur %d times, found %d", expected, count, actual)
+		}
+	}
+
+	if _, err := conf.Check("example.com/example", fset, []*ast.File{file}, nil); err != nil {
+		t.Error("Rewritten package does not type check:", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ai "github.com/cs-au-dk/goat/analysis/absint"
+	"github.com/cs-au-dk/goat/analysis/gotopo"
+	tu "github.com/cs-au-dk/goat/testutil"
+
+	"github.com/fatih/color"
+	"golang.org/x/tools/go/ssa"
+	"gopkg.in/yaml.v3"
+)
+
+// groundTruth maps every benchmark program, identified by its import path
+// relative to the root of the suite (e.g. "cockroach/1055"), to the source
+// lines where goroutines may block forever, as "<file>:<line>" relative to
+// the directory of the program. Programs without blocked lines are negative
+// controls, where every report is a false positive.
+type groundTruth map[string][]string
+
+// loadGroundTruth reads a ground truth manifest in YAML (or JSON) format.
+func loadGroundTruth(path string) (groundTruth, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var truth groundTruth
+	if err := yaml.Unmarshal(contents, &truth); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for program, lines := range truth {
+		for _, line := range lines {
+			if i := strings.LastIndex(line, ":"); i <= 0 {
+				return nil, fmt.Errorf("%s: %s: expected <file>:<line>, got %q", path, program, line)
+			} else if n, err := strconv.Atoi(line[i+1:]); err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s: %s: invalid line number in %q", path, program, line)
+			}
+		}
+	}
+
+	return truth, nil
+}
+
+// benchRun collects the outcome of analysing every primitive set of a
+// benchmark program.
+type benchRun struct {
+	// Blocked lines, as "<file>:<line>" relative to the directory of the
+	// program, where goroutines deadlock or leak when the program exits.
+	blocked, leaked map[string]struct{}
+	// Number of analysed primitive sets by outcome.
+	completes, skips, aborts int
+}
+
+// benchResult scores the reports for a single benchmark program against the ground truth.
+type benchResult struct {
+	program string
+	// Blocked lines that are reported (true positives), reported but not
+	// in the ground truth (false positives), or not reported (false negatives).
+	truePositives, falsePositives, falseNegatives []string
+	// Reported lines where goroutines only leak when the program exits.
+	// GoKer detects bugs as goroutines that are still blocked when the test
+	// ends, so they are scored, but are not counted as deadlocks.
+	leaks []string
+	// Number of primitive sets that timed out or were aborted.
+	skips, aborts int
+}
+
+// project returns the project of the benchmark program, e.g. "cockroach".
+func (r benchResult) project() string {
+	return strings.SplitN(r.program, "/", 2)[0]
+}
+
+// score compares the lines reported for the program with the ground truth.
+func (truth groundTruth) score(program string, run benchRun) benchResult {
+	res := benchResult{program: program, skips: run.skips, aborts: run.aborts}
+
+	reported := make(map[string]struct{}, len(run.blocked)+len(run.leaked))
+	for line := range run.blocked {
+		reported[line] = struct{}{}
+	}
+	for line := range run.leaked {
+		if _, found := run.blocked[line]; !found {
+			reported[line] = struct{}{}
+			res.leaks = append(res.leaks, line)
+		}
+	}
+
+	expected := make(map[string]struct{}, len(truth[program]))
+	for _, line := range truth[program] {
+		expected[line] = struct{}{}
+		if _, found := reported[line]; found {
+			res.truePositives = append(res.truePositives, line)
+		} else {
+			res.falseNegatives = append(res.falseNegatives, line)
+		}
+	}
+	for line := range reported {
+		if _, found := expected[line]; !found {
+			res.falsePositives = append(res.falsePositives, line)
+		}
+	}
+
+	for _, lines := range [][]string{res.truePositives, res.falsePositives, res.falseNegatives, res.leaks} {
+		sort.Strings(lines)
+	}
+	return res
+}
+
+type benchSummary []benchResult
+
+// ratio formats n / (n + m), or "-" if both are 0.
+func ratio(n, m int) string {
+	if n+m == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", float64(n)/float64(n+m))
+}
+
+func (s benchSummary) String() string {
+	type row struct {
+		programs, tps, fps, fns, leaks, skips, aborts int
+	}
+
+	rows := map[string]*row{}
+	projects := []string{}
+	total := &row{}
+	for _, r := range s {
+		p, found := rows[r.project()]
+		if !found {
+			p = &row{}
+			rows[r.project()] = p
+			projects = append(projects, r.project())
+		}
+
+		for _, x := range []*row{p, total} {
+			x.programs++
+			x.tps += len(r.truePositives)
+			x.fps += len(r.falsePositives)
+			x.fns += len(r.falseNegatives)
+			x.leaks += len(r.leaks)
+			x.skips += r.skips
+			x.aborts += r.aborts
+		}
+	}
+	sort.Strings(projects)
+
+	sb := &strings.Builder{}
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Project\tPrograms\tTP\tFP\tFN\tLeaks\tPrecision\tRecall\tTimeouts\tAborts")
+	printRow := func(name string, r *row) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%d\t%d\n",
+			name, r.programs, r.tps, r.fps, r.fns, r.leaks,
+			ratio(r.tps, r.fps), ratio(r.tps, r.fns), r.skips, r.aborts)
+	}
+	for _, project := range projects {
+		printRow(project, rows[project])
+	}
+	printRow("Total", total)
+
+	w.Flush()
+	return sb.String()
+}
+
+func (s benchSummary) Log() {
+	fmt.Println()
+	fmt.Println("================ Benchmark ===================")
+	for _, r := range s {
+		for _, line := range r.falsePositives {
+			fmt.Println(color.RedString("False positive:"), r.program+"/"+line)
+		}
+		for _, line := range r.falseNegatives {
+			fmt.Println(color.YellowString("False negative:"), r.program+"/"+line)
+		}
+	}
+	fmt.Println()
+	fmt.Println(s)
+}
+
+// relativeLine returns the position as "<file>:<line>" relative to the
+// directory of the benchmark program.
+func relativeLine(program, filename string, line int) string {
+	filename = filepath.ToSlash(filename)
+	if i := strings.LastIndex(filename, "/"+program+"/"); i >= 0 {
+		filename = filename[i+len(program)+2:]
+	} else {
+		filename = filepath.Base(filename)
+	}
+	return fmt.Sprintf("%s:%d", filename, line)
+}
+
+// analyzeBenchProgram analyzes every primitive set that is reachable from
+// the main function of a benchmark program, like -task collect-primitives,
+// and collects the lines of the blocked operations. Each primitive set is
+// skipped if it exceeds the timeout, and aborted if the analysis panics.
+func analyzeBenchProgram(program string, loadRes tu.LoadResult, entry *ssa.Function, timeout time.Duration) benchRun {
+	res := benchRun{blocked: make(map[string]struct{}), leaked: make(map[string]struct{})}
+
+	G := loadRes.PrunedCallDAG.Original
+	computeDominator := G.DominatorTree(entry)
+
+	ps, primsToUses := gotopo.GetPrimitives(entry, loadRes.Pointer, G)
+	psets := entryPSets(loadRes.Prog, loadRes.Cfg, loadRes.Pointer, G,
+		entry, ps, computeDominator, loadRes.PrunedCallDAG, nil)
+
+	// Ensure consistent ordering
+	sort.Slice(psets, func(i, j int) bool {
+		return psets[i].String() < psets[j].String()
+	})
+
+	cfgFunctions := loadRes.Cfg.Functions()
+	for i, pset := range psets {
+		funs := []*ssa.Function{}
+		pset.ForEach(func(v ssa.Value) {
+			if v.Parent() != nil {
+				// Include allocation site in dominator computation
+				funs = append(funs, v.Parent())
+			}
+			for fun := range primsToUses[v] {
+				funs = append(funs, fun)
+			}
+		})
+
+		loweredEntry := computeDominator(funs...)
+		if _, found := cfgFunctions[loweredEntry]; !found {
+			log.Println(color.YellowString("CFG does not contain the entry function."))
+			continue
+		}
+
+		log.Printf("PSet %d of %d, using %v as entrypoint", i+1, len(psets), loweredEntry)
+
+		C := ai.ConfigAI(ai.AIConfig{Metrics: true, Log: opts.LogAI()}).Function(loweredEntry)(loadRes)
+		C.FragmentPredicateFromPrimitives(pset.Entries(), primsToUses)
+
+		blocks := func() ai.Blocks {
+			defer func() {
+				if err := recover(); err != nil {
+					C.Metrics.Panic(err)
+				}
+			}()
+
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-time.After(timeout):
+					C.Metrics.Skip()
+				case <-done:
+				}
+			}()
+
+			C.Metrics.TimerStart()
+			G, A := ai.StaticAnalysis(C)
+			if C.Metrics.Outcome != "" {
+				return nil
+			}
+
+			C.Metrics.Done()
+			return ai.BlockAnalysisFiltered(C, G, A, true)
+		}()
+
+		switch C.Metrics.Outcome {
+		case ai.OUTCOME_SKIP:
+			log.Println(color.RedString("Skipped!"))
+			res.skips++
+		case ai.OUTCOME_PANIC:
+			log.Println(color.RedString("Aborted!"))
+			log.Println(C.Metrics.Error())
+			res.aborts++
+		default:
+			res.completes++
+			record := func(lines map[string]struct{}, blocks ai.Blocks) {
+				for _, cl := range blocks.CtrLocs() {
+					if pos := loadRes.Prog.Fset.Position(cl.Node().Pos()); pos.IsValid() {
+						lines[relativeLine(program, pos.Filename, pos.Line)] = struct{}{}
+					}
+				}
+			}
+			record(res.blocked, blocks.Deadlocks())
+			record(res.leaked, blocks.Leaks())
+		}
+	}
+
+	return res
+}
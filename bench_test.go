@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cs-au-dk/goat/utils"
+)
+
+// blocksAnnotation matches a //@ annotation containing "blocks", including
+// disabled annotations written as // @.
+var blocksAnnotation = regexp.MustCompile(`//\s*@.*\bblocks\b`)
+
+func TestGroundTruthMatchesAnnotations(t *testing.T) {
+	root := filepath.Join("examples", "src", utils.GoBenchRoot)
+	truth, err := loadGroundTruth(filepath.Join(filepath.Dir(root), "ground-truth.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	programs, err := filepath.Glob(filepath.Join(root, "*", "*", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(programs) != len(truth) {
+		t.Errorf("Expected %d programs in the ground truth, found %d", len(programs), len(truth))
+	}
+
+	for _, path := range programs {
+		program := filepath.ToSlash(filepath.Dir(strings.TrimPrefix(path, root+string(filepath.Separator))))
+		expected, found := truth[program]
+		if !found {
+			t.Errorf("%s is not in the ground truth", program)
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var annotated []string
+		lines := 0
+		for sc := bufio.NewScanner(f); sc.Scan(); {
+			lines++
+			if blocksAnnotation.MatchString(sc.Text()) {
+				annotated = append(annotated, relativeLine(program, path, lines))
+			}
+		}
+		f.Close()
+
+		switch {
+		case strings.HasSuffix(program, "_fixed"):
+			if len(expected) != 0 {
+				t.Errorf("Expected no blocked lines for %s, got %v", program, expected)
+			}
+		case len(annotated) > 0:
+			if !reflect.DeepEqual(expected, annotated) {
+				t.Errorf("Expected %v for %s, got %v", annotated, program, expected)
+			}
+		case len(expected) == 0:
+			t.Errorf("Expected blocked lines for %s", program)
+		}
+	}
+}
+
+func TestLoadGroundTruthErrors(t *testing.T) {
+	for _, contents := range []string{
+		"cockroach/584: [25]",
+		"cockroach/584: [main.go]",
+		"cockroach/584: [main.go:0]",
+		"cockroach/584: main.go:25",
+	} {
+		path := filepath.Join(t.TempDir(), "ground-truth.yaml")
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadGroundTruth(path); err == nil {
+			t.Errorf("Expected an error for %q", contents)
+		}
+	}
+}
+
+func TestBenchScore(t *testing.T) {
+	truth := groundTruth{
+		"cockroach/1055":       {"main.go:37", "main.go:45"},
+		"cockroach/1055_fixed": {},
+		"grpc/795":             {"main.go:15", "main.go:22"},
+	}
+
+	run := func(lines ...string) benchRun {
+		res := benchRun{blocked: make(map[string]struct{}), leaked: make(map[string]struct{})}
+		for _, line := range lines {
+			res.blocked[line] = struct{}{}
+		}
+		return res
+	}
+
+	// Lines where goroutines also deadlock are not counted as leaks.
+	leaking := run("main.go:45")
+	for _, line := range []string{"main.go:37", "main.go:45", "main.go:93"} {
+		leaking.leaked[line] = struct{}{}
+	}
+
+	res := truth.score("cockroach/1055", leaking)
+	if !reflect.DeepEqual(res.truePositives, []string{"main.go:37", "main.go:45"}) ||
+		!reflect.DeepEqual(res.falsePositives, []string{"main.go:93"}) ||
+		len(res.falseNegatives) != 0 ||
+		!reflect.DeepEqual(res.leaks, []string{"main.go:37", "main.go:93"}) {
+		t.Errorf("Unexpected score %+v", res)
+	}
+
+	fixed := truth.score("cockroach/1055_fixed", run("main.go:45"))
+	if len(fixed.truePositives) != 0 || len(fixed.falsePositives) != 1 || len(fixed.falseNegatives) != 0 {
+		t.Errorf("Expected a false positive for the fixed program, got %+v", fixed)
+	}
+
+	timedOut := run()
+	timedOut.skips, timedOut.aborts = 2, 1
+	grpc := truth.score("grpc/795", timedOut)
+
+	table := benchSummary{res, fixed, grpc}.String()
+	for _, expected := range []string{
+		"cockroach  2         2   2   0   2      0.50       1.00    0         0",
+		"grpc       1         0   0   2   0      -          0.00    2         1",
+		"Total      3         2   2   2   2      0.50       0.50    2         1",
+	} {
+		if !strings.Contains(table, expected) {
+			t.Errorf("Expected table to contain %q:\n%s", expected, table)
+		}
+	}
+}
+
+func TestRelativeLine(t *testing.T) {
+	for _, test := range []struct {
+		filename, expected string
+	}{
+		{"/src/gobench/goker/blocking/cockroach/1055/main.go", "main.go:10"},
+		{"/src/gobench/goker/blocking/cockroach/1055/sub/util.go", "sub/util.go:10"},
+		{"/src/other/main.go", "main.go:10"},
+	} {
+		if res := relativeLine("cockroach/1055", test.filename, 10); res != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.filename, res)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Package queue is a library without a main function. Analyse it with:
+//
+//	goat -gopath examples -harness harness-queue.Queue harness-queue
+package queue
+
+import "sync"
+
+type Queue struct {
+	mu    sync.Mutex
+	items chan int
+}
+
+func NewQueue(size int) *Queue {
+	return &Queue{items: make(chan int, size)}
+}
+
+// Push blocks while holding the lock if the queue is full, which prevents
+// concurrent calls to Pop from making progress.
+func (q *Queue) Push(v int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items <- v
+}
+
+func (q *Queue) Pop() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return <-q.items
+}
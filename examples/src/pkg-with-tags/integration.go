@@ -0,0 +1,9 @@
+//go:build integration
+
+package main
+
+func Integration() {
+	ch := make(chan int)
+	go func() { ch <- 1 }()
+	<-ch
+}
@@ -0,0 +1,5 @@
+package main
+
+func LinuxArm64() {
+	println("linux/arm64")
+}
@@ -0,0 +1,5 @@
+package lib
+
+func F() {
+	println("Hello from the workspace")
+}
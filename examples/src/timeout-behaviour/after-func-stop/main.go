@@ -0,0 +1,15 @@
+// Command after-func-stop stops a timer created by time.AfterFunc before
+// waiting for the scheduled function. If Stop prevents the function from
+// running, main blocks forever.
+package main
+
+import "time"
+
+func main() {
+	done := make(chan struct{})
+	t := time.AfterFunc(time.Second, func() {
+		close(done)
+	})
+	t.Stop()
+	<-done //@ blocks
+}
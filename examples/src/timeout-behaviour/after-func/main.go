@@ -0,0 +1,13 @@
+// Command after-func receives a value sent by a function scheduled with
+// time.AfterFunc, which runs on its own goroutine.
+package main
+
+import "time"
+
+func main() {
+	ch := make(chan int)
+	time.AfterFunc(time.Second, func() {
+		ch <- 42 //@ releases
+	})
+	println("received", <-ch) //@ releases
+}
@@ -0,0 +1,19 @@
+// Command stop-not-drained uses the idiom for stopping a timer and draining
+// its channel on a timer whose channel has already been drained. Stop then
+// returns false, and the second receive blocks forever.
+package main
+
+import "time"
+
+func main() {
+	t := time.NewTimer(time.Second)
+	<-t.C //@ releases
+
+	// Resetting the timer makes it fire again.
+	t.Reset(time.Second)
+	<-t.C //@ releases
+
+	if !t.Stop() {
+		<-t.C //@ blocks
+	}
+}
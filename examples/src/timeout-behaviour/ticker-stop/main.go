@@ -0,0 +1,15 @@
+// Command ticker-stop waits for a tick after stopping a ticker, which blocks
+// forever, since a stopped ticker delivers no more ticks.
+package main
+
+import "time"
+
+func main() {
+	tk := time.NewTicker(time.Second)
+	for i := 0; i < 3; i++ {
+		<-tk.C //@ releases
+	}
+
+	tk.Stop()
+	<-tk.C //@ blocks
+}
@@ -0,0 +1,12 @@
+package main
+
+import "user-models/queue"
+
+func main() {
+	q := queue.New()
+	queue.Go(func() {
+		q.Push(10)
+	})
+	q.Pop()
+	q.Pop() // Blocks forever: there is only one Push
+}
@@ -0,0 +1,37 @@
+package queue
+
+import "sync"
+
+// Queue is an unbounded queue. Its implementation is opaque to the analysis,
+// but models.yaml describes it as a channel.
+type Queue struct {
+	mu    sync.Mutex
+	items []int
+	ch    chan int
+}
+
+func New() *Queue {
+	return &Queue{ch: make(chan int)}
+}
+
+func (q *Queue) Push(v int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, v)
+}
+
+func (q *Queue) Pop() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return 0, false
+	}
+	v := q.items[0]
+	q.items = q.items[1:]
+	return v, true
+}
+
+// Go runs f in a new goroutine.
+func Go(f func()) {
+	go f()
+}
@@ -307,6 +307,7 @@ func BuildGraph(prog *ssa.Program, result *pointer.Result, goros []*upfront.Goro
 	}
 
 	dotG := &dot.DotGraph{
+		Name:     "goroutine-topology",
 		Title:    "Goroutine topology",
 		Clusters: clusters,
 		Nodes:    nodes,
@@ -320,6 +321,15 @@ func BuildGraph(prog *ssa.Program, result *pointer.Result, goros []*upfront.Goro
 	fmt.Printf("Clusters: %d\nNodes: %d\nEdges: %d\n",
 		len(clusters), len(nodeMap), len(edgeMap))
 
+	if opts.OutputDir() != "" {
+		paths, err := dotG.Save()
+		if err != nil {
+			fmt.Println(err)
+			return ""
+		}
+		return strings.Join(paths, "\n")
+	}
+
 	var buf bytes.Buffer
 	if err := dotG.WriteDot(&buf); err != nil {
 		fmt.Println(err)
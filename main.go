@@ -3,19 +3,24 @@ package main
 import (
 	// "github.com/cs-au-dk/goat/solver"
 
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cs-au-dk/goat/analysis/upfront/chreflect"
 	"github.com/cs-au-dk/goat/analysis/upfront/loopinline"
+	"github.com/cs-au-dk/goat/analysis/upfront/models"
+	"github.com/cs-au-dk/goat/analysis/upfront/subtests"
+	"github.com/cs-au-dk/goat/analysis/upfront/timers"
 	dotg "github.com/cs-au-dk/goat/graph"
 	"github.com/cs-au-dk/goat/pkgutil"
 	tu "github.com/cs-au-dk/goat/testutil"
@@ -28,6 +33,7 @@ import (
 	"github.com/cs-au-dk/goat/analysis/cfg"
 	"github.com/cs-au-dk/goat/analysis/defs"
 	"github.com/cs-au-dk/goat/analysis/gotopo"
+	L "github.com/cs-au-dk/goat/analysis/lattice"
 	u "github.com/cs-au-dk/goat/analysis/upfront"
 
 	"github.com/fatih/color"
@@ -43,11 +49,16 @@ import (
 var (
 	opts = utils.Opts()
 	task = opts.Task()
+
+	suppressor *ai.Suppressor
+
+	// Number of HTML reports written, see writeHTMLReport.
+	htmlReports int32
 )
 
 func main() {
 	utils.ParseArgs()
-	path := utils.MakePath()
+	patterns := utils.MakePatterns()
 
 	if opts.HttpDebug() {
 		go func() {
@@ -55,17 +66,95 @@ func main() {
 		}()
 	}
 
-	pkgs, err := pkgutil.LoadPackages(pkgutil.LoadConfig{
+	loadConfig := pkgutil.LoadConfig{
 		GoPath:       opts.GoPath(),
 		ModulePath:   opts.ModulePath(),
+		WorkPath:     opts.WorkPath(),
 		IncludeTests: opts.IncludeTests(),
-	}, path)
+		Tags:         opts.BuildTags(),
+		GOOS:         opts.GOOS(),
+		GOARCH:       opts.GOARCH(),
+	}
+	pkgs, err := pkgutil.LoadPackages(loadConfig, patterns...)
+	if loadErrs, ok := err.(pkgutil.LoadErrors); ok {
+		// Report the errors of each package, and continue with the packages
+		// that were loaded successfully.
+		for _, e := range loadErrs {
+			log.Println(e)
+		}
+
+		pkgs = pkgutil.WellTyped(pkgs)
+		if len(pkgs) == 0 {
+			err = errors.New("no packages were loaded without errors")
+		} else {
+			log.Printf("Skipping packages with load errors, continuing with %d package(s)\n", len(pkgs))
+			err = nil
+		}
+	}
 	if err != nil {
-		log.Printf("Failed pkgutil.LoadPackages(GoPath=\"%s\", ModulePath=\"%s\", IncludeTests=%v)\n", opts.GoPath(), opts.ModulePath(), opts.IncludeTests())
+		log.Printf("Failed pkgutil.LoadPackages(%+v, %v)\n", loadConfig, patterns)
 		log.Println(err)
 		os.Exit(1)
 	}
 
+	// Whether the analysed program is a generated harness.
+	generatedHarness := false
+	if targets := opts.Harness(); len(targets) > 0 {
+		// Analyse the library through a generated main package exercising its API.
+		harness, err := pkgutil.GenerateHarness(pkgs, targets, opts.HarnessGoros())
+		if err != nil {
+			log.Fatalln("Unable to generate harness:", err)
+		}
+		opts.OnVerbose(func() {
+			fmt.Println(string(harness.Source))
+		})
+
+		loadConfig.Overlay = map[string][]byte{harness.File: harness.Source}
+		if pkgs, err = pkgutil.LoadPackages(loadConfig, harness.PkgPath); err != nil {
+			log.Fatalln("Unable to load harness:", err)
+		}
+		generatedHarness = true
+	}
+
+	var baseline ai.Report
+	if path := opts.BaselinePath(); path != "" {
+		if baseline, err = ai.LoadReport(path); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	suppressor = ai.NewSuppressor(pkgutil.CollectIgnoreDirectives(pkgs), opts.Suppressions(), baseline)
+
+	if exclude := opts.Exclude(); len(exclude) > 0 {
+		pkgs = pkgutil.Exclude(pkgs, exclude)
+		if len(pkgs) == 0 {
+			log.Fatalln("All packages are excluded by", exclude)
+		}
+	}
+
+	if path := opts.ModelsPath(); path != "" {
+		spec, err := models.Load(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		models.Use(spec)
+
+		unresolved, err := models.Rewrite(pkgs)
+		if err != nil {
+			log.Fatalln("Applying models failed:", err)
+		}
+		for _, name := range unresolved {
+			log.Println(color.YellowString("No source code found for modelled function %s", name))
+		}
+	}
+
+	// Model the goroutines spawned by time.AfterFunc.
+	timers.Rewrite(pkgs)
+
+	if opts.IncludeTests() {
+		// Model the scheduling of subtests and cleanup functions.
+		subtests.Rewrite(pkgs)
+	}
+
 	// pkgs = u.UnrollLoops(pkgs)
 	err = loopinline.InlineLoops(pkgs)
 	if err != nil {
@@ -143,6 +232,24 @@ func main() {
 		return ptaResult, progCfg, goros
 	}
 
+	// Runs the pre-analyses required for abstract interpretation from the root of the call graph.
+	wholeProgramPipeline := func(includes u.IncludeType) tu.LoadResult {
+		ptaResult, progCfg := preanalysisPipeline(includes)
+		cg := ptaResult.CallGraph
+		entries := []*ssa.Function{cg.Root.Func}
+		loadRes := tu.LoadResult{
+			Prog:    prog,
+			Mains:   mains,
+			Cfg:     progCfg,
+			Pointer: ptaResult,
+			CallDAG: graph.FromCallGraph(cg, false).SCC(entries),
+		}
+		loadRes.PrunedCallDAG = graph.FromCallGraph(cg, true).SCC(entries)
+		loadRes.CtrLocPriorities = u.GetCtrLocPriorities(progCfg.Functions(), loadRes.PrunedCallDAG)
+		loadRes.WrittenFields = u.ComputeWrittenFields(ptaResult, loadRes.PrunedCallDAG)
+		return loadRes
+	}
+
 	// States queries for which types to include the Andersen points-to analysis
 	standardPTAnalysisQueries := u.IncludeType{
 		Chan:      true,
@@ -150,9 +257,13 @@ func main() {
 		Interface: true,
 	}
 
+	// Exit status of the tool
+	exitCode := 0
+
 	aiConfig := ai.AIConfig{
-		Metrics: opts.Metrics(),
-		Log:     opts.LogAI(),
+		Metrics:          opts.Metrics(),
+		Log:              opts.LogAI(),
+		GeneratedHarness: generatedHarness,
 	}
 
 	switch {
@@ -325,10 +436,30 @@ func main() {
 			return entries[i].String() < entries[j].String()
 		})
 
+		var userPsets gotopo.PSets
+		if opts.PSets().User() {
+			sites, err := pkgutil.CollectPSetDirectives(pkgs)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if path := opts.PSetPath(); path != "" {
+				fileSites, err := pkgutil.ReadPSetFile(path)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				sites = append(sites, fileSites...)
+			}
+
+			if userPsets, err = gotopo.GetUserPSets(prog, sites); err != nil {
+				log.Fatalln(err)
+			}
+		}
+
 		if len(entries) == 0 {
 			log.Println("Skipping benchmark since it has no entries")
-			return
-		} else {
+			// Break instead of returning, such that the report is still written.
+			break
+		} else if !opts.PSets().User() {
 			// This is a cheap check to see if we can avoid processing the package altogether.
 			// If there is no reachable local channel allocation in the RTA call graph,
 			// there is no need to do expensive (pointer) pre-analyses.
@@ -352,7 +483,7 @@ func main() {
 				return false
 			}, entries...) {
 				log.Println("Skipping benchmark since it has no local channel allocations")
-				return
+				break
 			}
 		}
 
@@ -393,47 +524,7 @@ func main() {
 
 			ps, primsToUses := gotopo.GetPrimitives(entry, pt, G)
 
-			psets := func() (psets gotopo.PSets) {
-				switch {
-				case opts.PSets().SameFunc():
-					return gotopo.GetSameFuncPsets(ps)
-				case opts.PSets().GCatch():
-					return gotopo.GetGCatchPSets(
-						pcfg, entry, pt, G,
-						computeDominator, callDAG, ps) // GCatch Psets
-				case opts.PSets().Total():
-					return gotopo.GetTotalPset(ps) // Singular whole program p-set
-				case opts.PSets().Singleton():
-					fallthrough
-				default:
-					return gotopo.GetSingletonPsets(ps) // Singleton sets
-				}
-			}()
-
-			// Remove channels from PSets where the channel flows into the reflection library
-			if reflectedChans := chreflect.GetReflectedChannels(prog, pt); !reflectedChans.Empty() {
-				log.Printf("%s:\n%v",
-					color.YellowString("Pruning channels from PSets that flow into the reflection library"),
-					reflectedChans)
-
-				// Since pruning may introduce duplicate PSets, we use hashing
-				// to ensure they are not present in the output.
-				newPsets := make(gotopo.PSets, 0, len(psets))
-				seen := hmap.NewMap[bool](utils.SSAValueSetHasher)
-
-				for _, pset := range psets {
-					reflectedChans.ForEach(func(rCh ssa.Value) {
-						pset.Map = pset.Delete(rCh)
-					})
-
-					if !pset.Empty() && !seen.Get(pset) {
-						seen.Set(pset, true)
-						newPsets = append(newPsets, pset)
-					}
-				}
-
-				psets = newPsets
-			}
+			psets := entryPSets(prog, pcfg, pt, G, entry, ps, computeDominator, callDAG, userPsets)
 
 			//log.Printf("%s", psets)
 
@@ -516,7 +607,7 @@ func main() {
 				C.FragmentPredicateFromPrimitives(pset.Entries(), primsToUses)
 
 				done := make(chan bool, 1)
-				timeout := 60 * time.Second
+				timeout := opts.TimeoutOr(60 * time.Second)
 				go func() {
 					select {
 					case <-time.After(timeout):
@@ -545,16 +636,46 @@ func main() {
 					log.Println(color.GreenString("SA completed in %s", C.Metrics.Performance()))
 					completes++
 
-					blocks := ai.BlockAnalysisFiltered(C, ts, analysis, true)
+					blocks := suppress(ai.BlockAnalysisFiltered(C, ts, analysis, true))
+					if opts.HTMLPath() != "" {
+						writeHTMLReport(fmt.Sprintf("PSet %d of %s", i+1, loweredEntry), ts, analysis, blocks)
+					}
 					if len(blocks) == 0 {
 						log.Println(color.GreenString("No blocking bugs detected"))
 					} else {
 						blocks.Log()
 
+						if opts.PathFormat() != "text" {
+							printPathDiagrams(blocks, ts, G)
+						}
 						if opts.Visualize() {
-							blocks.PrintPath(ts, analysis, G)
+							if opts.PathFormat() == "text" {
+								blocks.PrintPath(ts, analysis, G)
+							}
 							ts.Visualize(blocks)
 						}
+
+						if opts.Minimize() {
+							prepare := func() ai.AnalysisCtxt {
+								return ai.ConfigAI(aiConfig).Function(loweredEntry)(loadRes)
+							}
+							for _, cl := range blocks.CtrLocs() {
+								ai.MinimizeFragment(prepare, pset.Entries(), primsToUses, cl, timeout).Log()
+							}
+						}
+					}
+
+					if leaks := ai.LockLeakAnalysis(C, ts, analysis, blocks); len(leaks) > 0 {
+						leaks.Log()
+					}
+					if lbs := ai.LockedBlockingAnalysis(C, ts, analysis); len(lbs) > 0 {
+						lbs.Log()
+					}
+					if livelocks := ai.LivelockAnalysis(ts); len(livelocks) > 0 {
+						livelocks.Log()
+					}
+					if cws := ai.CondAnalysis(C, ts, analysis); len(cws) > 0 {
+						cws.Log()
 					}
 
 				}
@@ -568,7 +689,7 @@ func main() {
 					syncConfsWithPrimitive := 0
 					ts.ForEach(func(conf *ai.AbsConfiguration) {
 						state := analysis.GetUnsafe(conf.Superlocation())
-						if !conf.IsPanicked() && conf.IsSynchronizing(C, state) {
+						if !conf.IsCrashed() && conf.IsSynchronizing(C, state) {
 							mem := state.Memory()
 							_, _, found := conf.Threads().Find(func(g defs.Goro, cl defs.CtrLoc) bool {
 								for _, prim := range cfg.CommunicationPrimitivesOf(cl.Node()) {
@@ -598,6 +719,54 @@ func main() {
 
 		log.Printf("Completed runs: %d, skipped runs: %d, aborted runs: %d", completes, skips, aborts)
 
+	case task.IsBenchGoBench():
+		truth, err := loadGroundTruth(opts.GroundTruthPath())
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// Analyse each benchmark program as its own unit, sharing the
+		// loaded packages and SSA program between programs.
+		units := mains
+		sort.Slice(units, func(i, j int) bool {
+			return units[i].Pkg.Path() < units[j].Pkg.Path()
+		})
+
+		summary := make(benchSummary, 0, len(units))
+		for i, unit := range units {
+			program := strings.TrimPrefix(unit.Pkg.Path(), utils.GoBenchRoot+"/")
+			if _, found := truth[program]; !found {
+				log.Println(color.YellowString("Skipping %s since it is not in the ground truth", program))
+				continue
+			}
+
+			fmt.Println()
+			log.Printf("Program %d of %d: %s", i+1, len(units), program)
+
+			mains = []*ssa.Package{unit}
+			pkgutil.GetLocalPackages(mains, allPackages)
+
+			entry := unit.Func("main")
+			pt, pcfg := preanalysisPipeline(u.IncludeType{All: true})
+			G := graph.FromCallGraph(pt.CallGraph, true)
+			callDAG := G.SCC([]*ssa.Function{entry})
+			loadRes := tu.LoadResult{
+				Prog:             prog,
+				Mains:            mains,
+				Cfg:              pcfg,
+				Pointer:          pt,
+				CallDAG:          graph.FromCallGraph(pt.CallGraph, false).SCC([]*ssa.Function{entry}),
+				PrunedCallDAG:    callDAG,
+				CtrLocPriorities: u.GetCtrLocPriorities(pcfg.Functions(), callDAG),
+				WrittenFields:    u.ComputeWrittenFields(pt, callDAG),
+			}
+
+			run := analyzeBenchProgram(program, loadRes, entry, opts.TimeoutOr(60*time.Second))
+			summary = append(summary, truth.score(program, run))
+		}
+
+		summary.Log()
+
 	case task.IsChannelAliasingCheck():
 		fullPreanalysisPipeline(standardPTAnalysisQueries)
 		fmt.Printf("%d -- %s\n", u.ChAliasingInfo.MaxChanPtsToSetSize, u.ChAliasingInfo.Location)
@@ -636,13 +805,43 @@ func main() {
 			}
 		}
 		scc.Convolution().ToDotGraph(allComps, &graph.VisualizationConfig[int]{
+			Name: "scc-convolution",
 			NodeAttrs: func(node int) (string, dot.DotAttrs) {
 				return fmt.Sprint(node), dot.DotAttrs{"label": fmt.Sprint(scc.Components[node][0])}
 			},
 		}).ShowDot()
 		cg.ToDotGraph(allNodes, &graph.VisualizationConfig[*ssa.Function]{
+			Name:       "callgraph",
 			ClusterKey: func(node *ssa.Function) any { return scc.ComponentOf(node) },
 		}).ShowDot()
+	case task.IsAbstractInterpretation() && task.IsWholeProgramAnalysis() && len(mains) > 1:
+		// Analyse each main or test package as its own unit, sharing the
+		// loaded packages and SSA program between units.
+		units := mains
+		summary := make(unitSummary, 0, len(units))
+
+		// The outcome of every entry is collected in its metrics.
+		unitConfig := aiConfig
+		unitConfig.Metrics = true
+
+		for i, unit := range units {
+			fmt.Println()
+			log.Printf("Package %d of %d: %s", i+1, len(units), unit.Pkg.Path())
+
+			// The pre-analysis pipelines and local package detection
+			// are driven by the current set of main packages.
+			mains = []*ssa.Package{unit}
+			pkgutil.GetLocalPackages(mains, allPackages)
+
+			loadRes := wholeProgramPipeline(u.IncludeType{All: true})
+			Cs := ai.ConfigAI(unitConfig).Executable(loadRes)
+			summary = append(summary, analyzeUnit(unit, Cs, opts.TimeoutOr(120*time.Second)))
+		}
+
+		summary.Log()
+		if summary.ExceedsThreshold() {
+			exitCode = 1
+		}
 	case task.IsAbstractInterpretation():
 		ptQueries := u.IncludeType{All: true}
 		if !task.IsWholeProgramAnalysis() {
@@ -651,24 +850,12 @@ func main() {
 
 		results := make(map[*ssa.Function]*ai.Metrics)
 
-		ptaResult, prog_cfg := preanalysisPipeline(ptQueries)
-		cg := ptaResult.CallGraph
-		entries := []*ssa.Function{cg.Root.Func}
-		loadRes := tu.LoadResult{
-			Prog:    prog,
-			Mains:   mains,
-			Cfg:     prog_cfg,
-			Pointer: ptaResult,
-			CallDAG: graph.FromCallGraph(cg, false).SCC(entries),
-		}
-		loadRes.PrunedCallDAG = graph.FromCallGraph(cg, true).SCC(entries)
-		loadRes.CtrLocPriorities = u.GetCtrLocPriorities(prog_cfg.Functions(), loadRes.PrunedCallDAG)
-		loadRes.WrittenFields = u.ComputeWrittenFields(ptaResult, loadRes.PrunedCallDAG)
+		loadRes := wholeProgramPipeline(ptQueries)
 
 		// Analysis context
 		Cs := ai.ConfigAI(aiConfig).Executable(loadRes)
 
-		timeout := 120000 * time.Millisecond
+		timeout := opts.TimeoutOr(120000 * time.Millisecond)
 
 		for f, C := range Cs {
 			if !C.Metrics.Enabled() {
@@ -688,14 +875,32 @@ func main() {
 				// if G.Size() > 3 {
 				// }
 				// Log all the found blocking bugs.
-				blocks = ai.BlockAnalysis(C, G, A)
+				blocks = suppress(ai.BlockAnalysis(C, G, A))
 				blocks.ForEach(func(sl defs.Superloc, gs map[defs.Goro]struct{}) {
 					fmt.Printf("%s ↦ %s\n", sl, A.GetUnsafe(sl).Memory())
 				})
 				blocks.Log()
+				if len(blocks) > 0 && opts.PathFormat() != "text" {
+					printPathDiagrams(blocks, G, graph.FromCallGraph(loadRes.Pointer.CallGraph, true))
+				}
+				if leaks := ai.LockLeakAnalysis(C, G, A, blocks); len(leaks) > 0 {
+					leaks.Log()
+				}
+				if lbs := ai.LockedBlockingAnalysis(C, G, A); len(lbs) > 0 {
+					lbs.Log()
+				}
+				if livelocks := ai.LivelockAnalysis(G); len(livelocks) > 0 {
+					livelocks.Log()
+				}
+				if cws := ai.CondAnalysis(C, G, A); len(cws) > 0 {
+					cws.Log()
+				}
 				if opts.Visualize() {
 					G.Visualize(blocks)
 				}
+				if opts.HTMLPath() != "" {
+					writeHTMLReport(f.String(), G, A, blocks)
+				}
 
 				continue
 			}
@@ -745,7 +950,7 @@ func main() {
 					log.Println("Done")
 					fmt.Println()
 				}
-				blocks = ai.BlockAnalysis(C, G, result)
+				blocks = suppress(ai.BlockAnalysis(C, G, result))
 				// log.Println("Analysis result:\n", A)
 				if C.Metrics.IsRelevant() {
 					C.Metrics.SetBlocks(blocks)
@@ -756,6 +961,9 @@ func main() {
 						G.Visualize(blocks)
 					}
 				}
+				if opts.HTMLPath() != "" {
+					writeHTMLReport(f.String(), G, result, blocks)
+				}
 			}(f, C)
 
 			go func(f *ssa.Function, C ai.AnalysisCtxt) {
@@ -785,6 +993,30 @@ func main() {
 		}
 
 		GatherMetrics(loadRes, results)
+	case task.IsCheckRaces():
+		loadRes := wholeProgramPipeline(u.IncludeType{All: true})
+		C := ai.ConfigAI(aiConfig).WholeProgram(loadRes)
+
+		G, A := ai.StaticAnalysis(C)
+		log.Println("Superlocation graph size:", G.Size())
+
+		log.Println("Checking for data races...")
+		ai.RaceAnalysis(C, G, A).Log()
+	case task.IsExplore():
+		if opts.AnalyzeAllFuncs() {
+			log.Fatalln("The explore task requires a single entry function, use -fun <name>")
+		}
+
+		loadRes := wholeProgramPipeline(u.IncludeType{All: true})
+
+		var C ai.AnalysisCtxt
+		if opts.IsWholeProgramAnalysis() {
+			C = ai.ConfigAI(aiConfig).WholeProgram(loadRes)
+		} else {
+			C = ai.ConfigAI(aiConfig).FunctionByName(opts.Function(), true)(loadRes)
+		}
+
+		ai.Explore(C, os.Stdin, os.Stdout)
 	case task.IsPosition():
 		for _, pkg := range prog.AllPackages() {
 			for _, member := range pkg.Members {
@@ -795,132 +1027,133 @@ func main() {
 			}
 		}
 	}
-}
 
-func GatherMetrics(loadRes tu.LoadResult, results map[*ssa.Function]*ai.Metrics) {
-	if !opts.Metrics() || len(results) == 0 {
-		return
+	if task.IsAbstractInterpretation() || task.IsCollectPrimitives() {
+		suppressor.Log()
+		if path := opts.ReportPath(); path != "" {
+			if err := suppressor.WriteReport(path); err != nil {
+				log.Fatalln(err)
+			}
+			log.Println("Findings written to", path)
+		}
 	}
-	prog := loadRes.Prog
-	coveredConcOp := make(map[ssa.Instruction]struct{})
-	coveredChans := make(map[ssa.Instruction]struct{})
-	coveredGos := make(map[ssa.Instruction]struct{})
-
-	msg := "================ Results =====================\n\n"
 
-	for f, r := range results {
-		msg += "Function: " + f.String() + "\n"
-		msg += "Outcome: " + r.Outcome + "\n"
+	os.Exit(exitCode)
+}
 
-		if r.Outcome == ai.OUTCOME_SKIP {
-			msg += "Function finished\n\n"
-			continue
-		}
-		if r.Outcome == ai.OUTCOME_PANIC {
-			msg += r.Error() + "\nFunction finished\n\n"
-			continue
-		}
+// printPathDiagrams prints a sequence diagram of the path to every blocked
+// superlocation, in the format given with -path-format.
+func printPathDiagrams(blocks ai.Blocks, G ai.SuperlocGraph, cg graph.Graph[*ssa.Function]) {
+	diagrams, err := blocks.PathDiagrams(G, cg, opts.PathFormat())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(diagrams)
+}
 
-		msg += "Time: " + r.Performance() + "\n\n"
+// writeHTMLReport writes an interactive report of the superlocation graph to
+// the file given with -html. When several graphs are analysed in one run,
+// the reports after the first one get a numbered suffix.
+func writeHTMLReport(title string, G ai.SuperlocGraph, A L.Analysis, blocks ai.Blocks) {
+	path := opts.HTMLPath()
+	if n := atomic.AddInt32(&htmlReports, 1); n > 1 {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+	}
 
-		files := make(map[string]struct{})
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println("Unable to write HTML report:", err)
+		return
+	}
+	defer f.Close()
 
-		if len(r.Functions()) > 0 {
-			msg += "Expanded functions: " + fmt.Sprintf("%d", len(r.Functions())) + " {\n"
-			for f, times := range r.Functions() {
-				fn := prog.Fset.Position(f.Pos()).Filename
-				if _, ok := files[fn]; !ok {
-					files[prog.Fset.Position(f.Pos()).Filename] = struct{}{}
-				}
+	if err := G.WriteHTML(f, title, A, blocks); err != nil {
+		log.Println("Unable to write HTML report:", err)
+		return
+	}
+	log.Println("Superlocation graph of", title, "written to", path)
+}
 
-				msg += "  " + f.String() + " -- " + fmt.Sprintf("%d", times) + "\n"
-			}
-			msg += "}\n"
-		}
+// suppress removes blocked goroutines covered by //goat:ignore directives,
+// suppressions in the project configuration file, or the baseline.
+func suppress(blocks ai.Blocks) ai.Blocks {
+	return suppressor.Filter(blocks)
+}
 
-		if len(r.Blocks()) > 0 {
-			msg += "Blocks:"
-			msg += r.Blocks().String()
-			msg += "\n"
-		}
+func GatherMetrics(loadRes tu.LoadResult, results map[*ssa.Function]*ai.Metrics) {
+	if !opts.Metrics() || len(results) == 0 {
+		return
+	}
 
-		fs := make([]string, 0, len(files))
-		for f := range files {
-			fs = append(fs, f)
-		}
-		if len(fs) > 0 {
-			cloc := exec.Command("cloc", fs...)
-			out, err := cloc.Output()
-			if err == nil {
-				msg += string(out) + "\n"
-			}
-		}
+	report := ai.NewMetricsReport(loadRes, results)
+	fmt.Println(report)
 
-		for i := range r.ConcurrencyOps() {
-			coveredConcOp[i] = struct{}{}
-		}
-		for i := range r.Gos() {
-			coveredGos[i] = struct{}{}
+	if path := opts.MetricsPath(); path != "" {
+		if err := report.Write(path); err != nil {
+			log.Fatalln(err)
 		}
-		for i := range r.Chans() {
-			coveredChans[i] = struct{}{}
-		}
-		msg += "Function finished\n\n"
+		log.Println("Metrics written to", path)
 	}
+}
 
-	allConcOps := loadRes.Cfg.GetAllConcurrencyOps()
-	msg += "Concurrency operations covered: " + fmt.Sprint(len(coveredConcOp)) + "/" + fmt.Sprint(len(allConcOps)) + " {\n"
-	if len(allConcOps) > 0 {
-		notCovered := make(map[ssa.Instruction]struct{})
-		for op := range allConcOps {
-			if _, ok := coveredConcOp[op]; !ok {
-				notCovered[op] = struct{}{}
-			}
-		}
-		if len(notCovered) > 0 {
-			msg += "Not covered: {\n"
-			for op := range notCovered {
-				msg += "  " + op.String() + ":" + prog.Fset.Position(op.Pos()).String() + "\n"
-			}
-			msg += "}\n"
-		}
+// entryPSets computes the primitive sets for the primitives reachable from
+// the entry with the strategy given by -psets. Channels that flow into the
+// reflection library are pruned from the primitive sets.
+func entryPSets(
+	prog *ssa.Program,
+	pcfg *cfg.Cfg,
+	pt *pointer.Result,
+	G graph.Graph[*ssa.Function],
+	entry *ssa.Function,
+	ps gotopo.Primitives,
+	computeDominator func(...*ssa.Function) *ssa.Function,
+	callDAG graph.SCCDecomposition[*ssa.Function],
+	userPsets gotopo.PSets,
+) gotopo.PSets {
+	var psets gotopo.PSets
+	switch {
+	case opts.PSets().SameFunc():
+		psets = gotopo.GetSameFuncPsets(ps)
+	case opts.PSets().GCatch():
+		psets = gotopo.GetGCatchPSets(
+			pcfg, entry, pt, G,
+			computeDominator, callDAG, ps) // GCatch Psets
+	case opts.PSets().IntraDependent():
+		psets = gotopo.GetIntraDependentPSets(
+			pcfg, entry, pt, G, ps) // Intra-procedurally dependent channels
+	case opts.PSets().Total():
+		psets = gotopo.GetTotalPset(ps) // Singular whole program p-set
+	case opts.PSets().User():
+		psets = gotopo.RestrictPSets(userPsets, ps) // User-defined p-sets
+	default:
+		psets = gotopo.GetSingletonPsets(ps) // Singleton sets
 	}
 
-	allChans := loadRes.Cfg.GetAllChans()
-	msg += "Channel sites covered: " + fmt.Sprint(len(coveredChans)) + "/" + fmt.Sprint(len(allChans)) + "\n"
-	if len(allChans) > 0 {
-		notCovered := make(map[ssa.Instruction]struct{})
-		for ch := range allChans {
-			if _, ok := coveredChans[ch]; !ok {
-				notCovered[ch] = struct{}{}
-			}
-		}
-		if len(notCovered) > 0 {
-			msg += "Not covered: {\n"
-			for ch := range notCovered {
-				msg += "  " + ch.String() + ":" + prog.Fset.Position(ch.Pos()).String() + "\n"
-			}
-			msg += "}\n"
-		}
-	}
+	// Remove channels from PSets where the channel flows into the reflection library
+	if reflectedChans := chreflect.GetReflectedChannels(prog, pt); !reflectedChans.Empty() {
+		log.Printf("%s:\n%v",
+			color.YellowString("Pruning channels from PSets that flow into the reflection library"),
+			reflectedChans)
 
-	allGos := loadRes.Cfg.GetAllGos()
-	msg += "Goroutine sites covered: " + fmt.Sprint(len(coveredGos)) + "/" + fmt.Sprint(len(allGos)) + "\n"
-	if len(allGos) > 0 {
-		notCovered := make(map[ssa.Instruction]struct{})
-		for g := range allGos {
-			if _, ok := coveredGos[g]; !ok {
-				notCovered[g] = struct{}{}
-			}
-		}
-		if len(notCovered) > 0 {
-			msg += "Not covered: {\n"
-			for g := range notCovered {
-				msg += "  " + g.String() + ":" + prog.Fset.Position(g.Pos()).String() + "\n"
+		// Since pruning may introduce duplicate PSets, we use hashing
+		// to ensure they are not present in the output.
+		newPsets := make(gotopo.PSets, 0, len(psets))
+		seen := hmap.NewMap[bool](utils.SSAValueSetHasher)
+
+		for _, pset := range psets {
+			reflectedChans.ForEach(func(rCh ssa.Value) {
+				pset.Map = pset.Delete(rCh)
+			})
+
+			if !pset.Empty() && !seen.Get(pset) {
+				seen.Set(pset, true)
+				newPsets = append(newPsets, pset)
 			}
-			msg += "}\n"
 		}
+
+		psets = newPsets
 	}
-	msg += "================ Results ====================="
-	fmt.Println(msg)
+
+	return psets
 }
@@ -0,0 +1,341 @@
+package pkgutil
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Name of the directory containing the generated harness, relative to the
+// directory of the first harnessed package.
+const harnessDir = "goat-harness"
+
+// Harness is a generated main package exercising the API of a library
+// concurrently. It is added to the loaded program as an overlay, and is
+// never written to disk.
+type Harness struct {
+	// Path of the generated file.
+	File string
+	// Import path of the generated main package.
+	PkgPath string
+	Source  []byte
+}
+
+// harnessGen accumulates the generated source code.
+type harnessGen struct {
+	// Import aliases by package.
+	imports map[*types.Package]string
+	// Argument functions by type.
+	args     map[string]string
+	argTypes []string
+	body     bytes.Buffer
+	// Number of spawned goroutines.
+	goros int
+}
+
+// GenerateHarness synthesizes an entry point for a library. Targets are
+// qualified names of types or functions, e.g. example.com/queue.Queue.
+//
+// For every type, the harness constructs a value with a constructor
+// (New<Type> or New, if the package has one), or as a zero value, and
+// calls every exported method of the value from the given number of
+// goroutines. Every function is similarly called from the given number of
+// goroutines. Arguments are unknown values, obtained from calls to
+// functions without a body. The main function returns once every spawned
+// goroutine has finished its call.
+func GenerateHarness(pkgs []*packages.Package, targets []string, goros int) (h Harness, err error) {
+	if goros < 1 {
+		return h, fmt.Errorf("the harness must use at least 1 goroutine, got %d", goros)
+	}
+
+	byPath := make(map[string]*packages.Package)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		byPath[pkg.PkgPath] = pkg
+	})
+
+	gen := &harnessGen{
+		imports: make(map[*types.Package]string),
+		args:    make(map[string]string),
+	}
+
+	for i, target := range targets {
+		dot := strings.LastIndex(target, ".")
+		if dot <= 0 {
+			return h, fmt.Errorf("%s is not a qualified name, e.g. example.com/queue.Queue", target)
+		}
+
+		pkgPath, name := target[:dot], target[dot+1:]
+		pkg, found := byPath[pkgPath]
+		if !found || pkg.Types == nil {
+			return h, fmt.Errorf("package %s of %s is not loaded", pkgPath, target)
+		}
+
+		if h.File == "" {
+			if len(pkg.GoFiles) == 0 {
+				return h, fmt.Errorf("package %s has no source files", pkgPath)
+			}
+			h.File = filepath.Join(filepath.Dir(pkg.GoFiles[0]), harnessDir, "main.go")
+			h.PkgPath = pkgPath + "/" + harnessDir
+		}
+
+		switch obj := pkg.Types.Scope().Lookup(name).(type) {
+		case *types.TypeName:
+			err = gen.harnessType(pkg.Types, obj, fmt.Sprintf("x%d", i), goros)
+		case *types.Func:
+			err = gen.harnessFunc(obj, goros)
+		default:
+			err = fmt.Errorf("%s is not a type or function", target)
+		}
+
+		if err != nil {
+			return h, err
+		}
+	}
+
+	h.Source, err = gen.source()
+	return
+}
+
+// qualifier imports packages referenced in generated code.
+func (gen *harnessGen) qualifier(pkg *types.Package) string {
+	if alias, found := gen.imports[pkg]; found {
+		return alias
+	}
+	alias := fmt.Sprintf("p%d", len(gen.imports))
+	gen.imports[pkg] = alias
+	return alias
+}
+
+// nameable returns true if the type can be written outside of its package.
+func nameable(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Basic:
+		return t.Kind() != types.UnsafePointer
+	case *types.Named:
+		if t.Obj().Pkg() != nil && !t.Obj().Exported() {
+			return false
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if !nameable(t.TypeArgs().At(i)) {
+				return false
+			}
+		}
+		return true
+	case *types.Pointer:
+		return nameable(t.Elem())
+	case *types.Slice:
+		return nameable(t.Elem())
+	case *types.Array:
+		return nameable(t.Elem())
+	case *types.Chan:
+		return nameable(t.Elem())
+	case *types.Map:
+		return nameable(t.Key()) && nameable(t.Elem())
+	case *types.Signature:
+		return t.TypeParams().Len() == 0 && nameable(t.Params()) && nameable(t.Results())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if !nameable(t.At(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if !t.Field(i).Exported() || !nameable(t.Field(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			if !t.Method(i).Exported() || !nameable(t.Method(i).Type()) {
+				return false
+			}
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if !nameable(t.EmbeddedType(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// arg returns a call producing an unknown value of the given type.
+func (gen *harnessGen) arg(t types.Type) string {
+	typ := types.TypeString(t, gen.qualifier)
+	fun, found := gen.args[typ]
+	if !found {
+		fun = fmt.Sprintf("arg%d", len(gen.args))
+		gen.args[typ] = fun
+		gen.argTypes = append(gen.argTypes, typ)
+	}
+	return fun + "()"
+}
+
+// call returns a call of fun with unknown arguments, or false if the
+// arguments cannot be written in the harness.
+func (gen *harnessGen) call(fun string, sig *types.Signature) (string, bool) {
+	if !nameable(sig.Params()) {
+		return "", false
+	}
+
+	args := make([]string, 0, sig.Params().Len())
+	for i := 0; i < sig.Params().Len(); i++ {
+		args = append(args, gen.arg(sig.Params().At(i).Type()))
+	}
+
+	call := fun + "(" + strings.Join(args, ", ")
+	if sig.Variadic() {
+		call += "..."
+	}
+	return call + ")", true
+}
+
+// spawn calls the function from the given number of goroutines, which
+// signal on the done channel of main when the call returns.
+func (gen *harnessGen) spawn(call string, goros int) {
+	for i := 0; i < goros; i++ {
+		fmt.Fprintf(&gen.body, "\tgo func() {\n\t\t%s\n\t\tdone <- struct{}{}\n\t}()\n", call)
+	}
+	gen.goros += goros
+}
+
+func (gen *harnessGen) harnessFunc(fun *types.Func, goros int) error {
+	sig := fun.Type().(*types.Signature)
+	switch {
+	case !fun.Exported():
+		return fmt.Errorf("%s is not exported", fun.FullName())
+	case sig.TypeParams().Len() > 0:
+		return fmt.Errorf("%s is generic, which is not supported", fun.FullName())
+	}
+
+	call, ok := gen.call(gen.qualifier(fun.Pkg())+"."+fun.Name(), sig)
+	if !ok {
+		return fmt.Errorf("the arguments of %s cannot be constructed outside of its package", fun.FullName())
+	}
+
+	fmt.Fprintf(&gen.body, "\t// %s\n", fun.FullName())
+	gen.spawn(call, goros)
+	return nil
+}
+
+// constructor finds an exported function in the package of the type, named
+// New<Type>, or New, whose first result is the type or a pointer to it.
+func constructor(pkg *types.Package, obj *types.TypeName) (*types.Func, bool) {
+	for _, name := range []string{"New" + obj.Name(), "New"} {
+		fun, ok := pkg.Scope().Lookup(name).(*types.Func)
+		if !ok {
+			continue
+		}
+
+		sig := fun.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 || sig.Results().Len() == 0 {
+			continue
+		}
+
+		res := sig.Results().At(0).Type()
+		if ptr, ok := res.(*types.Pointer); ok {
+			res = ptr.Elem()
+		}
+		if types.Identical(res, obj.Type()) {
+			return fun, true
+		}
+	}
+	return nil, false
+}
+
+func (gen *harnessGen) harnessType(pkg *types.Package, obj *types.TypeName, x string, goros int) error {
+	named, ok := obj.Type().(*types.Named)
+	switch {
+	case !obj.Exported():
+		return fmt.Errorf("%s.%s is not exported", pkg.Path(), obj.Name())
+	case !ok || obj.IsAlias():
+		return fmt.Errorf("%s.%s is not a defined type", pkg.Path(), obj.Name())
+	case named.TypeParams().Len() > 0:
+		return fmt.Errorf("%s.%s is generic, which is not supported", pkg.Path(), obj.Name())
+	}
+
+	fmt.Fprintf(&gen.body, "\t// %s\n", types.TypeString(named, nil))
+
+	if fun, found := constructor(pkg, obj); found {
+		sig := fun.Type().(*types.Signature)
+		call, ok := gen.call(gen.qualifier(pkg)+"."+fun.Name(), sig)
+		if !ok {
+			return fmt.Errorf("the arguments of %s cannot be constructed outside of its package", fun.FullName())
+		}
+
+		lhs := []string{x}
+		for i := 1; i < sig.Results().Len(); i++ {
+			lhs = append(lhs, "_")
+		}
+		fmt.Fprintf(&gen.body, "\t%s := %s\n", strings.Join(lhs, ", "), call)
+	} else if _, isInterface := named.Underlying().(*types.Interface); isInterface {
+		return fmt.Errorf("%s.%s is an interface without a constructor", pkg.Path(), obj.Name())
+	} else {
+		fmt.Fprintf(&gen.body, "\t%s := new(%s)\n", x, types.TypeString(named, gen.qualifier))
+	}
+
+	// Methods of both T and *T are callable, since x is addressable or a pointer.
+	mset := types.NewMethodSet(types.NewPointer(named))
+	for i := 0; i < mset.Len(); i++ {
+		method := mset.At(i).Obj().(*types.Func)
+		if !method.Exported() {
+			continue
+		}
+
+		call, ok := gen.call(x+"."+method.Name(), method.Type().(*types.Signature))
+		if !ok {
+			fmt.Fprintf(&gen.body, "\t// Skipped %s: arguments cannot be constructed\n", method.Name())
+			continue
+		}
+		gen.spawn(call, goros)
+	}
+
+	return nil
+}
+
+func (gen *harnessGen) source() ([]byte, error) {
+	var src bytes.Buffer
+	src.WriteString("// Code generated by goat -harness. DO NOT EDIT.\n\npackage main\n\n")
+
+	if len(gen.imports) > 0 {
+		imports := make([]string, 0, len(gen.imports))
+		for pkg, alias := range gen.imports {
+			imports = append(imports, fmt.Sprintf("\t%s %q\n", alias, pkg.Path()))
+		}
+		sort.Strings(imports)
+		src.WriteString("import (\n" + strings.Join(imports, "") + ")\n\n")
+	}
+
+	src.WriteString("func main() {\n")
+	if gen.goros > 0 {
+		src.WriteString("\tdone := make(chan struct{})\n")
+	}
+	src.Write(gen.body.Bytes())
+	if gen.goros > 0 {
+		// The receives are not written as a loop, which the analysis
+		// could not bound by the number of goroutines.
+		src.WriteString("\n\t// Wait for every goroutine to finish.\n")
+		src.WriteString(strings.Repeat("\t<-done\n", gen.goros))
+	}
+	src.WriteString("}\n")
+
+	if len(gen.argTypes) > 0 {
+		src.WriteString("\n// Functions without a body produce unknown values.\n")
+	}
+	for _, typ := range gen.argTypes {
+		fmt.Fprintf(&src, "func %s() %s\n", gen.args[typ], typ)
+	}
+
+	return format.Source(src.Bytes())
+}
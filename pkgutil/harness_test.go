@@ -0,0 +1,134 @@
+package pkgutil
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const harnessLibSrc = `
+package queue
+
+import "sync"
+
+type Queue struct {
+	mu sync.Mutex
+	ch chan int
+}
+
+func NewQueue(size int) (*Queue, error) {
+	return &Queue{ch: make(chan int, size)}, nil
+}
+
+func (q *Queue) Push(v int)          { q.ch <- v }
+func (q *Queue) Pop() int            { return <-q.ch }
+func (q *Queue) PushAll(vs ...int)   {}
+func (q *Queue) internal()           {}
+func (q *Queue) Opaque(h hidden)     {}
+
+type hidden struct{}
+
+type Counter struct{ n int }
+
+func (c *Counter) Inc() { c.n++ }
+
+func Drain(ch <-chan int) {}
+`
+
+// harnessLib type checks a library package without loading it.
+func harnessLib(t *testing.T) *packages.Package {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "/lib/queue/queue.go", harnessLibSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpkg, err := (&types.Config{Importer: importer.ForCompiler(fset, "source", nil)}).
+		Check("example.com/queue", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &packages.Package{
+		PkgPath: "example.com/queue",
+		GoFiles: []string{"/lib/queue/queue.go"},
+		Types:   tpkg,
+	}
+}
+
+type libImporter struct{ lib *types.Package }
+
+func (i libImporter) Import(path string) (*types.Package, error) {
+	if path == i.lib.Path() {
+		return i.lib, nil
+	}
+	return nil, fmt.Errorf("unexpected import %s", path)
+}
+
+func TestGenerateHarness(t *testing.T) {
+	lib := harnessLib(t)
+
+	h, err := GenerateHarness([]*packages.Package{lib},
+		[]string{"example.com/queue.Queue", "example.com/queue.Counter", "example.com/queue.Drain"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := string(h.Source)
+	t.Log(src)
+
+	if h.File != "/lib/queue/goat-harness/main.go" || h.PkgPath != "example.com/queue/goat-harness" {
+		t.Errorf("Unexpected harness location: %s (%s)", h.File, h.PkgPath)
+	}
+
+	for expected, count := range map[string]int{
+		"x0, _ := p0.NewQueue(arg0())": 1,
+		"x1 := new(p0.Counter)":        1,
+		"x0.Push(arg0())":              2,
+		"x0.Pop()":                     2,
+		"x0.PushAll(arg1()...)":        2,
+		"x1.Inc()":                     2,
+		"p0.Drain(arg2())":             2,
+		"internal":                     0,
+		"Skipped Opaque":               1,
+		"func arg2() <-chan int":       1,
+		"done <- struct{}{}":           10,
+		"\t<-done\n":                   10,
+	} {
+		if actual := strings.Count(src, expected); actual != count {
+			t.Errorf("Expected %q to occur %d times in the harness, found %d", expected, count, actual)
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, h.File, h.Source, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&types.Config{Importer: libImporter{lib.Types}}).
+		Check(h.PkgPath, fset, []*ast.File{file}, nil); err != nil {
+		t.Error("Harness does not type check:", err)
+	}
+}
+
+func TestGenerateHarnessErrors(t *testing.T) {
+	lib := harnessLib(t)
+
+	for _, target := range []string{
+		"Queue",
+		"example.com/other.Queue",
+		"example.com/queue.Missing",
+		"example.com/queue.hidden",
+	} {
+		if _, err := GenerateHarness([]*packages.Package{lib}, []string{target}, 2); err == nil {
+			t.Errorf("Expected an error for %s", target)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pkgutil
+
+import (
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const ignoreDirective = "//goat:ignore"
+
+// IgnoreDirective is a `//goat:ignore <reason>` comment in the source code.
+type IgnoreDirective struct {
+	Pos    token.Position
+	Reason string
+}
+
+// IgnoreDirectives maps file names and lines to the directives covering them.
+type IgnoreDirectives map[string]map[int]*IgnoreDirective
+
+// CollectIgnoreDirectives finds all `//goat:ignore <reason>` comments in the
+// syntax trees of the given packages and their dependencies.
+// A directive covers the line it is written on, and the following line,
+// such that it may either trail an operation or precede it.
+func CollectIgnoreDirectives(pkgs []*packages.Package) IgnoreDirectives {
+	res := make(IgnoreDirectives)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.Syntax {
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					if !strings.HasPrefix(comment.Text, ignoreDirective) {
+						continue
+					}
+
+					reason := strings.TrimPrefix(comment.Text, ignoreDirective)
+					if reason != "" && !strings.HasPrefix(reason, " ") {
+						// Some other directive, e.g. //goat:ignoreme
+						continue
+					}
+
+					pos := pkg.Fset.Position(comment.Pos())
+					directive := &IgnoreDirective{pos, strings.TrimSpace(reason)}
+					if _, found := res[pos.Filename]; !found {
+						res[pos.Filename] = make(map[int]*IgnoreDirective)
+					}
+					for _, line := range []int{pos.Line, pos.Line + 1} {
+						if _, found := res[pos.Filename][line]; !found {
+							res[pos.Filename][line] = directive
+						}
+					}
+				}
+			}
+		}
+	})
+	return res
+}
+
+// Lookup returns the directive covering the given position, if any.
+func (ds IgnoreDirectives) Lookup(pos token.Position) (*IgnoreDirective, bool) {
+	d, found := ds[pos.Filename][pos.Line]
+	return d, found
+}
+
+// ForEach calls do once for every directive.
+func (ds IgnoreDirectives) ForEach(do func(*IgnoreDirective)) {
+	seen := make(map[*IgnoreDirective]bool)
+	for _, lines := range ds {
+		for _, d := range lines {
+			if !seen[d] {
+				seen[d] = true
+				do(d)
+			}
+		}
+	}
+}
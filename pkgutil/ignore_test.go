@@ -0,0 +1,46 @@
+package pkgutil_test
+
+import (
+	"go/token"
+	"testing"
+
+	p "github.com/cs-au-dk/goat/pkgutil"
+)
+
+func TestCollectIgnoreDirectives(t *testing.T) {
+	pkgs, err := p.LoadPackagesFromSource(`package main
+
+func main() {
+	ch := make(chan int)
+	//goat:ignore intentionally leaked
+	go func() {
+		ch <- 1
+	}()
+	go func() {
+		ch <- 2 //goat:ignore also leaked
+	}()
+	//goat:ignoreme is not a directive
+	<-ch
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directives := p.CollectIgnoreDirectives(pkgs)
+
+	reasons := map[int]string{}
+	directives.ForEach(func(d *p.IgnoreDirective) {
+		reasons[d.Pos.Line] = d.Reason
+	})
+
+	if len(reasons) != 2 || reasons[5] != "intentionally leaked" || reasons[10] != "also leaked" {
+		t.Errorf("Unexpected directives: %v", reasons)
+	}
+
+	file := pkgs[0].Fset.Position(pkgs[0].Syntax[0].Pos()).Filename
+	for line, expected := range map[int]bool{5: true, 6: true, 7: false, 10: true, 11: true, 13: false} {
+		if _, found := directives.Lookup(token.Position{Filename: file, Line: line}); found != expected {
+			t.Errorf("Expected line %d to be covered: %v", line, expected)
+		}
+	}
+}
@@ -1,60 +1,148 @@
 package pkgutil
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strings"
 
+	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/packages"
 )
 
 type LoadConfig struct {
 	GoPath, ModulePath string
-	IncludeTests       bool
+	// Path to a go.work file, or to a directory containing one. If provided,
+	// packages are loaded in workspace mode, and patterns are resolved
+	// relative to the workspace root.
+	WorkPath     string
+	IncludeTests bool
+	// Build tags used when selecting files, e.g. "integration".
+	Tags []string
+	// Target platform. The host platform is used if left empty.
+	GOOS, GOARCH string
+	// Contents of files that replace or are added to the files on disk.
+	Overlay map[string][]byte
 }
 
-var moduleRegex = regexp.MustCompile(`(?m)^module\s+(.*)$`)
+// PackageError records the errors encountered while loading a single package.
+type PackageError struct {
+	PkgPath string
+	Errors  []packages.Error
+}
+
+// LoadErrors is returned when one or more packages could not be loaded
+// without errors. The successfully loaded packages are still returned.
+type LoadErrors []PackageError
+
+func (e PackageError) String() string {
+	str := e.PkgPath + ":"
+	for _, err := range e.Errors {
+		str += "\n  " + err.Error()
+	}
+	return str
+}
+
+func (es LoadErrors) Error() string {
+	strs := make([]string, 0, len(es))
+	for _, e := range es {
+		strs = append(strs, e.String())
+	}
+	return fmt.Sprintf("errors encountered while loading %d package(s):\n%s",
+		len(es), strings.Join(strs, "\n"))
+}
 
-// Load the AST for the packages matching the specified package name according
-// to the provided LoadConfig.
-func LoadPackages(cfg LoadConfig, packageName string) ([]*packages.Package, error) {
+// Load the AST for the packages matching the specified patterns according
+// to the provided LoadConfig. Patterns are given in the same format as for
+// the go command, e.g. "./..." or "example.com/pkg/...".
+//
+// If some packages fail to load, all packages are returned together with a
+// LoadErrors value describing the errors of each package.
+func LoadPackages(cfg LoadConfig, patterns ...string) ([]*packages.Package, error) {
 	gopath, err := filepath.Abs(cfg.GoPath)
 	if err != nil {
 		return nil, err
 	}
 
 	config := &packages.Config{
-		Mode:  packages.LoadAllSyntax,
-		Tests: cfg.IncludeTests,
+		Mode:    packages.LoadAllSyntax,
+		Tests:   cfg.IncludeTests,
+		Env:     append(os.Environ(), "GOPATH="+gopath),
+		Overlay: cfg.Overlay,
 	}
 
-	if modulePath := cfg.ModulePath; modulePath != "" {
+	switch {
+	case cfg.WorkPath != "":
+		// Load packages according to the modules listed in a workspace (go.work) file.
+		workFile, err := findWorkFile(cfg.WorkPath)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Dir = filepath.Dir(workFile)
+		config.Env = append(config.Env, "GO111MODULE=on", "GOWORK="+workFile)
+	case cfg.ModulePath != "":
 		// Load packages according to the new "module-aware" mode (GO111MODULE=on).
-		pkgPath, err := filepath.Abs(modulePath)
+		// The go command will pick up a go.work file in an enclosing directory.
+		pkgPath, err := filepath.Abs(cfg.ModulePath)
 		if err != nil {
 			return nil, err
 		}
 
 		contents, err := os.ReadFile(filepath.Join(pkgPath, "go.mod"))
 		if err != nil {
-			return nil, fmt.Errorf("Unable to load 'go.mod' file at %s.\n%w", modulePath, err)
+			return nil, fmt.Errorf("Unable to load 'go.mod' file at %s.\n%w", cfg.ModulePath, err)
 		}
 
-		m := moduleRegex.FindSubmatch(contents)
-		if len(m) <= 1 {
+		if modfile.ModulePath(contents) == "" {
 			return nil, fmt.Errorf("Unable to locate module name in 'go.mod' file")
 		}
 
 		config.Dir = pkgPath
-		config.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=on")
-	} else {
+		config.Env = append(config.Env, "GO111MODULE=on")
+	default:
 		// Load packages according to the legacy "module-unaware" mode (GO111MODULE=off).
-		config.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=off")
+		config.Env = append(config.Env, "GO111MODULE=off")
+	}
+
+	if cfg.GOOS != "" {
+		config.Env = append(config.Env, "GOOS="+cfg.GOOS)
+	}
+	if cfg.GOARCH != "" {
+		config.Env = append(config.Env, "GOARCH="+cfg.GOARCH)
+	}
+	if len(cfg.Tags) > 0 {
+		config.BuildFlags = append(config.BuildFlags, "-tags="+strings.Join(cfg.Tags, ","))
+	}
+
+	return LoadPackagesWithConfig(config, patterns...)
+}
+
+// findWorkFile resolves the path to a go.work file, and checks that it
+// can be parsed.
+func findWorkFile(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if fi, err := os.Stat(path); err != nil {
+		return "", err
+	} else if fi.IsDir() {
+		path = filepath.Join(path, "go.work")
 	}
 
-	return LoadPackagesWithConfig(config, packageName)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Unable to load 'go.work' file at %s.\n%w", path, err)
+	}
+
+	if _, err := modfile.ParseWork(path, contents, nil); err != nil {
+		return "", fmt.Errorf("Unable to parse 'go.work' file at %s.\n%w", path, err)
+	}
+
+	return path, nil
 }
 
 // Mainly useful for testing
@@ -73,13 +161,12 @@ func LoadPackagesFromSource(source string) ([]*packages.Package, error) {
 	return LoadPackagesWithConfig(config, "/fake/testpackage/main.go")
 }
 
-func LoadPackagesWithConfig(config *packages.Config, query string) ([]*packages.Package, error) {
-	pkgs, err := packages.Load(config, query)
+func LoadPackagesWithConfig(config *packages.Config, queries ...string) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(config, queries...)
 	if err != nil {
 		return nil, err
-	} else if packages.PrintErrors(pkgs) > 0 {
-		return nil, errors.New("errors encountered while loading packages")
 	}
+
 	if config.Tests {
 		// Deduplicate packages that have test functions (such packages are
 		// returned twice, once with no tests and once with tests. We discard
@@ -99,5 +186,59 @@ func LoadPackagesWithConfig(config *packages.Config, query string) ([]*packages.
 		}
 		pkgs = filteredPkgs
 	}
+
+	if errs := collectErrors(pkgs); len(errs) > 0 {
+		return pkgs, errs
+	}
 	return pkgs, nil
 }
+
+// collectErrors gathers the errors of the given packages and their
+// dependencies, grouped by package.
+func collectErrors(pkgs []*packages.Package) (res LoadErrors) {
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.Errors) > 0 {
+			res = append(res, PackageError{pkg.ID, pkg.Errors})
+		}
+	})
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].PkgPath < res[j].PkgPath
+	})
+	return
+}
+
+// WellTyped returns the packages that, including their dependencies,
+// were loaded without errors.
+func WellTyped(pkgs []*packages.Package) (res []*packages.Package) {
+	for _, pkg := range pkgs {
+		if !pkg.IllTyped {
+			res = append(res, pkg)
+		}
+	}
+	return
+}
+
+// MatchesPattern returns true if the package path matches the pattern.
+// As for the go command, a pattern ending in "/..." matches the package
+// and all packages below it.
+func MatchesPattern(pkgPath, pattern string) bool {
+	if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+		return pkgPath == prefix || strings.HasPrefix(pkgPath, prefix+"/")
+	}
+	return pkgPath == pattern
+}
+
+// Exclude removes the packages matching any of the patterns.
+func Exclude(pkgs []*packages.Package, patterns []string) (res []*packages.Package) {
+PACKAGES:
+	for _, pkg := range pkgs {
+		for _, pattern := range patterns {
+			if MatchesPattern(pkg.PkgPath, pattern) {
+				continue PACKAGES
+			}
+		}
+		res = append(res, pkg)
+	}
+	return
+}
@@ -32,6 +32,59 @@ func TestLoadFromGoPath(t *testing.T) {
 	}
 }
 
+func TestLoadWithWorkspace(t *testing.T) {
+	if pkgs, err := p.LoadPackages(p.LoadConfig{
+		GoPath:   "../examples",
+		WorkPath: "../examples/src/pkg-with-workspace",
+	}, "./app/..."); err != nil {
+		t.Fatal(err)
+	} else if len(pkgs) != 1 {
+		t.Errorf("Expected load result to contain 1 package, got: %s", pkgs)
+	} else if _, found := pkgs[0].Imports["example.com/lib"]; !found {
+		t.Errorf("Expected %s to import the workspace module example.com/lib", pkgs[0])
+	}
+}
+
+func TestLoadWithTagsAndPlatform(t *testing.T) {
+	countFiles := func(cfg p.LoadConfig) int {
+		cfg.GoPath = "../examples"
+		pkgs, err := p.LoadPackages(cfg, "pkg-with-tags")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(pkgs) != 1 {
+			t.Fatalf("Expected load result to contain 1 package, got: %s", pkgs)
+		}
+		return len(pkgs[0].Syntax)
+	}
+
+	if n := countFiles(p.LoadConfig{GOOS: "windows", GOARCH: "amd64"}); n != 1 {
+		t.Errorf("Expected 1 file without tags on windows/amd64, got %d", n)
+	}
+	if n := countFiles(p.LoadConfig{Tags: []string{"integration"}, GOOS: "windows", GOARCH: "amd64"}); n != 2 {
+		t.Errorf("Expected 2 files with the integration tag, got %d", n)
+	}
+	if n := countFiles(p.LoadConfig{Tags: []string{"integration"}, GOOS: "linux", GOARCH: "arm64"}); n != 3 {
+		t.Errorf("Expected 3 files with the integration tag on linux/arm64, got %d", n)
+	}
+}
+
+func TestLoadErrorsPerPackage(t *testing.T) {
+	pkgs, err := p.LoadPackages(p.LoadConfig{GoPath: "../examples"}, "pkg-with-load-error/...")
+	loadErrs, ok := err.(p.LoadErrors)
+	if !ok {
+		t.Fatalf("Expected load errors, got: %v", err)
+	} else if len(loadErrs) != 1 || loadErrs[0].PkgPath != "pkg-with-load-error/broken" {
+		t.Errorf("Expected errors only for pkg-with-load-error/broken, got: %v", loadErrs)
+	}
+
+	if len(pkgs) != 2 {
+		t.Errorf("Expected load result to contain 2 packages, got: %s", pkgs)
+	}
+	if wellTyped := p.WellTyped(pkgs); len(wellTyped) != 1 || wellTyped[0].PkgPath != "pkg-with-load-error/ok" {
+		t.Errorf("Expected only pkg-with-load-error/ok to be well-typed, got: %s", wellTyped)
+	}
+}
+
 func TestLoadTestBloat(t *testing.T) {
 	pkgs, err := p.LoadPackages(p.LoadConfig{
 		IncludeTests: true,
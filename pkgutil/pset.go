@@ -0,0 +1,122 @@
+package pkgutil
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const psetDirective = "//goat:pset"
+
+// PSetSite is the source location of a primitive allocation in a
+// user-defined PSet. Sites are given either with a `//goat:pset <name>`
+// comment, or in a PSet file.
+type PSetSite struct {
+	Pos  token.Position
+	Name string
+	// Comment is true if the site is given by a //goat:pset comment,
+	// in which case it also covers the following line.
+	Comment bool
+}
+
+func (s PSetSite) String() string {
+	return fmt.Sprintf("%s:%d (pset %s)", s.Pos.Filename, s.Pos.Line, s.Name)
+}
+
+// Covers returns true if the site covers the given position.
+// Files are matched by path suffix, such that sites in a PSet file
+// may be given relative to the project root. The suffix must consist
+// of whole path elements, i.e., a.go does not match data.go.
+func (s PSetSite) Covers(pos token.Position) bool {
+	file, site := filepath.ToSlash(pos.Filename), filepath.ToSlash(s.Pos.Filename)
+	if file != site && !strings.HasSuffix(file, "/"+site) {
+		return false
+	}
+	return pos.Line == s.Pos.Line || s.Comment && pos.Line == s.Pos.Line+1
+}
+
+// CollectPSetDirectives finds all `//goat:pset <name>` comments in the
+// syntax trees of the given packages and their dependencies.
+// Like //goat:ignore, a directive may either trail the allocation of a
+// primitive or precede it.
+func CollectPSetDirectives(pkgs []*packages.Package) (sites []PSetSite, err error) {
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, file := range pkg.Syntax {
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					if !strings.HasPrefix(comment.Text, psetDirective) {
+						continue
+					}
+
+					name := strings.TrimPrefix(comment.Text, psetDirective)
+					if name != "" && !strings.HasPrefix(name, " ") {
+						// Some other directive, e.g. //goat:psets
+						continue
+					}
+
+					pos := pkg.Fset.Position(comment.Pos())
+					if name = strings.TrimSpace(name); name == "" {
+						if err == nil {
+							err = fmt.Errorf("%s: %s requires a PSet name", pos, psetDirective)
+						}
+						continue
+					}
+
+					sites = append(sites, PSetSite{pos, name, true})
+				}
+			}
+		}
+	})
+	return
+}
+
+// ReadPSetFile reads a file of user-defined PSets. Every line is a
+// `<file>:<line> [<name>]` allocation site, where sites without a name
+// belong to a PSet named after the PSet file.
+// Empty lines and lines starting with # are ignored.
+func ReadPSetFile(path string) (sites []PSetSite, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: expected <file>:<line> [<name>]", path, lineNo)
+		}
+
+		idx := strings.LastIndex(fields[0], ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected <file>:<line>, got %q", path, lineNo, fields[0])
+		}
+		line, err := strconv.Atoi(fields[0][idx+1:])
+		if err != nil || line <= 0 {
+			return nil, fmt.Errorf("%s:%d: invalid line number in %q", path, lineNo, fields[0])
+		}
+
+		name := filepath.Base(path)
+		if len(fields) == 2 {
+			name = fields[1]
+		}
+
+		sites = append(sites, PSetSite{
+			Pos:  token.Position{Filename: fields[0][:idx], Line: line},
+			Name: name,
+		})
+	}
+
+	return sites, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package pkgutil_test
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	p "github.com/cs-au-dk/goat/pkgutil"
+)
+
+func TestCollectPSetDirectives(t *testing.T) {
+	pkgs, err := p.LoadPackagesFromSource(`package main
+
+import "sync"
+
+func main() {
+	//goat:pset shutdown
+	done := make(chan int)
+	var mu sync.Mutex //goat:pset shutdown
+	//goat:psets is not a directive
+	go func() {
+		mu.Lock()
+		done <- 1
+		mu.Unlock()
+	}()
+	<-done
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := p.CollectPSetDirectives(pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sites) != 2 || sites[0].Pos.Line != 6 || sites[1].Pos.Line != 8 {
+		t.Fatalf("Unexpected sites: %v", sites)
+	}
+
+	file := sites[0].Pos.Filename
+	for line, expected := range map[int]bool{6: true, 7: true, 8: false} {
+		if covered := sites[0].Covers(token.Position{Filename: file, Line: line}); covered != expected {
+			t.Errorf("Expected line %d to be covered: %v", line, expected)
+		}
+	}
+}
+
+func TestCollectPSetDirectivesRequiresName(t *testing.T) {
+	pkgs, err := p.LoadPackagesFromSource(`package main
+
+func main() {
+	ch := make(chan int) //goat:pset
+	close(ch)
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.CollectPSetDirectives(pkgs); err == nil {
+		t.Error("Expected an error for a directive without a name")
+	}
+}
+
+func TestReadPSetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "psets.txt")
+	contents := "# Channels of the worker pool\n" +
+		"pool/pool.go:12 pool\n" +
+		"\n" +
+		"pool/pool.go:15\n"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := p.ReadPSetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sites) != 2 ||
+		sites[0].Name != "pool" || sites[0].Pos.Line != 12 ||
+		sites[1].Name != "psets.txt" || sites[1].Pos.Line != 15 {
+		t.Fatalf("Unexpected sites: %v", sites)
+	}
+
+	pos := token.Position{Filename: "/home/user/project/pool/pool.go", Line: 12}
+	if !sites[0].Covers(pos) {
+		t.Errorf("Expected %v to cover %v", sites[0], pos)
+	}
+	if pos.Line++; sites[0].Covers(pos) {
+		t.Errorf("Expected %v not to cover %v", sites[0], pos)
+	}
+	for _, filename := range []string{"/home/user/project/mypool/pool.go", "/home/user/project/pool/subpool.go"} {
+		if pos := (token.Position{Filename: filename, Line: 12}); sites[0].Covers(pos) {
+			t.Errorf("Expected %v not to cover %v", sites[0], pos)
+		}
+	}
+
+	for _, invalid := range []string{"pool.go\n", "pool.go:x\n", "pool.go:12 a b\n"} {
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.ReadPSetFile(path); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ai "github.com/cs-au-dk/goat/analysis/absint"
+
+	"github.com/fatih/color"
+	"golang.org/x/tools/go/ssa"
+)
+
+// unitResult summarizes the analysis of a single main or test package.
+type unitResult struct {
+	pkg string
+	// Number of blocking bugs reported across all entries of the package,
+	// and of goroutines reported to leak when the program exits.
+	blocks, leaks int
+	// Number of analysed entries of the package by outcome.
+	completes, skips, aborts int
+}
+
+type unitSummary []unitResult
+
+func (r unitResult) outcome() string {
+	switch {
+	case r.completes+r.skips+r.aborts == 0:
+		return "No entries"
+	case r.blocks > 0:
+		return ai.OUTCOME_BUGS_FOUND
+	case r.aborts > 0:
+		return ai.OUTCOME_PANIC
+	case r.skips > 0:
+		return ai.OUTCOME_SKIP
+	default:
+		return ai.OUTCOME_NO_BUGS_FOUND
+	}
+}
+
+// Findings returns the total number of blocking bugs across all packages.
+// Goroutines leaked at program exit are not counted.
+func (s unitSummary) Findings() (res int) {
+	for _, r := range s {
+		res += r.blocks
+	}
+	return
+}
+
+func (s unitSummary) String() string {
+	sb := &strings.Builder{}
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Package\tOutcome\tBlocks\tLeaks\tCompleted\tSkipped\tAborted")
+	total := unitResult{pkg: "Total"}
+	for _, r := range s {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			r.pkg, r.outcome(), r.blocks, r.leaks, r.completes, r.skips, r.aborts)
+
+		total.blocks += r.blocks
+		total.leaks += r.leaks
+		total.completes += r.completes
+		total.skips += r.skips
+		total.aborts += r.aborts
+	}
+	fmt.Fprintf(w, "%s\t\t%d\t%d\t%d\t%d\t%d\n",
+		total.pkg, total.blocks, total.leaks, total.completes, total.skips, total.aborts)
+
+	w.Flush()
+	return sb.String()
+}
+
+func (s unitSummary) Log() {
+	fmt.Println()
+	fmt.Println("================ Summary =====================")
+	fmt.Println(s)
+}
+
+// ExceedsThreshold returns true if the number of findings exceeds the
+// threshold given by -max-findings.
+func (s unitSummary) ExceedsThreshold() bool {
+	if max := opts.MaxFindings(); max >= 0 && s.Findings() > max {
+		log.Println(color.RedString("Number of findings (%d) exceeds the threshold (%d)", s.Findings(), max))
+		return true
+	}
+	return false
+}
+
+// analyzeUnit abstractly interprets every analysis context of a single main
+// or test package, and summarizes the outcomes. Each context is skipped if
+// it exceeds the timeout, and aborted if the analysis panics.
+func analyzeUnit(pkg *ssa.Package, Cs map[*ssa.Function]ai.AnalysisCtxt, timeout time.Duration) unitResult {
+	res := unitResult{pkg: pkg.Pkg.Path()}
+
+	// Ensure consistent ordering
+	entries := make([]*ssa.Function, 0, len(Cs))
+	for f := range Cs {
+		entries = append(entries, f)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].String() < entries[j].String()
+	})
+
+	for _, f := range entries {
+		C := Cs[f]
+		log.Println("Abstractly interpreting", f, "in", res.pkg)
+
+		blocks := func() (blocks ai.Blocks) {
+			defer func() {
+				if err := recover(); err != nil {
+					C.Metrics.Panic(err)
+				}
+			}()
+
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-time.After(timeout):
+					C.Metrics.Skip()
+				case <-done:
+				}
+			}()
+
+			C.Metrics.TimerStart()
+			G, A := ai.StaticAnalysis(C)
+			if C.Metrics.Outcome != "" {
+				return nil
+			}
+
+			blocks = suppress(ai.BlockAnalysis(C, G, A))
+			C.Metrics.SetBlocks(blocks)
+			C.Metrics.Done()
+			return
+		}()
+
+		switch C.Metrics.Outcome {
+		case ai.OUTCOME_SKIP:
+			log.Println(color.RedString("Skipped!"))
+			res.skips++
+		case ai.OUTCOME_PANIC:
+			log.Println(color.RedString("Aborted!"))
+			log.Println(C.Metrics.Error())
+			res.aborts++
+		default:
+			log.Println(color.GreenString("SA completed in %s", C.Metrics.Performance()))
+			res.completes++
+			res.blocks += len(blocks.Deadlocks())
+			res.leaks += len(blocks.Leaks())
+			if len(blocks) > 0 {
+				blocks.Log()
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestUnitSummary(t *testing.T) {
+	summary := unitSummary{
+		{pkg: "example.com/a", blocks: 2, leaks: 1, completes: 3},
+		{pkg: "example.com/b", leaks: 2, completes: 1, skips: 1},
+		{pkg: "example.com/c", aborts: 1},
+		{pkg: "example.com/d"},
+	}
+
+	// Goroutines leaked at program exit are not findings.
+	if findings := summary.Findings(); findings != 2 {
+		t.Errorf("Expected 2 findings, got %d", findings)
+	}
+
+	table := summary.String()
+	for _, expected := range []string{
+		"example.com/a  Bugs found  2       1      3          0        0",
+		"example.com/b  Skipped     0       2      1          1        0",
+		"example.com/c  Panicked    0       0      0          0        1",
+		"example.com/d  No entries  0       0      0          0        0",
+		"Total                      2       3      4          1        1",
+	} {
+		if !strings.Contains(table, expected) {
+			t.Errorf("Expected table to contain %q:\n%s", expected, table)
+		}
+	}
+}
+
+func TestExceedsThreshold(t *testing.T) {
+	defer flag.Set("max-findings", "-1")
+
+	summary := unitSummary{{pkg: "example.com/a", blocks: 2, leaks: 5, completes: 1}}
+	for _, test := range []struct {
+		max      string
+		expected bool
+	}{
+		{"-1", false},
+		{"0", true},
+		{"1", true},
+		{"2", false},
+	} {
+		if err := flag.Set("max-findings", test.max); err != nil {
+			t.Fatal(err)
+		}
+		if res := summary.ExceedsThreshold(); res != test.expected {
+			t.Errorf("Expected %v for -max-findings %s, got %v", test.expected, test.max, res)
+		}
+	}
+}
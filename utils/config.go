@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of project configuration files, in order of precedence.
+var configFiles = []string{"goat.json", "goat.yaml", "goat.yml"}
+
+// Config is the contents of a project configuration file (goat.json or
+// goat.yaml). It provides defaults for command line options, and options
+// that are provided explicitly on the command line take precedence.
+type Config struct {
+	// Primitive set strategy, see -psets.
+	PSets string `json:"psets" yaml:"psets"`
+	// File of user-defined PSets, see -pset-file.
+	PSetFile string `json:"psetFile" yaml:"psetFile"`
+	// Upper bound for dynamically spawned goroutines, see -goro-bound.
+	GoroBound uint `json:"goroBound" yaml:"goroBound"`
+	// Analysis timeout, e.g. "90s", see -timeout.
+	Timeout string `json:"timeout" yaml:"timeout"`
+	// Packages excluded from the analysis, see -exclude.
+	Exclude []string `json:"exclude" yaml:"exclude"`
+	// Concurrency models of library functions, see -models.
+	Models string `json:"models" yaml:"models"`
+	// Reports that should not be shown.
+	Suppressions []Suppression `json:"suppressions" yaml:"suppressions"`
+}
+
+// Suppression silences reports at a source location. If Line is 0,
+// every report in the file is suppressed.
+type Suppression struct {
+	File   string `json:"file" yaml:"file"`
+	Line   int    `json:"line" yaml:"line"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// Matches returns true if the suppression covers the given position.
+// Files are matched by path suffix, such that suppressions may be given
+// relative to the project root. The suffix must consist of whole path
+// elements, i.e., main.go does not match notmain.go.
+func (s Suppression) Matches(pos token.Position) bool {
+	file, sup := filepath.ToSlash(pos.Filename), filepath.ToSlash(s.File)
+	if s.File == "" || file != sup && !strings.HasSuffix(file, "/"+sup) {
+		return false
+	}
+	return s.Line == 0 || s.Line == pos.Line
+}
+
+// LoadConfig reads a project configuration file. The format is determined
+// by the file extension.
+func LoadConfig(path string) (cfg Config, err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(contents, &cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &cfg)
+	default:
+		return cfg, fmt.Errorf("unsupported configuration file format: %s", path)
+	}
+
+	if err != nil {
+		return cfg, fmt.Errorf("unable to parse configuration file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// findConfig returns the path to the configuration file in the current
+// directory, or an empty string if there is none.
+func findConfig() string {
+	for _, name := range configFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyConfig uses the values of the configuration file for every option
+// that was not set explicitly on the command line.
+func applyConfig(cfg Config) error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if cfg.PSets != "" && !set["psets"] {
+		opts.psets = cfg.PSets
+	}
+	if cfg.PSetFile != "" && !set["pset-file"] {
+		opts.psetPath = cfg.PSetFile
+	}
+	if cfg.GoroBound != 0 && !set["goro-bound"] {
+		opts.goroBound = cfg.GoroBound
+	}
+	if cfg.Timeout != "" && !set["timeout"] {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout in configuration file: %w", err)
+		}
+		opts.timeout = timeout
+	}
+	if cfg.Models != "" && !set["models"] {
+		opts.modelsPath = cfg.Models
+	}
+	if len(cfg.Exclude) > 0 && !set["exclude"] {
+		opts.exclude = strings.Join(cfg.Exclude, ",")
+	}
+
+	for _, s := range cfg.Suppressions {
+		if s.File == "" {
+			return errors.New("suppressions in the configuration file must specify a file")
+		}
+	}
+	opts.suppressions = cfg.Suppressions
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"goat.json": `{
+			"psets": "gcatch",
+			"goroBound": 3,
+			"timeout": "90s",
+			"exclude": ["example.com/gen/..."],
+			"suppressions": [{"file": "pkg/main.go", "line": 10, "reason": "known"}]
+		}`,
+		"goat.yaml": `
+psets: gcatch
+goroBound: 3
+timeout: 90s
+exclude:
+  - example.com/gen/...
+suppressions:
+  - file: pkg/main.go
+    line: 10
+    reason: known
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.PSets != "gcatch" || cfg.GoroBound != 3 || cfg.Timeout != "90s" {
+				t.Errorf("Unexpected options: %+v", cfg)
+			}
+			if len(cfg.Exclude) != 1 || cfg.Exclude[0] != "example.com/gen/..." {
+				t.Errorf("Unexpected excluded packages: %v", cfg.Exclude)
+			}
+			if len(cfg.Suppressions) != 1 || cfg.Suppressions[0] != (Suppression{"pkg/main.go", 10, "known"}) {
+				t.Errorf("Unexpected suppressions: %v", cfg.Suppressions)
+			}
+		})
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	tests := []struct {
+		s        Suppression
+		pos      token.Position
+		expected bool
+	}{
+		{Suppression{File: "pkg/main.go", Line: 10}, token.Position{Filename: "/src/pkg/main.go", Line: 10}, true},
+		{Suppression{File: "pkg/main.go", Line: 10}, token.Position{Filename: "/src/pkg/main.go", Line: 11}, false},
+		{Suppression{File: "pkg/main.go"}, token.Position{Filename: "/src/pkg/main.go", Line: 11}, true},
+		{Suppression{File: "other.go"}, token.Position{Filename: "/src/pkg/main.go", Line: 11}, false},
+		{Suppression{File: "main.go"}, token.Position{Filename: "main.go", Line: 11}, true},
+		{Suppression{File: "pkg/main.go"}, token.Position{Filename: "/src/otherpkg/main.go", Line: 11}, false},
+		{Suppression{File: "main.go"}, token.Position{Filename: "/src/pkg/notmain.go", Line: 11}, false},
+	}
+
+	for _, test := range tests {
+		if res := test.s.Matches(test.pos); res != test.expected {
+			t.Errorf("Expected %v.Matches(%v) to be %v", test.s, test.pos, test.expected)
+		}
+	}
+}
+
+func TestParseSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		task     string
+		nextArgs int
+	}{
+		{[]string{"check", "-gopath", "examples", "simple"}, "abstract-interp", 3},
+		{[]string{"graph", "callgraph", "simple"}, "callgraph-to-dot", 1},
+		{[]string{"pointsto", "simple"}, "points-to", 1},
+		{[]string{"bench", "gobench", "-timeout", "30s"}, "bench-gobench", 2},
+		{[]string{"-task", "check-psets", "simple"}, "", 3},
+	}
+
+	for _, test := range tests {
+		args, task := parseSubcommand(test.args)
+		if task != test.task || len(args) != test.nextArgs {
+			t.Errorf("Expected %v to select task %q with %d arguments, got %q and %v",
+				test.args, test.task, test.nextArgs, task, args)
+		}
+	}
+}
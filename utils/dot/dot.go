@@ -4,82 +4,67 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"text/template"
 
+	"github.com/cs-au-dk/goat/utils"
+
 	"github.com/goccy/go-graphviz"
 )
 
-// location of dot executable for converting from .dot to .svg
-// it's usually at: /usr/bin/dot
-var dotExe string
-
-// dotToImageGraphviz generates a SVG using the 'dot' utility, returning the filepath
-func dotToImageGraphviz(outfname string, format string, dot []byte) (string, error) {
-	if dotExe == "" {
-		dot, err := exec.LookPath("dot")
-		if err != nil {
-			log.Fatalln("unable to find program 'dot', please install it or check your PATH")
-		}
-		dotExe = dot
-	}
+var opts = utils.Opts()
 
-	var basepath string
-	if outfname == "" {
-		basepath = filepath.Join(os.TempDir(), "go-callvis_export.")
-	} else {
-		basepath = fmt.Sprintf("%s.", outfname)
-	}
+// renderFormats renders a graph in DOT format to each of the given image formats
+// with the Graphviz library, writing <basepath>.<format>. No external Graphviz
+// installation is required.
+func renderFormats(basepath string, dot []byte, formats ...string) ([]string, error) {
+	g := graphviz.New()
+	defer g.Close()
 
-	dotpath := basepath + "dot"
-	if err := ioutil.WriteFile(dotpath, dot, 0644); err != nil {
-		return "", err
+	graph, err := graphviz.ParseBytes(dot)
+	if err != nil {
+		return nil, err
 	}
+	defer graph.Close()
 
-	fmt.Printf("Exported dot graph to %s\n", dotpath)
-
-	img := basepath + format
-	cmd := exec.Command(dotExe, fmt.Sprintf("-T%s", format), "-o", img)
-	cmd.Stdin = bytes.NewReader(dot)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("command '%v': %v\n%v", cmd, err, stderr.String())
+	imgs := make([]string, 0, len(formats))
+	for _, format := range formats {
+		img := basepath + "." + format
+		if err := g.RenderFilename(graph, graphviz.Format(format), img); err != nil {
+			return imgs, fmt.Errorf("rendering %s: %w", img, err)
+		}
+		imgs = append(imgs, img)
 	}
-	return img, nil
+	return imgs, nil
 }
 
+// DotToImage writes the graph in DOT format to <outfname>.dot and renders it
+// to <outfname>.<format>, returning the path of the image. If outfname is empty,
+// the files are written to the temporary directory.
 func DotToImage(outfname string, format string, dot []byte) (string, error) {
-	if true {
-		return dotToImageGraphviz(outfname, format, dot)
+	basepath := outfname
+	if basepath == "" {
+		basepath = filepath.Join(os.TempDir(), "go-callvis_export")
 	}
 
-	g := graphviz.New()
-	graph, err := graphviz.ParseBytes(dot)
-	if err != nil {
+	dotpath := basepath + ".dot"
+	if err := os.WriteFile(dotpath, dot, 0644); err != nil {
 		return "", err
 	}
-	defer func() {
-		if err := graph.Close(); err != nil {
-			log.Fatal(err)
-		}
-		g.Close()
-	}()
-	var img string
-	if outfname == "" {
-		img = filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis_export.%s", format))
-	} else {
-		img = fmt.Sprintf("%s.%s", outfname, format)
-	}
-	if err := g.RenderFilename(graph, graphviz.Format(format), img); err != nil {
+
+	fmt.Printf("Exported dot graph to %s\n", dotpath)
+
+	imgs, err := renderFormats(basepath, dot, format)
+	if err != nil {
 		return "", err
 	}
-	return img, nil
+	return imgs[0], nil
 }
 
 const tmplCluster = `{{define "cluster" -}}
@@ -202,6 +187,8 @@ func (p DotAttrs) Lines() string {
 
 // ==[ type def/func: DotGraph   ]===============================================
 type DotGraph struct {
+	// Base name of the files the graph is written to with -output-dir.
+	Name     string
 	Title    string
 	Attrs    DotAttrs
 	Clusters []*DotCluster
@@ -220,6 +207,16 @@ func (g *DotGraph) countNodes() int {
 	return res
 }
 
+// countEdges counts the visible edges of the graph.
+func (g *DotGraph) countEdges() (res int) {
+	for _, e := range g.Edges {
+		if str, ok := e.Attrs["style"]; !(ok && strings.Contains(str, "invis")) {
+			res++
+		}
+	}
+	return
+}
+
 func (g *DotGraph) WriteDot(w io.Writer) error {
 	t := template.New("dot")
 	t.Option("missingkey=zero") // Make missing map keys return the zero value of appropriate type
@@ -236,7 +233,73 @@ func (g *DotGraph) WriteDot(w io.Writer) error {
 	return err
 }
 
+var (
+	savedMu sync.Mutex
+	// Number of graphs saved with each name, used to make file names unique.
+	saved = map[string]int{}
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Save writes the graph in DOT format to the output directory given with
+// -output-dir, and renders it to every format given with -format.
+// The files are named after the graph, with a numbered suffix if a graph
+// of the same name was already saved. Returns the paths of the written files.
+func (g *DotGraph) Save() ([]string, error) {
+	dir := opts.OutputDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(g.Name, "-"), "-")
+	if name == "" {
+		name = "graph"
+	}
+
+	savedMu.Lock()
+	saved[name]++
+	if n := saved[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+	savedMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := g.WriteDot(&buf); err != nil {
+		return nil, err
+	}
+
+	basepath := filepath.Join(dir, name)
+	dotpath := basepath + ".dot"
+	if err := os.WriteFile(dotpath, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	formats := []string{}
+	for _, format := range strings.Split(opts.OutputFormat(), ",") {
+		if format = strings.TrimSpace(format); format != "" && format != "dot" {
+			formats = append(formats, format)
+		}
+	}
+
+	imgs, err := renderFormats(basepath, buf.Bytes(), formats...)
+	return append([]string{dotpath}, imgs...), err
+}
+
+// ShowDot opens the graph in xdot. If an output directory is given
+// with -output-dir, the graph is saved there instead, see Save.
 func (g *DotGraph) ShowDot() {
+	if opts.OutputDir() != "" {
+		paths, err := g.Save()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Graph has %d nodes and %d edges.\n", g.countNodes(), g.countEdges())
+		for _, path := range paths {
+			log.Println("Stored graph at", path)
+		}
+		return
+	}
+
 	xdot, err := exec.LookPath("xdot")
 	if err != nil {
 		log.Fatalln("unable to find program 'xdot', please install it or check your PATH")
@@ -256,15 +319,8 @@ func (g *DotGraph) ShowDot() {
 		log.Fatalln(err)
 	}
 
-	var edgeCount int
-	for _, e := range g.Edges {
-		if str, ok := e.Attrs["style"]; !(ok && strings.Contains(str, "invis")) {
-			edgeCount++
-		}
-	}
-
 	log.Println("Stored dotgraph at", f.Name())
-	log.Printf("Graph has %d nodes and %d edges.\n", g.countNodes(), edgeCount)
+	log.Printf("Graph has %d nodes and %d edges.\n", g.countNodes(), g.countEdges())
 	log.Println("Starting xdot...")
 
 	if err := exec.Command(xdot, f.Name()).Run(); err != nil {
@@ -0,0 +1,52 @@
+package dot
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "graphs")
+	for name, value := range map[string]string{"output-dir": dir, "format": "svg,png"} {
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer flag.Set("output-dir", "")
+	defer flag.Set("format", "svg")
+
+	a, b := &DotNode{ID: "a"}, &DotNode{ID: "b"}
+	g := &DotGraph{
+		Name:  "cfg-(*T).f",
+		Nodes: []*DotNode{a, b},
+		Edges: []*DotEdge{{From: a, To: b}},
+		Options: map[string]string{
+			"minlen":  "2",
+			"nodesep": "0.35",
+		},
+	}
+
+	for _, expected := range [][]string{
+		{"cfg--T-.f.dot", "cfg--T-.f.svg", "cfg--T-.f.png"},
+		{"cfg--T-.f-2.dot", "cfg--T-.f-2.svg", "cfg--T-.f-2.png"},
+	} {
+		paths, err := g.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(paths) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, paths)
+		}
+		for i, path := range paths {
+			if path != filepath.Join(dir, expected[i]) {
+				t.Errorf("Expected %s, got %s", expected[i], path)
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+				t.Errorf("Expected %s to be written: %v", path, err)
+			}
+		}
+	}
+}
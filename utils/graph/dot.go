@@ -10,6 +10,8 @@ import (
 var opts = utils.Opts()
 
 type VisualizationConfig[T any] struct {
+	// Name of the graph, used for file names when saving it (see dot.DotGraph).
+	Name string
 	// Provides the ID and attributes for dot nodes.
 	// If not provided, the ID is the stringified node.
 	NodeAttrs func(node T) (string, dot.DotAttrs)
@@ -40,6 +42,7 @@ func (G Graph[T]) ToDotGraph(nodes []T, cfg *VisualizationConfig[T]) *dot.DotGra
 	}
 
 	dg := &dot.DotGraph{
+		Name:    cfg.Name,
 		Options: graphOpts,
 	}
 
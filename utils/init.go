@@ -4,18 +4,42 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 type options struct {
 	goroBound       uint
+	maxFindings     int
+	timeout         time.Duration
+	configPath      string
+	exclude         string
+	suppressions    []Suppression
+	baselinePath    string
+	reportPath      string
+	metricsPath     string
+	groundTruthPath string
+	modelsPath      string
+	psetPath        string
+	minimize        bool
+	htmlPath        string
+	pathFormat      string
+	harness         string
+	harnessGoros    int
 	minlen          uint
 	pseti           int
 	nodesep         float64
 	function        string
 	outputFormat    string
+	outputDir       string
 	gopath          string
 	modulePath      string
+	workPath        string
+	buildTags       string
+	goos            string
+	goarch          string
 	psets           string
 	task            string
 	logai           bool
@@ -33,6 +57,7 @@ type options struct {
 	justGoros       bool
 	skipChanNames   bool
 	skipSync        bool
+	psetChansOnly   bool
 	noAbort         bool
 }
 
@@ -51,6 +76,9 @@ const (
 	_WRITTEN_FIELDS_ANALYSIS
 	_COLLECT_PRIMITIVES
 	_CHECK_PSETS
+	_CHECK_RACES
+	_EXPLORE
+	_BENCH_GOBENCH
 )
 
 const (
@@ -59,6 +87,7 @@ const (
 	_PSET_INTRA_DEP
 	_PSET_TOTAL
 	_PSET_SAMEFUNC
+	_PSET_USER
 )
 
 func CanColorize(col func(...interface{}) string) func(...interface{}) string {
@@ -112,8 +141,45 @@ var task = []struct{ flag, explanation string }{{
 }, {
 	"check-psets",
 	"Print the result of computing Psets",
+}, {
+	"check-races",
+	"Perform abstract interpretation and report potential data races",
+}, {
+	"explore",
+	"Step through the abstract transition system in an interactive prompt",
+}, {
+	"bench-gobench",
+	"Analyze the GoKer blocking bugs and score the reports against a ground truth of blocked lines",
 }}
 
+// Subcommands are shorthands for tasks, e.g. "goat check <package>".
+var subcommands = []struct {
+	name, explanation string
+	task              int
+}{
+	{"check", "Check for concurrency bugs with abstract interpretation", _ABSTRACT_INTERP},
+	{"metrics", "Collect static metrics on over-approximations", _STATIC_METRICS},
+	{"pointsto", "Perform points-to analysis and log all points-to sets", _POINTS_TO},
+}
+
+// Kinds of graphs supported by the "graph" subcommand, e.g. "goat graph cfg <package>".
+var graphKinds = []struct {
+	name string
+	task int
+}{
+	{"cfg", _CFG_TO_DOT},
+	{"callgraph", _CALLGRAPH_TO_DOT},
+	{"topology", _GORO_TOPOLOGY},
+}
+
+// Benchmark suites supported by the "bench" subcommand, e.g. "goat bench gobench".
+var benchSuites = []struct {
+	name string
+	task int
+}{
+	{"gobench", _BENCH_GOBENCH},
+}
+
 var psets = []struct{ flag, explanation string }{{
 	"singleton",
 	"Primitive sets consist of singletons of channels, identified by allocation site",
@@ -129,6 +195,9 @@ var psets = []struct{ flag, explanation string }{{
 }, {
 	"samefunc",
 	"Primitive sets are formed by merging primitives that are allocated or used in the same function",
+}, {
+	"user",
+	"Primitive sets are given by //goat:pset <name> comments on primitive allocations, and by the file given with -pset-file",
 }}
 
 var opts = &options{}
@@ -147,6 +216,96 @@ func (optInterface) NoColorize() bool {
 	return opts.noColorize
 }
 
+func (optInterface) Timeout() time.Duration {
+	return opts.timeout
+}
+
+// TimeoutOr returns the timeout given by -timeout or the configuration
+// file, or def if none was given.
+func (optInterface) TimeoutOr(def time.Duration) time.Duration {
+	if opts.timeout > 0 {
+		return opts.timeout
+	}
+	return def
+}
+
+func (optInterface) Exclude() []string {
+	if opts.exclude == "" {
+		return nil
+	}
+	return strings.Split(opts.exclude, ",")
+}
+
+// Harness returns the types and functions for which an entry point should be generated.
+func (optInterface) Harness() []string {
+	if opts.harness == "" {
+		return nil
+	}
+	return strings.Split(opts.harness, ",")
+}
+
+func (optInterface) HarnessGoros() int {
+	return opts.harnessGoros
+}
+
+// Suppressions returns the suppressions given in the configuration file.
+func (optInterface) Suppressions() []Suppression {
+	return opts.suppressions
+}
+
+func (optInterface) BaselinePath() string {
+	return opts.baselinePath
+}
+
+// MetricsPath returns the path of the file that metrics are exported to, see -metrics-out.
+func (optInterface) MetricsPath() string {
+	return opts.metricsPath
+}
+
+// GroundTruthPath returns the path to the ground truth of the benchmark
+// suite, given by -ground-truth. Defaults to the ground-truth.yaml file
+// next to the GoKer blocking bugs in the GOPATH.
+func (optInterface) GroundTruthPath() string {
+	if opts.groundTruthPath == "" {
+		return filepath.Join(opts.gopath, "src", filepath.Dir(GoBenchRoot), "ground-truth.yaml")
+	}
+	return opts.groundTruthPath
+}
+
+func (optInterface) ReportPath() string {
+	return opts.reportPath
+}
+
+func (optInterface) ModelsPath() string {
+	return opts.modelsPath
+}
+
+// HTMLPath returns the path of the interactive HTML report of the
+// superlocation graph, see -html.
+func (optInterface) HTMLPath() string {
+	return opts.htmlPath
+}
+
+// PathFormat returns the format in which blocking paths are rendered, see -path-format.
+func (optInterface) PathFormat() string {
+	return opts.pathFormat
+}
+
+// Minimize returns true if the PSets and fragments of reported blocking
+// bugs should be minimized, see -minimize.
+func (optInterface) Minimize() bool {
+	return opts.minimize
+}
+
+// PSetPath returns the path to the file of user-defined PSets, see -pset-file.
+func (optInterface) PSetPath() string {
+	return opts.psetPath
+}
+
+func (optInterface) MaxFindings() int {
+	return opts.maxFindings
+}
+
 func (optInterface) GoroBound() int {
 	return int(opts.goroBound)
 }
@@ -179,12 +338,33 @@ func (optInterface) Function() string {
 func (optInterface) OutputFormat() string {
 	return opts.outputFormat
 }
+
+// OutputDir returns the directory that graphs are written to instead of
+// being shown, see -output-dir.
+func (optInterface) OutputDir() string {
+	return opts.outputDir
+}
 func (optInterface) GoPath() string {
 	return opts.gopath
 }
 func (optInterface) ModulePath() string {
 	return opts.modulePath
 }
+func (optInterface) WorkPath() string {
+	return opts.workPath
+}
+func (optInterface) BuildTags() []string {
+	if opts.buildTags == "" {
+		return nil
+	}
+	return strings.Split(opts.buildTags, ",")
+}
+func (optInterface) GOOS() string {
+	return opts.goos
+}
+func (optInterface) GOARCH() string {
+	return opts.goarch
+}
 func (optInterface) LogAI() bool {
 	return opts.logai
 }
@@ -206,6 +386,9 @@ func (psetInterface) Total() bool {
 func (psetInterface) SameFunc() bool {
 	return opts.psets == psets[_PSET_SAMEFUNC].flag
 }
+func (psetInterface) User() bool {
+	return opts.psets == psets[_PSET_USER].flag
+}
 func (optInterface) Task() taskInterface {
 	return taskInterface{}
 }
@@ -245,6 +428,15 @@ func (taskInterface) IsCollectPrimitives() bool {
 func (taskInterface) IsCheckPsets() bool {
 	return opts.task == task[_CHECK_PSETS].flag
 }
+func (taskInterface) IsCheckRaces() bool {
+	return opts.task == task[_CHECK_RACES].flag
+}
+func (taskInterface) IsExplore() bool {
+	return opts.task == task[_EXPLORE].flag
+}
+func (taskInterface) IsBenchGoBench() bool {
+	return opts.task == task[_BENCH_GOBENCH].flag
+}
 func (taskInterface) IsPosition() bool {
 	return opts.task == task[_POSITION].flag
 }
@@ -290,6 +482,9 @@ func (optInterface) SkipChanNames() bool {
 func (optInterface) SkipSync() bool {
 	return opts.skipSync
 }
+func (optInterface) PSetChansOnly() bool {
+	return opts.psetChansOnly || opts.skipSync
+}
 func (optInterface) NoAbort() bool {
 	return opts.noAbort
 }
@@ -314,15 +509,26 @@ func init() {
 		"the framework will search for a function matching that name in the main package. If one is not found, "+
 		"it will proceed to do a search across all packages. Will return the first function matching that name.\n"+
 		"- Use '.' to perform targeted analysis on all functions in the main package.\n")
-	flag.StringVar(&(opts.outputFormat), "format", "svg", "output file format [svg | png | jpg | ...]")
+	flag.StringVar(&(opts.outputFormat), "format", "svg", "output file format [svg | png | jpg | ...]. With -output-dir, a comma-separated list of formats, e.g. svg,png")
+	flag.StringVar(&(opts.outputDir), "output-dir", "", "write every graph to the given directory in DOT format, and rendered in the formats given with -format, instead of opening a viewer")
 	flag.StringVar(&(opts.gopath), "gopath", "examples", "specify GOPATH to be used for packages.Load")
 	flag.StringVar(&(opts.modulePath), "modulepath", "", `specify a path to a directory containing a Go module.
 - If provided this will make our code loading tools (that piggyback on Go's tools) run
 in "module-aware" mode (GO111MODULE=on).`)
+	flag.StringVar(&(opts.workPath), "workpath", "", `specify a path to a go.work file, or a directory containing one.
+- If provided, packages are loaded in workspace mode, and package patterns
+are resolved relative to the directory of the go.work file.`)
+	flag.StringVar(&(opts.buildTags), "tags", "", "comma-separated list of build tags to consider satisfied when loading packages")
+	flag.StringVar(&(opts.goos), "goos", "", "target operating system used when selecting files (defaults to the host)")
+	flag.StringVar(&(opts.goarch), "goarch", "", "target architecture used when selecting files (defaults to the host)")
 	flag.StringVar(&(opts.psets), "psets", psets[_PSET_SINGLETON].flag, "When collecting primitives, determine primitive grouping strategy. Options:"+psetFlag)
+	flag.StringVar(&(opts.psetPath), "pset-file", "", "path to a file of primitive allocation sites given as <file>:<line> [<pset name>] lines, used with -psets user")
+	flag.BoolVar(&(opts.minimize), "minimize", false, "when collecting primitives, shrink the PSet and the expanded functions of every reported blocking bug with delta debugging")
 	flag.StringVar(&(opts.task), "task", task[_ABSTRACT_INTERP].flag, "Set the task to do during execution. Options:"+taskFlag)
 	flag.BoolVar(&(opts.logai), "ai-logging", false, "Enable logging of specific events during abstract interpretation")
 	flag.BoolVar(&(opts.metrics), "metrics", false, "Enable collection of performance metrics for abstract interpretation")
+	flag.StringVar(&(opts.metricsPath), "metrics-out", "", "with -metrics, export the metrics of every entry function to the given .json or .csv file")
+	flag.StringVar(&(opts.groundTruthPath), "ground-truth", "", "with \"bench gobench\", path to the ground truth of blocked lines (default <gopath>/src/gobench/goker/ground-truth.yaml)")
 	flag.BoolVar(&(opts.noColorize), "no-colorize", false, "Disable pretty printer colorization")
 	flag.BoolVar(&(opts.extended), "extended", false, "Include additional information, e.g. channel buffer size and closing.")
 	flag.BoolVar(&(opts.verbose), "verbose", false, "enable verbose output")
@@ -333,12 +539,26 @@ in "module-aware" mode (GO111MODULE=on).`)
 	flag.BoolVar(&(opts.justGoros), "just-goroutines", false, "channels are excluded from the graph.")
 	flag.BoolVar(&(opts.fullCg), "full-cg", false, "disable goroutine transitive closure in callgraph")
 	flag.BoolVar(&(opts.skipChanNames), "skip-chan-names", false, "disable associating channel allocation sites with source code given names in the original AST")
+	flag.StringVar(&(opts.htmlPath), "html", "", "write a self-contained, interactive HTML report of the superlocation graph to the given file")
+	flag.StringVar(&(opts.pathFormat), "path-format", "text", "format of the paths to reported blocking bugs [text | mermaid | plantuml]. Text paths are printed step by step with -visualize, while mermaid and plantuml print a sequence diagram of every path")
 	flag.BoolVar(&(opts.visualize), "visualize", false, "enable visualization via XDot")
 	flag.BoolVar(&(opts.skipSync), "skip-sync", false, "skip special modelling of features of the 'sync' library")
+	flag.BoolVar(&(opts.psetChansOnly), "pset-chans-only", false, "only include channels in primitive sets, leaving out 'sync' primitives (implied by -skip-sync)")
 	flag.BoolVar(&(opts.noAbort), "no-abort", false, "disable aborts upon critical precision loss")
 	flag.UintVar(&(opts.goroBound), "goro-bound", 1, "set upper bound for dynamically spawned goroutines")
+	flag.IntVar(&(opts.maxFindings), "max-findings", -1, "when analysing several main or test packages, exit with a non-zero status if the total number of findings exceeds this number (negative to disable)")
+	flag.DurationVar(&(opts.timeout), "timeout", 0, "time limit for analysing a single entry or fragment (defaults depend on the task)")
+	flag.StringVar(&(opts.configPath), "config", "", "path to a project configuration file (defaults to goat.json or goat.yaml in the current directory)")
+	flag.StringVar(&(opts.exclude), "exclude", "", "comma-separated list of package patterns to exclude from the analysis, e.g. example.com/gen/...")
+	flag.StringVar(&(opts.baselinePath), "baseline", "", "path to a report of known findings (see -report) that should not be reported again")
+	flag.StringVar(&(opts.reportPath), "report", "", "write the fingerprints of all findings to the given file, to be used with -baseline")
+	flag.StringVar(&(opts.modelsPath), "models", "", "path to a file with concurrency models of library functions (JSON or YAML)")
+	flag.StringVar(&(opts.harness), "harness", "", "comma-separated list of library types or functions to analyse with a generated entry point, e.g. example.com/queue.Queue")
+	flag.IntVar(&(opts.harnessGoros), "harness-goros", 2, "number of goroutines calling each method or function in the generated entry point (see -harness)")
 	flag.BoolVar(&(opts.httpDebug), "http-debug", false, "Start an http/pprof server for debugging")
 
+	flag.Usage = usage
+
 	// Set up logging
 	log.SetFlags(log.Ltime | log.Lshortfile)
 }
@@ -346,7 +566,33 @@ in "module-aware" mode (GO111MODULE=on).`)
 func ParseArgs() {
 	// Calling flag.Parse in init messes up unit tests.
 	// See https://stackoverflow.com/questions/60235896/flag-provided-but-not-defined-test-v
-	flag.Parse()
+	args, subTask := parseSubcommand(os.Args[1:])
+	flag.CommandLine.Parse(args)
+
+	if subTask != "" {
+		taskSet := false
+		flag.Visit(func(f *flag.Flag) {
+			taskSet = taskSet || f.Name == "task"
+		})
+		if taskSet {
+			log.Fatalln("-task cannot be combined with a subcommand")
+		}
+		opts.task = subTask
+	}
+
+	configPath := opts.configPath
+	if configPath == "" {
+		configPath = findConfig()
+	}
+	if configPath != "" {
+		cfg, err := LoadConfig(configPath)
+		if err == nil {
+			err = applyConfig(cfg)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
 
 	validTask := false
 	for _, task := range task {
@@ -360,6 +606,20 @@ func ParseArgs() {
 		log.Fatalf("Value \"%s\" is not valid for -task", opts.task)
 	}
 
+	switch opts.pathFormat {
+	case "text", "mermaid", "plantuml":
+	default:
+		log.Fatalf("Value \"%s\" is not valid for -path-format", opts.pathFormat)
+	}
+
+	if opts.metricsPath != "" {
+		switch filepath.Ext(opts.metricsPath) {
+		case ".json", ".csv":
+		default:
+			log.Fatalf("Value \"%s\" is not valid for -metrics-out, expected a .json or .csv file", opts.metricsPath)
+		}
+	}
+
 	if opts.localPackages {
 		opts.includeInternal = false
 	}
@@ -376,6 +636,63 @@ func ParseArgs() {
 	}
 }
 
+// parseSubcommand strips a leading subcommand from the command line
+// arguments, and returns the remaining arguments and the selected task.
+func parseSubcommand(args []string) ([]string, string) {
+	if len(args) == 0 {
+		return args, ""
+	}
+
+	for _, sub := range subcommands {
+		if args[0] == sub.name {
+			return args[1:], task[sub.task].flag
+		}
+	}
+
+	if args[0] == "graph" {
+		kinds := make([]string, 0, len(graphKinds))
+		for _, kind := range graphKinds {
+			if len(args) > 1 && args[1] == kind.name {
+				return args[2:], task[kind.task].flag
+			}
+			kinds = append(kinds, kind.name)
+		}
+		log.Fatalf("Usage: graph %s [flags] <packages>", strings.Join(kinds, "|"))
+	}
+
+	if args[0] == "bench" {
+		suites := make([]string, 0, len(benchSuites))
+		for _, suite := range benchSuites {
+			if len(args) > 1 && args[1] == suite.name {
+				return args[2:], task[suite.task].flag
+			}
+			suites = append(suites, suite.name)
+		}
+		log.Fatalf("Usage: bench %s [flags] [<packages>]", strings.Join(suites, "|"))
+	}
+
+	return args, ""
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [subcommand] [flags] <packages>\n\nSubcommands:\n", os.Args[0])
+	for _, sub := range subcommands {
+		fmt.Fprintf(out, "  %-10s %s\n", sub.name, sub.explanation)
+	}
+	kinds := make([]string, 0, len(graphKinds))
+	for _, kind := range graphKinds {
+		kinds = append(kinds, kind.name)
+	}
+	fmt.Fprintf(out, "  %-10s %s\n", "graph", "Visualize a graph ("+strings.Join(kinds, "|")+")")
+	suites := make([]string, 0, len(benchSuites))
+	for _, suite := range benchSuites {
+		suites = append(suites, suite.name)
+	}
+	fmt.Fprintf(out, "  %-10s %s\n\nFlags:\n", "bench", "Score the analysis on a benchmark suite ("+strings.Join(suites, "|")+")")
+	flag.PrintDefaults()
+}
+
 func (optInterface) AnalyzeAllFuncs() bool {
 	return opts.function == "."
 }
@@ -383,6 +700,7 @@ func (optInterface) AnalyzeAllFuncs() bool {
 func (optInterface) IsWholeProgramAnalysis() bool {
 	return (Opts().Task().IsAbstractInterpretation() ||
 		Opts().Task().IsCollectPrimitives() ||
+		Opts().Task().IsExplore() ||
 		Opts().Task().IsCfgToDot()) &&
 		opts.function == "main"
 }
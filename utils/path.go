@@ -25,3 +25,18 @@ func MakePath() (path string) {
 
 	return
 }
+
+// GoBenchRoot is the import path of the GoKer blocking bugs in the GOPATH.
+const GoBenchRoot = "gobench/goker/blocking"
+
+// MakePatterns returns the package patterns provided on the command line,
+// e.g. "./..." or "example.com/pkg/...". Defaults to "hello-world", or to
+// all GoKer blocking bugs for "bench gobench".
+func MakePatterns() []string {
+	if args := flag.Args(); len(args) >= 1 {
+		return args
+	} else if Opts().Task().IsBenchGoBench() {
+		return []string{GoBenchRoot + "/..."}
+	}
+	return []string{"hello-world"}
+}